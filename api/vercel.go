@@ -3,6 +3,8 @@ package api
 import (
 	"final-project-rest-api/configs"
 	"final-project-rest-api/docs"
+	"final-project-rest-api/events"
+	"final-project-rest-api/models"
 	"final-project-rest-api/routes"
 	"final-project-rest-api/utils"
 	"log"
@@ -42,6 +44,11 @@ func init() {
 
 	log.Println("Connecting to database...")
 	db := configs.ConnectDataBase()
+	events.Start(db, time.Minute)
+	models.StartWebhookWorker(db, 15*time.Second)
+	models.StartKeyRotation(db, 10*time.Minute)
+	models.StartAuditChain(db, 30*time.Second)
+	events.StartAccountDeletion(db, time.Hour)
 
 	log.Println("Setting up routes...")
 	App = routes.SetupRouter(db)
@@ -0,0 +1,32 @@
+// Command mock-oidc runs a local OpenID Connect provider to try the social login with:
+//
+//	go run ./cmd/mock-oidc -addr :9999
+//
+// then set OIDC_PROVIDERS=mock, OIDC_MOCK_ISSUER=http://localhost:9999,
+// OIDC_MOCK_CLIENT_ID=laptop-reviews and OIDC_MOCK_CLIENT_SECRET=secret.
+package main
+
+import (
+	"final-project-rest-api/utils/oidc/mock"
+	"flag"
+	"log"
+	"net/http"
+)
+
+func main() {
+	addr := flag.String("addr", ":9999", "address to listen on")
+	issuer := flag.String("issuer", "http://localhost:9999", "issuer URL the provider is reached at")
+	clientID := flag.String("client-id", "laptop-reviews", "client ID to accept")
+	clientSecret := flag.String("client-secret", "secret", "client secret to accept")
+	email := flag.String("email", "mock.user@example.com", "email to log in as when there is no login_hint")
+	flag.Parse()
+
+	provider, err := mock.New(*issuer, *clientID, *clientSecret)
+	if err != nil {
+		log.Fatal(err)
+	}
+	provider.Email = *email
+
+	log.Printf("Mock OIDC provider %s listening on %s", *issuer, *addr)
+	log.Fatal(http.ListenAndServe(*addr, provider))
+}
@@ -4,6 +4,7 @@ import (
 	"final-project-rest-api/models"
 	"final-project-rest-api/utils"
 	"fmt"
+	"log"
 	"os"
 
 	"gorm.io/driver/mysql"
@@ -49,7 +50,40 @@ func ConnectDataBase() *gorm.DB {
 
 	}
 
-	db.AutoMigrate(&models.User{}, &models.Profile{}, &models.Laptop{}, &models.Brand{}, &models.Category{}, &models.Comment{})
+	if err := models.RegisterAuditCallbacks(db); err != nil {
+		panic(err.Error())
+	}
+
+	// accounts from before email verification are taken as verified once, when it is added
+	verifyExisting := db.Migrator().HasTable(&models.User{}) && !db.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt")
+	// the wishlists from before DefaultFor are marked once, when it is added
+	markDefaults := db.Migrator().HasTable(&models.Collection{}) && !db.Migrator().HasColumn(&models.Collection{}, "DefaultFor")
+
+	db.AutoMigrate(&models.User{}, &models.Profile{}, &models.Laptop{}, &models.Brand{}, &models.Category{}, &models.Comment{}, &models.AspectRating{}, &models.OwnershipClaim{}, &models.CommentRevision{}, &models.Collection{}, &models.CollectionItem{}, &models.AlertSubscription{}, &models.Notification{}, &models.NotificationPreference{}, &models.Webhook{}, &models.WebhookDelivery{}, &models.OutboxEvent{}, &models.PasswordResetToken{}, &models.LoginAttempt{}, &models.RecoveryCode{}, &models.LoginChallenge{}, &models.SigningKey{}, &models.APIKey{}, &models.UserIdentity{}, &models.OIDCLoginState{}, &models.Session{}, &models.DataExport{}, &models.PrivacySetting{}, &models.AdminAction{}, &models.AuditLog{}, &models.AuditChainHead{})
+
+	if err := models.MigrateAuditLog(db); err != nil {
+		panic(err.Error())
+	}
+
+	if verifyExisting {
+		if err := models.VerifyExistingUsers(db); err != nil {
+			panic(err.Error())
+		}
+	}
+
+	if markDefaults {
+		if err := models.MigrateDefaultCollections(db); err != nil {
+			panic(err.Error())
+		}
+	}
+
+	promoted, err := models.BootstrapAdmins(db)
+	if err != nil {
+		panic(err.Error())
+	}
+	for _, username := range promoted {
+		log.Printf("Promoted %s to admin from ADMIN_USERNAMES", username)
+	}
 
 	return db
 
@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"final-project-rest-api/dto"
+	"final-project-rest-api/events"
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils/token"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type DataExportInput struct {
+	Format string `json:"format" example:"zip"`
+}
+
+type DeleteAccountInput struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code"`
+}
+
+// GetMyDataExports godoc
+// @Summary Get my data exports.
+// @Description Get the exports of the logged-in user's data, with whether each is ready to download.
+// @Tags Account
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/exports [get]
+func GetMyDataExports(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var exports []models.DataExport
+	if err := db.Omit("archive").Where("user_id = ? AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now()).
+		Order("created_at DESC").Find(&exports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve exports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"exports": dto.NewDataExports(exports)})
+}
+
+// RequestDataExport godoc
+// @Summary Export my data.
+// @Description Start preparing a copy of everything stored about the logged-in user: account, profile, reviews and the rest,
+// @Description as a ZIP archive of JSON files or a single JSON document. The user is notified when it is ready to download.
+// @Description One export can be requested a day.
+// @Tags Account
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param Body body DataExportInput false "the archive format, zip or json"
+// @Produce json
+// @Success 202 {object} dto.DataExport
+// @Router /api/me/exports [post]
+func RequestDataExport(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input DataExportInput
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var export models.DataExport
+	err = events.Transaction(db, func(tx *events.Tx) error {
+		var err error
+		export, err = models.RequestDataExport(tx.DB, userID, input.Format)
+		if err != nil {
+			return err
+		}
+		return tx.Publish(events.DataExportRequested{ExportID: export.ID, UserID: userID})
+	})
+	switch err {
+	case nil:
+	case models.ErrDataExportFormat:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case models.ErrDataExportPending:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case models.ErrDataExportThrottled:
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request export"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, dto.NewDataExport(export))
+}
+
+// DownloadDataExport godoc
+// @Summary Download a data export.
+// @Description Download a ready export of the logged-in user's data.
+// @Tags Account
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Export ID"
+// @Produce application/zip
+// @Produce json
+// @Success 200 {file} file
+// @Router /api/me/exports/{id}/download [get]
+func DownloadDataExport(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var export models.DataExport
+	if err := db.Where("id = ? AND user_id = ? AND (expires_at IS NULL OR expires_at > ?)", c.Param("id"), userID, time.Now()).
+		First(&export).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export not found"})
+		return
+	}
+	if export.Status != models.ExportReady {
+		c.JSON(http.StatusConflict, gin.H{"error": "Export is not ready", "status": export.Status})
+		return
+	}
+
+	contentType := "application/zip"
+	if export.Format == models.ExportFormatJSON {
+		contentType = "application/json"
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+export.Filename()+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, contentType, export.Archive)
+}
+
+// GetAccountDeletion godoc
+// @Summary Get my account deletion.
+// @Description Get when the logged-in user's account is scheduled to be deleted, if it is, and what happens to their reviews.
+// @Tags Account
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/deletion [get]
+func GetAccountDeletion(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	user, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scheduled":      user.DeletionScheduledAt != nil,
+		"scheduled_at":   user.DeletionScheduledAt,
+		"grace_days":     int(models.AccountDeletionGrace().Hours() / 24),
+		"reviews_policy": models.DeletedReviewsPolicy(),
+	})
+}
+
+// DeleteAccount godoc
+// @Summary Delete my account.
+// @Description Schedule the logged-in user's account to be deleted with everything stored about them once the grace period is over.
+// @Description Until then the deletion can be cancelled. Depending on the site's policy, reviews are kept without the author's name or deleted.
+// @Description Requires the password, and a two-factor code when it is enabled.
+// @Tags Account
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param Body body DeleteAccountInput true "the password and, with two-factor authentication, a code"
+// @Produce json
+// @Success 202 {object} map[string]interface{}
+// @Router /api/me/deletion [post]
+func DeleteAccount(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var input DeleteAccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+	if err := user.VerifyPassword(input.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid password"})
+		return
+	}
+	if user.TwoFactorEnabled() && !verifySecondFactor(c, db, &user, input.Code) {
+		return
+	}
+
+	switch err := models.ScheduleAccountDeletion(db, &user); err {
+	case nil:
+	case models.ErrDeletionScheduled, models.ErrLastAdmin:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	default:
+		if user.DeletionScheduledAt == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule deletion"})
+			return
+		}
+		// scheduled, only the confirmation email failed
+		log.Printf("Failed to mail the deletion notice of user %d: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":        "Account scheduled for deletion",
+		"scheduled_at":   user.DeletionScheduledAt,
+		"reviews_policy": models.DeletedReviewsPolicy(),
+	})
+}
+
+// CancelAccountDeletion godoc
+// @Summary Cancel my account deletion.
+// @Description Keep the logged-in user's account that was scheduled for deletion.
+// @Tags Account
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/deletion [delete]
+func CancelAccountDeletion(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	switch err := models.CancelAccountDeletion(db, userID); err {
+	case nil:
+	case models.ErrDeletionNotScheduled:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel deletion"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deletion cancelled"})
+}
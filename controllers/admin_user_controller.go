@@ -0,0 +1,469 @@
+package controllers
+
+import (
+	"final-project-rest-api/dto"
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils/token"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const maxAdminPerPage = 100
+
+type SuspendUserInput struct {
+	Reason string    `json:"reason" binding:"required,max=500"`
+	Until  time.Time `json:"until" binding:"required" example:"2030-01-01T00:00:00Z"`
+}
+
+type BanUserInput struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+type UserRoleInput struct {
+	Role string `json:"role" binding:"required" example:"editor"`
+}
+
+type ImpersonateInput struct {
+	Reason string `json:"reason" binding:"required,max=500" example:"Reproducing a bug report"`
+}
+
+// userSorts are the orders users can be listed in.
+var userSorts = map[string]string{
+	"created_at":  "created_at, id",
+	"-created_at": "created_at DESC, id DESC",
+	"username":    "username",
+	"-username":   "username DESC",
+	"reputation":  "reputation, id",
+	"-reputation": "reputation DESC, id",
+}
+
+// GetUsers godoc
+// @Summary List users.
+// @Description List the accounts, filtered by a search on username or email, role and status. Admin only.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param q query string false "part of the username or email"
+// @Param role query string false "user, editor or admin"
+// @Param status query string false "active, suspended, banned, locked, unverified or deletion_scheduled"
+// @Param sort query string false "created_at, username or reputation, prefixed with - for descending (default -created_at)"
+// @Param page query int false "page number, starting at 1"
+// @Param per_page query int false "users per page, up to 100 (default 20)"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/users [get]
+func GetUsers(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	page, perPage, ok := pagination(c)
+	if !ok {
+		return
+	}
+	order, ok := userSorts[c.DefaultQuery("sort", "-created_at")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be created_at, username or reputation, optionally prefixed with -"})
+		return
+	}
+
+	now := time.Now()
+	query := db.Model(&models.User{}).Where("username <> ?", models.DeletedUsername)
+	if q := strings.ToLower(strings.TrimSpace(c.Query("q"))); q != "" {
+		like := "%" + q + "%"
+		query = query.Where("LOWER(username) LIKE ? OR LOWER(email) LIKE ?", like, like)
+	}
+	if role := c.Query("role"); role != "" {
+		if !models.IsRole(role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": models.ErrInvalidRole.Error()})
+			return
+		}
+		query = query.Where("role = ?", role)
+	}
+	switch c.Query("status") {
+	case "":
+	case models.UserActive:
+		query = query.Where("suspended_at IS NULL OR suspended_until <= ?", now)
+	case models.UserSuspended:
+		query = query.Where("suspended_at IS NOT NULL AND suspended_until > ?", now)
+	case models.UserBanned:
+		query = query.Where("suspended_at IS NOT NULL AND suspended_until IS NULL")
+	case "locked":
+		query = query.Where("locked_until > ?", now)
+	case "unverified":
+		query = query.Where("email_verified_at IS NULL")
+	case "deletion_scheduled":
+		query = query.Where("deletion_scheduled_at IS NOT NULL")
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be active, suspended, banned, locked, unverified or deletion_scheduled"})
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
+		return
+	}
+
+	var users []models.User
+	if err := query.Order(order).Limit(perPage).Offset((page - 1) * perPage).Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": dto.NewAdminUsers(users), "total": total, "page": page, "per_page": perPage})
+}
+
+// GetUser godoc
+// @Summary Get a user.
+// @Description Get an account with its status and profile. Admin only.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "User ID"
+// @Produce json
+// @Success 200 {object} dto.AdminUser
+// @Router /api/admin/users/{id} [get]
+func GetUser(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var user models.User
+	if err := db.Preload("Profile").First(&user, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewAdminUser(user))
+}
+
+// SuspendUser godoc
+// @Summary Suspend a user.
+// @Description Keep a user from logging in or using the API until the given time, sign out their sessions and email them the reason. Admins can't suspend other admins. Admin only.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "User ID"
+// @Param Body body SuspendUserInput true "the reason and when the suspension ends"
+// @Produce json
+// @Success 200 {object} dto.AdminUser
+// @Router /api/admin/users/{id}/suspend [post]
+func SuspendUser(c *gin.Context) {
+	var input SuspendUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !input.Until.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "until must be in the future"})
+		return
+	}
+	suspendUser(c, input.Reason, &input.Until)
+}
+
+// BanUser godoc
+// @Summary Ban a user.
+// @Description Keep a user from logging in or using the API until the ban is lifted, sign out their sessions and email them the reason. Admins can't ban other admins. Admin only.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "User ID"
+// @Param Body body BanUserInput true "the reason"
+// @Produce json
+// @Success 200 {object} dto.AdminUser
+// @Router /api/admin/users/{id}/ban [post]
+func BanUser(c *gin.Context) {
+	var input BanUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	suspendUser(c, input.Reason, nil)
+}
+
+func suspendUser(c *gin.Context, reason string, until *time.Time) {
+	db := c.MustGet("db").(*gorm.DB)
+	adminID, user, ok := adminTarget(c, db, false)
+	if !ok {
+		return
+	}
+
+	if err := models.SuspendUser(db, &user, reason, until); err != nil {
+		if user.SuspendedAt == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suspend user"})
+			return
+		}
+		// suspended, only the email failed
+		log.Printf("Failed to mail the suspension notice of user %d: %v", user.ID, err)
+	}
+
+	action := models.AdminActionBan
+	details := map[string]interface{}{}
+	if until != nil {
+		action = models.AdminActionSuspend
+		details["until"] = until
+	}
+	logAdminAction(c, db, models.AdminAction{AdminID: adminID, TargetUserID: user.ID, Action: action, Reason: reason, Details: details})
+
+	c.JSON(http.StatusOK, dto.NewAdminUser(user))
+}
+
+// UnsuspendUser godoc
+// @Summary Lift a suspension or ban.
+// @Description Let a suspended or banned user log in again. Admin only.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "User ID"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/users/{id}/suspension [delete]
+func UnsuspendUser(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	adminID, user, ok := adminTarget(c, db, true)
+	if !ok {
+		return
+	}
+
+	switch err := models.UnsuspendUser(db, user.ID); err {
+	case nil:
+	case models.ErrNotSuspended:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lift suspension"})
+		return
+	}
+	logAdminAction(c, db, models.AdminAction{AdminID: adminID, TargetUserID: user.ID, Action: models.AdminActionUnsuspend})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Suspension lifted"})
+}
+
+// UpdateUserRole godoc
+// @Summary Change a user's role.
+// @Description Make a user a user, an editor or an admin. Admins can't change another admin's role. Admin only.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "User ID"
+// @Param Body body UserRoleInput true "the new role"
+// @Produce json
+// @Success 200 {object} dto.AdminUser
+// @Router /api/admin/users/{id}/role [put]
+func UpdateUserRole(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var input UserRoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID, user, ok := adminTarget(c, db, false)
+	if !ok {
+		return
+	}
+
+	switch err := models.SetUserRole(db, user.ID, input.Role); err {
+	case nil:
+	case models.ErrInvalidRole:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change role"})
+		return
+	}
+	if input.Role != user.Role {
+		logAdminAction(c, db, models.AdminAction{
+			AdminID:      adminID,
+			TargetUserID: user.ID,
+			Action:       models.AdminActionRoleChange,
+			Details:      map[string]interface{}{"from": user.Role, "to": input.Role},
+		})
+	}
+	user.Role = input.Role
+
+	c.JSON(http.StatusOK, dto.NewAdminUser(user))
+}
+
+// ForcePasswordReset godoc
+// @Summary Force a password reset.
+// @Description Sign a user out everywhere, revoke their API keys and keep them from logging in until they
+// @Description choose a new password with the reset link they are emailed. Admins can't do this to other admins. Admin only.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "User ID"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/users/{id}/force-password-reset [post]
+func ForcePasswordReset(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	adminID, user, ok := adminTarget(c, db, false)
+	if !ok {
+		return
+	}
+
+	if err := models.ForcePasswordReset(db, &user); err != nil {
+		if !user.PasswordResetRequired {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to force password reset"})
+			return
+		}
+		// the user can still request a link from the forgot password page
+		log.Printf("Failed to mail the forced password reset of user %d: %v", user.ID, err)
+	}
+	logAdminAction(c, db, models.AdminAction{AdminID: adminID, TargetUserID: user.ID, Action: models.AdminActionForcePasswordReset})
+
+	c.JSON(http.StatusOK, gin.H{"message": "The user has been signed out and must reset their password"})
+}
+
+// ImpersonateUser godoc
+// @Summary Impersonate a user.
+// @Description Get a token to act as a user for 15 minutes, e.g. to reproduce what they see. Admins can't be impersonated,
+// @Description and the user's password, keys, data exports and deletion can't be touched with the token.
+// @Description The impersonation and every request made with the token are recorded. Admin only.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "User ID"
+// @Param Body body ImpersonateInput true "why the user is impersonated"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/users/{id}/impersonate [post]
+func ImpersonateUser(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var input ImpersonateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID, user, ok := adminTarget(c, db, false)
+	if !ok {
+		return
+	}
+	if user.Suspension() != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Suspended users can't be impersonated"})
+		return
+	}
+
+	session, jwtToken, err := models.StartImpersonation(db, adminID, user, c.Request.UserAgent(), c.ClientIP())
+	switch err {
+	case nil:
+	case models.ErrImpersonateAdmin:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to impersonate user"})
+		return
+	}
+	logAdminAction(c, db, models.AdminAction{
+		AdminID:      adminID,
+		TargetUserID: user.ID,
+		Action:       models.AdminActionImpersonate,
+		Reason:       input.Reason,
+		Details:      map[string]interface{}{"session_id": session.ID},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"token": jwtToken, "session_id": session.ID, "expires_at": session.ExpiresAt})
+}
+
+// GetAdminActions godoc
+// @Summary Get admin actions.
+// @Description Get what admins did to user accounts, newest first, including the requests made while impersonating. Admin only.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param admin_id query string false "the admin who acted"
+// @Param user_id query string false "the user acted on"
+// @Param action query string false "e.g. user.suspend or user.impersonate"
+// @Param page query int false "page number, starting at 1"
+// @Param per_page query int false "actions per page, up to 100 (default 20)"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/actions [get]
+func GetAdminActions(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	page, perPage, ok := pagination(c)
+	if !ok {
+		return
+	}
+
+	query := db.Model(&models.AdminAction{})
+	if adminID := c.Query("admin_id"); adminID != "" {
+		query = query.Where("admin_id = ?", adminID)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("target_user_id = ?", userID)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve admin actions"})
+		return
+	}
+
+	var actions []models.AdminAction
+	if err := query.Order("id DESC").Limit(perPage).Offset((page - 1) * perPage).Find(&actions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve admin actions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"actions": dto.NewAdminActions(actions), "total": total, "page": page, "per_page": perPage})
+}
+
+// pagination reads the page and per_page query parameters, answering 400 when they are invalid.
+func pagination(c *gin.Context) (int, int, bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive number"})
+		return 0, 0, false
+	}
+	perPage, err := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if err != nil || perPage < 1 || perPage > maxAdminPerPage {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "per_page must be between 1 and 100"})
+		return 0, 0, false
+	}
+	return page, perPage, true
+}
+
+// adminTarget returns the logged-in admin and the user in the path they act on. Admins
+// can't act on their own account, so there is always an admin left, nor on another
+// admin's, unless restores is set for an action that only gives access back.
+func adminTarget(c *gin.Context, db *gorm.DB, restores bool) (uint, models.User, bool) {
+	adminID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return 0, models.User{}, false
+	}
+
+	var user models.User
+	if err := db.Where("username <> ?", models.DeletedUsername).First(&user, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return 0, models.User{}, false
+	}
+	if user.ID == adminID {
+		c.JSON(http.StatusConflict, gin.H{"error": "You can't do this to your own account"})
+		return 0, models.User{}, false
+	}
+	if user.Role == models.RoleAdmin && !restores {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can't do this to another admin's account"})
+		return 0, models.User{}, false
+	}
+	return adminID, user, true
+}
+
+// logAdminAction records what an admin did. The action has already happened, so a
+// failure is only logged.
+func logAdminAction(c *gin.Context, db *gorm.DB, action models.AdminAction) {
+	action.IP = c.ClientIP()
+	if err := models.LogAdminAction(db, action); err != nil {
+		log.Printf("Failed to record admin action %s on user %d: %v", action.Action, action.TargetUserID, err)
+	}
+}
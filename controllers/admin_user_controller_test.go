@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils/token"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// newAdminRouter serves the admin user routes as if admin had logged in.
+func newAdminRouter(t *testing.T, admin models.User) (*gin.Engine, *gorm.DB) {
+	t.Helper()
+	r, db := newTestRouter(t)
+	if err := db.AutoMigrate(&models.AdminAction{}, &models.APIKey{}, &models.PasswordResetToken{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&admin).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	users := r.Group("/api/admin/users", func(c *gin.Context) { c.Set(token.UserIDKey, admin.ID) })
+	users.POST("/:id/unlock", UnlockUser)
+	users.POST("/:id/ban", BanUser)
+	users.DELETE("/:id/suspension", UnsuspendUser)
+	users.PUT("/:id/role", UpdateUserRole)
+	users.POST("/:id/force-password-reset", ForcePasswordReset)
+	return r, db
+}
+
+func TestAdminsCantActOnOtherAdmins(t *testing.T) {
+	r, db := newAdminRouter(t, models.User{Username: "root", Email: "root@example.com", Password: "x", Role: models.RoleAdmin})
+	other := models.User{Username: "ops", Email: "ops@example.com", Password: "x", Role: models.RoleAdmin}
+	db.Create(&other)
+
+	path := fmt.Sprintf("/api/admin/users/%d", other.ID)
+	for _, req := range []struct{ method, path, body string }{
+		{http.MethodPost, path + "/ban", `{"reason":"rogue"}`},
+		{http.MethodPut, path + "/role", `{"role":"user"}`},
+		{http.MethodPost, path + "/force-password-reset", ``},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(req.method, req.path, strings.NewReader(req.body)))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s %s = %d %s", req.method, req.path, w.Code, w.Body.String())
+		}
+	}
+
+	var kept models.User
+	db.First(&kept, other.ID)
+	if kept.Role != models.RoleAdmin || kept.SuspendedAt != nil {
+		t.Fatalf("the other admin was changed: role %q, suspended at %v", kept.Role, kept.SuspendedAt)
+	}
+
+	// giving access back is still allowed, e.g. to an admin locked out by failed logins
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path+"/unlock", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unlock = %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"final-project-rest-api/dto"
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils/token"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AlertInput struct {
+	Type        string   `json:"type" binding:"required,oneof=price_target price_drop new_review new_laptop"`
+	LaptopID    *uint    `json:"laptop_id"`
+	BrandID     *uint    `json:"brand_id"`
+	TargetPrice float64  `json:"target_price"`
+	DropPercent float64  `json:"drop_percent"`
+	Channels    []string `json:"channels"`
+	WebhookURL  string   `json:"webhook_url"`
+	Active      *bool    `json:"active"`
+}
+
+func (input AlertInput) apply(alert *models.AlertSubscription) {
+	alert.Type = input.Type
+	alert.LaptopID = input.LaptopID
+	alert.BrandID = input.BrandID
+	alert.TargetPrice = input.TargetPrice
+	alert.DropPercent = input.DropPercent
+	alert.Channels = input.Channels
+	alert.WebhookURL = input.WebhookURL
+	if input.Active != nil {
+		alert.Active = *input.Active
+	}
+}
+
+// GetMyAlerts godoc
+// @Summary Get my alerts.
+// @Description Get the alert subscriptions of the logged-in user.
+// @Tags Alert
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/alerts [get]
+func GetMyAlerts(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var alerts []models.AlertSubscription
+	if err := db.Where("user_id = ?", userID).Find(&alerts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"alerts": dto.NewAlerts(alerts)})
+}
+
+// CreateAlert godoc
+// @Summary Create an alert.
+// @Description Subscribe to a price target, a percent price drop or new reviews of a laptop, or to new laptops of a brand.
+// @Description Alerts are always stored in-app and can also be delivered by email or webhook.
+// @Tags Alert
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param Body body AlertInput true "the body to create an alert"
+// @Produce json
+// @Success 201 {object} dto.Alert
+// @Router /api/me/alerts [post]
+func CreateAlert(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input AlertInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	alert := models.AlertSubscription{UserID: userID, Active: true}
+	input.apply(&alert)
+	if err := alert.Validate(db); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Create(&alert).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.NewAlert(alert))
+}
+
+// UpdateAlert godoc
+// @Summary Update an alert.
+// @Description Update an alert subscription of the logged-in user.
+// @Tags Alert
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Alert ID"
+// @Param Body body AlertInput true "the body to update an alert"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/alerts/{id} [put]
+func UpdateAlert(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input AlertInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var alert models.AlertSubscription
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&alert).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+
+	input.apply(&alert)
+	if err := alert.Validate(db); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Save(&alert).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alert"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert updated successfully", "alert": dto.NewAlert(alert)})
+}
+
+// DeleteAlert godoc
+// @Summary Delete an alert.
+// @Description Delete an alert subscription of the logged-in user.
+// @Tags Alert
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Alert ID"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/alerts/{id} [delete]
+func DeleteAlert(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	result := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).Delete(&models.AlertSubscription{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert deleted successfully"})
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"final-project-rest-api/dto"
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils/token"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type APIKeyInput struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// GetMyAPIKeys godoc
+// @Summary Get my API keys.
+// @Description Get the API keys of the logged-in user, including revoked and expired ones.
+// @Tags API Key
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/api-keys [get]
+func GetMyAPIKeys(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var keys []models.APIKey
+	if err := db.Where("user_id = ?", userID).Order("id DESC").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": dto.NewAPIKeys(keys)})
+}
+
+// CreateAPIKey godoc
+// @Summary Create an API key.
+// @Description Create an API key for scripts to call the API as the logged-in user, sent in the X-API-Key header instead of a Bearer token.
+// @Description Scopes: catalog:read, catalog:write (categories, brands and laptops) and reviews:write (comments).
+// @Description The key is only shown in this response.
+// @Tags API Key
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param Body body APIKeyInput true "the body to create an API key"
+// @Produce json
+// @Success 201 {object} map[string]interface{}
+// @Router /api/me/api-keys [post]
+func CreateAPIKey(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input APIKeyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key := models.APIKey{
+		UserID:    userID,
+		Name:      input.Name,
+		Scopes:    input.Scopes,
+		ExpiresAt: input.ExpiresAt,
+	}
+	if err := key.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plain, err := models.CreateAPIKey(db, &key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"api_key": dto.NewAPIKey(key), "key": plain})
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key.
+// @Description Revoke an API key of the logged-in user. It stops working right away.
+// @Tags API Key
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "API key ID"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/api-keys/{id} [delete]
+func RevokeAPIKey(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	revoked, err := models.RevokeAPIKey(db, userID, uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
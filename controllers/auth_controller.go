@@ -1,9 +1,13 @@
 package controllers
 
 import (
+	"final-project-rest-api/events"
 	"final-project-rest-api/models"
 	"final-project-rest-api/utils/token"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -14,6 +18,11 @@ type LoginInput struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type LoginTwoFactorInput struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
 type RegisterInput struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
@@ -25,6 +34,15 @@ type ChangePasswordInput struct {
 	NewPassword     string `json:"new_password" binding:"required"`
 }
 
+type ForgotPasswordInput struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordInput struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
 // Login handles user login
 // @Summary Login as a user.
 // @Description Logging in to get JWT token to access admin or user API by roles.
@@ -42,15 +60,180 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	token, err := models.LoginCheck(input.Username, input.Password, db)
+	ip := c.ClientIP()
+	if !reserveLoginAttempt(c, db, input.Username, ip) {
+		return
+	}
+
+	user, err := models.Authenticate(input.Username, input.Password, db)
 	if err != nil {
+		if refuseLogin(c, db, input.Username, err) {
+			releaseLoginAttempt(db, input.Username)
+			return
+		}
+		recordLoginAttempt(db, input.Username, ip, models.LoginInvalidCredentials)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "username or password is incorrect"})
 		return
 	}
 
+	if user.TwoFactorEnabled() {
+		challenge, err := models.IssueLoginChallenge(db, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor login"})
+			return
+		}
+		recordLoginAttempt(db, input.Username, ip, models.LoginTwoFactorRequired)
+		releaseLoginAttempt(db, input.Username)
+		c.JSON(http.StatusOK, gin.H{
+			"message":             "two-factor authentication required",
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+		})
+		return
+	}
+
+	token, err := startSession(c, db, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	recordLoginAttempt(db, input.Username, ip, models.LoginSucceeded)
+
+	response := gin.H{"message": "login success", "token": token}
+	if models.TwoFactorRequired(user.Role) {
+		response["two_factor_setup_required"] = true
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// LoginTwoFactor completes a two-factor login
+// @Summary Complete a two-factor login.
+// @Description Exchange the challenge token from /login and a code from the authenticator app,
+// @Description or an unused recovery code, for a JWT token. A challenge token can only be used once.
+// @Tags Auth
+// @Param Body body LoginTwoFactorInput true "the body to complete a two-factor login"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /login/2fa [post]
+func LoginTwoFactor(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var input LoginTwoFactorInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := models.ConsumeLoginChallenge(db, input.ChallengeToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login challenge is invalid or has expired, please log in again"})
+		return
+	}
+
+	// the account may have been suspended since the password was checked
+	if refuseLogin(c, db, user.Username, user.LoginAllowed()) {
+		return
+	}
+
+	if !verifySecondFactor(c, db, &user, input.Code) {
+		return
+	}
+
+	token, err := startSession(c, db, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	recordLoginAttempt(db, user.Username, c.ClientIP(), models.LoginSucceeded)
+
 	c.JSON(http.StatusOK, gin.H{"message": "login success", "token": token})
 }
 
+// reserveLoginAttempt answers 429 and returns false when the username or IP must wait
+// before trying again.
+func reserveLoginAttempt(c *gin.Context, db *gorm.DB, username string, ip string) bool {
+	err := models.ReserveLoginAttempt(db, username, ip)
+	if err == nil {
+		return true
+	}
+	if throttled, ok := err.(*models.LoginThrottledError); ok {
+		recordLoginAttempt(db, username, ip, models.LoginThrottled)
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": throttled.Error()})
+		return false
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	return false
+}
+
+// loginRefusal returns the login attempt reason for an account that can't log in even
+// with the right credentials, or "" when err isn't one of those.
+func loginRefusal(err error) string {
+	if err == models.ErrPasswordResetRequired {
+		return models.LoginResetRequired
+	}
+	if _, ok := err.(*models.SuspendedError); ok {
+		return models.LoginSuspended
+	}
+	return ""
+}
+
+// refuseLogin answers 403 and returns true when err means the account can't log in.
+func refuseLogin(c *gin.Context, db *gorm.DB, username string, err error) bool {
+	reason := loginRefusal(err)
+	if reason == "" {
+		return false
+	}
+	recordLoginAttempt(db, username, c.ClientIP(), reason)
+	c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	return true
+}
+
+// verifySecondFactor checks a TOTP or recovery code under the login throttle, so codes
+// can't be guessed faster than passwords. It answers the request and returns false when
+// the code isn't accepted.
+func verifySecondFactor(c *gin.Context, db *gorm.DB, user *models.User, code string) bool {
+	ip := c.ClientIP()
+	if !reserveLoginAttempt(c, db, user.Username, ip) {
+		return false
+	}
+
+	err := models.VerifySecondFactor(db, user, code)
+	if err == nil || err == models.ErrTwoFactorNotEnabled {
+		releaseLoginAttempt(db, user.Username)
+	}
+	switch err {
+	case nil:
+		return true
+	case models.ErrInvalidTwoFactorCode:
+		recordLoginAttempt(db, user.Username, ip, models.LoginInvalidTwoFactor)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case models.ErrTwoFactorNotEnabled:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+	}
+	return false
+}
+
+// startSession logs the user in on the requesting device and returns the token for it.
+func startSession(c *gin.Context, db *gorm.DB, user models.User) (string, error) {
+	_, jwtToken, err := models.StartSession(db, user.ID, c.Request.UserAgent(), c.ClientIP())
+	return jwtToken, err
+}
+
+func recordLoginAttempt(db *gorm.DB, username string, ip string, reason string) {
+	if err := models.RecordLoginAttempt(db, username, ip, reason); err != nil {
+		log.Printf("Failed to record login attempt: %v", err)
+	}
+}
+
+func releaseLoginAttempt(db *gorm.DB, username string) {
+	if err := models.ReleaseLoginAttempt(db, username); err != nil {
+		log.Printf("Failed to release login attempt: %v", err)
+	}
+}
+
 // Register handles user registration
 // @Summary Register a user.
 // @Description Registering a user from public access.
@@ -74,7 +257,12 @@ func Register(c *gin.Context) {
 		Password: input.Password,
 	}
 
-	_, err := u.SaveUser(db)
+	err := events.Transaction(db, func(tx *events.Tx) error {
+		if _, err := u.SaveUser(tx.DB); err != nil {
+			return err
+		}
+		return tx.Publish(events.UserRegistered{UserID: u.ID, Username: u.Username})
+	})
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -85,7 +273,7 @@ func Register(c *gin.Context) {
 
 // ChangePassword handles changing user's password
 // @Summary Change password for a user.
-// @Description Changing password for a logged-in user.
+// @Description Changing password for a logged-in user. Every other session is ended and earlier tokens and API keys are revoked; the response has a new token for this session.
 // @Tags Auth
 // @Security ApiKeyAuth
 // @Param Authorization header string true "Bearer token"
@@ -118,17 +306,86 @@ func ChangePassword(c *gin.Context) {
 		return
 	}
 
-	hashedPassword, err := models.HashPassword(input.NewPassword)
+	sessionID, err := token.ExtractSessionID(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
-	u.Password = hashedPassword
 
-	if err := db.Save(&u).Error; err != nil {
+	if err := models.ChangePassword(db, u.ID, sessionID, input.NewPassword); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
+	// tokens without a session were revoked with the rest and have to log in again
+	if sessionID == 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully, please log in again"})
+		return
+	}
+	newToken, err := token.GenerateSessionToken(u.ID, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully", "token": newToken})
+}
+
+// ForgotPassword handles password reset requests
+// @Summary Request a password reset.
+// @Description Email a single-use password reset link to the account with this address.
+// @Description The response is the same whether or not the account exists.
+// @Tags Auth
+// @Param Body body ForgotPasswordInput true "the body to request a password reset"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/forgot-password [post]
+func ForgotPassword(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var input ForgotPasswordInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// sent in the background so the response time doesn't tell whether the account exists
+	go func(db *gorm.DB, email string) {
+		if err := models.RequestPasswordReset(db, email); err != nil {
+			log.Printf("Failed to send password reset email: %v", err)
+		}
+	}(models.Detached(db), input.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an account uses this email address, a password reset link has been sent to it"})
+}
+
+// ResetPassword handles resetting a forgotten password
+// @Summary Reset a password.
+// @Description Set a new password with the token from a reset link. The token can only be used once,
+// @Description and every existing login of the account is signed out and its API keys are revoked.
+// @Tags Auth
+// @Param Body body ResetPasswordInput true "the body to reset a password"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/reset-password [post]
+func ResetPassword(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var input ResetPasswordInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := models.ResetPassword(db, input.Token, input.NewPassword)
+	if err == models.ErrInvalidResetToken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset, please log in again"})
 }
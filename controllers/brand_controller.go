@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"final-project-rest-api/dto"
+	"final-project-rest-api/events"
 	"final-project-rest-api/models"
 	"net/http"
 
@@ -35,12 +37,18 @@ func CreateBrand(c *gin.Context) {
 		BrandName: input.BrandName,
 	}
 
-	if err := db.Create(&brand).Error; err != nil {
+	err := events.Transaction(db, func(tx *events.Tx) error {
+		if err := tx.Create(&brand).Error; err != nil {
+			return err
+		}
+		return tx.Publish(events.BrandCreated{Brand: brand})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Brand created successfully", "category": brand})
+	c.JSON(http.StatusOK, gin.H{"message": "Brand created successfully", "category": dto.NewBrand(brand)})
 }
 
 // GetBrands godoc
@@ -59,7 +67,7 @@ func GetBrands(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"brands": brands})
+	c.JSON(http.StatusOK, gin.H{"brands": dto.NewBrands(brands)})
 }
 
 // GetBrandById godoc
@@ -78,7 +86,7 @@ func GetBrandByID(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"category": brand})
+	c.JSON(http.StatusOK, gin.H{"category": dto.NewBrand(brand)})
 }
 
 // UpdateBrand godoc
@@ -108,12 +116,18 @@ func UpdateBrand(c *gin.Context) {
 
 	brand.BrandName = input.BrandName
 
-	if err := db.Save(&brand).Error; err != nil {
+	err := events.Transaction(db, func(tx *events.Tx) error {
+		if err := tx.Save(&brand).Error; err != nil {
+			return err
+		}
+		return tx.Publish(events.BrandUpdated{Brand: brand})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update brand"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Brand updated successfully", "brand": brand})
+	c.JSON(http.StatusOK, gin.H{"message": "Brand updated successfully", "brand": dto.NewBrand(brand)})
 }
 
 // DeleteBrand godoc
@@ -134,7 +148,13 @@ func DeleteBrand(c *gin.Context) {
 		return
 	}
 
-	if err := db.Delete(&brand).Error; err != nil {
+	err := events.Transaction(db, func(tx *events.Tx) error {
+		if err := tx.Delete(&brand).Error; err != nil {
+			return err
+		}
+		return tx.Publish(events.BrandDeleted{Brand: brand})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete brand"})
 		return
 	}
@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"final-project-rest-api/dto"
+	"final-project-rest-api/events"
 	"final-project-rest-api/models"
 	"net/http"
 
@@ -9,7 +11,8 @@ import (
 )
 
 type CategoryInput struct {
-	CategoryName string `json:"name" binding:"required"`
+	CategoryName string   `json:"name" binding:"required"`
+	Aspects      []string `json:"aspects"`
 }
 
 // CreateCategory godoc
@@ -31,16 +34,29 @@ func CreateCategory(c *gin.Context) {
 		return
 	}
 
+	aspects, err := models.NormalizeAspects(input.Aspects)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	category := models.Category{
 		CategoryName: input.CategoryName,
+		Aspects:      aspects,
 	}
 
-	if err := db.Create(&category).Error; err != nil {
+	err = events.Transaction(db, func(tx *events.Tx) error {
+		if err := tx.Create(&category).Error; err != nil {
+			return err
+		}
+		return tx.Publish(events.CategoryCreated{Category: category})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Category created successfully", "category": category})
+	c.JSON(http.StatusOK, gin.H{"message": "Category created successfully", "category": dto.NewCategory(category)})
 }
 
 // GetCategories godoc
@@ -59,7 +75,7 @@ func GetCategories(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"categories": categories})
+	c.JSON(http.StatusOK, gin.H{"categories": dto.NewCategories(categories)})
 }
 
 // GetCategoryById godoc
@@ -78,7 +94,7 @@ func GetCategoryById(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"category": category})
+	c.JSON(http.StatusOK, gin.H{"category": dto.NewCategory(category)})
 }
 
 // UpdateCategory godoc
@@ -106,14 +122,27 @@ func UpdateCategory(c *gin.Context) {
 		return
 	}
 
-	category.CategoryName = input.CategoryName
+	aspects, err := models.NormalizeAspects(input.Aspects)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	if err := db.Save(&category).Error; err != nil {
+	category.CategoryName = input.CategoryName
+	category.Aspects = aspects
+
+	err = events.Transaction(db, func(tx *events.Tx) error {
+		if err := tx.Save(&category).Error; err != nil {
+			return err
+		}
+		return tx.Publish(events.CategoryUpdated{Category: category})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Category updated successfully", "category": category})
+	c.JSON(http.StatusOK, gin.H{"message": "Category updated successfully", "category": dto.NewCategory(category)})
 }
 
 // DeleteCategory godoc
@@ -134,7 +163,13 @@ func DeleteCategory(c *gin.Context) {
 		return
 	}
 
-	if err := db.Delete(&category).Error; err != nil {
+	err := events.Transaction(db, func(tx *events.Tx) error {
+		if err := tx.Delete(&category).Error; err != nil {
+			return err
+		}
+		return tx.Publish(events.CategoryDeleted{Category: category})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category"})
 		return
 	}
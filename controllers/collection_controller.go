@@ -0,0 +1,481 @@
+package controllers
+
+import (
+	"final-project-rest-api/dto"
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils/token"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CollectionInput struct {
+	Name       string `json:"name" binding:"required,max=100"`
+	Visibility string `json:"visibility" binding:"omitempty,oneof=private public"`
+}
+
+type CollectionItemInput struct {
+	LaptopID uint   `json:"laptop_id" binding:"required"`
+	Note     string `json:"note" binding:"max=500"`
+}
+
+type CollectionItemNoteInput struct {
+	Note string `json:"note" binding:"max=500"`
+}
+
+type CollectionOrderInput struct {
+	ItemIDs []uint `json:"item_ids" binding:"required"`
+}
+
+// findMyCollection loads a collection of the logged-in user, writing the error response if it can't.
+func findMyCollection(c *gin.Context, db *gorm.DB) (models.Collection, bool) {
+	var collection models.Collection
+
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return collection, false
+	}
+
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&collection).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return collection, false
+	}
+
+	return collection, true
+}
+
+// GetMyCollections godoc
+// @Summary Get my collections.
+// @Description Get the collections of the logged-in user. The default wishlist is created on first use.
+// @Tags Collection
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/collections [get]
+func GetMyCollections(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if _, err := models.EnsureDefaultCollection(db, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create wishlist"})
+		return
+	}
+
+	var collections []models.Collection
+	if err := db.Preload("Items", models.OrderedItems).Preload("Items.Laptop").
+		Where("user_id = ?", userID).Order("is_default DESC, id").Find(&collections).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve collections"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"collections": dto.NewCollections(collections)})
+}
+
+// CreateCollection godoc
+// @Summary Create a collection.
+// @Description Create a named collection of laptops for the logged-in user.
+// @Tags Collection
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param Body body CollectionInput true "the body to create a collection"
+// @Produce json
+// @Success 201 {object} dto.Collection
+// @Router /api/me/collections [post]
+func CreateCollection(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input CollectionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	shareToken, err := models.GenerateShareToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create collection"})
+		return
+	}
+
+	collection := models.Collection{
+		UserID:     userID,
+		Name:       strings.TrimSpace(input.Name),
+		Visibility: input.Visibility,
+		ShareToken: shareToken,
+	}
+	if collection.Visibility == "" {
+		collection.Visibility = models.VisibilityPrivate
+	}
+	if err := collection.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Create(&collection).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create collection"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.NewCollection(collection))
+}
+
+// GetMyCollection godoc
+// @Summary Get a collection.
+// @Description Get a collection of the logged-in user with its laptops in order.
+// @Tags Collection
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Collection ID"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/collections/{id} [get]
+func GetMyCollection(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	collection, ok := findMyCollection(c, db)
+	if !ok {
+		return
+	}
+
+	if err := db.Preload("Items", models.OrderedItems).Preload("Items.Laptop").First(&collection, collection.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve collection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"collection": dto.NewCollection(collection)})
+}
+
+// UpdateCollection godoc
+// @Summary Update a collection.
+// @Description Rename a collection or change its visibility.
+// @Tags Collection
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Collection ID"
+// @Param Body body CollectionInput true "the body to update a collection"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/collections/{id} [put]
+func UpdateCollection(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var input CollectionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection, ok := findMyCollection(c, db)
+	if !ok {
+		return
+	}
+
+	collection.Name = strings.TrimSpace(input.Name)
+	if input.Visibility != "" {
+		collection.Visibility = input.Visibility
+	}
+	if err := collection.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Save(&collection).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update collection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collection updated successfully", "collection": dto.NewCollection(collection)})
+}
+
+// DeleteCollection godoc
+// @Summary Delete a collection.
+// @Description Delete a collection and its items. The default wishlist can't be deleted.
+// @Tags Collection
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Collection ID"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/collections/{id} [delete]
+func DeleteCollection(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	collection, ok := findMyCollection(c, db)
+	if !ok {
+		return
+	}
+
+	if collection.IsDefault {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The default wishlist can't be deleted"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("collection_id = ?", collection.ID).Delete(&models.CollectionItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&collection).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete collection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collection deleted successfully"})
+}
+
+// RegenerateCollectionShareToken godoc
+// @Summary Regenerate a collection share link.
+// @Description Replace the share token of a collection, invalidating previously shared links.
+// @Tags Collection
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Collection ID"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/collections/{id}/share [post]
+func RegenerateCollectionShareToken(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	collection, ok := findMyCollection(c, db)
+	if !ok {
+		return
+	}
+
+	shareToken, err := models.GenerateShareToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate share token"})
+		return
+	}
+	collection.ShareToken = shareToken
+
+	if err := db.Save(&collection).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate share token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Share token regenerated successfully", "share_token": collection.ShareToken})
+}
+
+// AddCollectionItem godoc
+// @Summary Add a laptop to a collection.
+// @Description Add a laptop with an optional note at the end of a collection.
+// @Tags Collection
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Collection ID"
+// @Param Body body CollectionItemInput true "the laptop to add"
+// @Produce json
+// @Success 201 {object} dto.CollectionItem
+// @Router /api/me/collections/{id}/items [post]
+func AddCollectionItem(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var input CollectionItemInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection, ok := findMyCollection(c, db)
+	if !ok {
+		return
+	}
+
+	var laptop models.Laptop
+	if err := db.Where("id = ?", input.LaptopID).First(&laptop).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Laptop not found"})
+		return
+	}
+
+	var count int64
+	if err := db.Model(&models.CollectionItem{}).Where("collection_id = ? AND laptop_id = ?", collection.ID, laptop.ID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Laptop is already in this collection"})
+		return
+	}
+
+	position, err := models.NextItemPosition(db, collection.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	item := models.CollectionItem{
+		CollectionID: collection.ID,
+		LaptopID:     laptop.ID,
+		Note:         input.Note,
+		Position:     position,
+	}
+	if err := db.Create(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add laptop to collection"})
+		return
+	}
+	item.Laptop = &laptop
+
+	c.JSON(http.StatusCreated, dto.NewCollectionItem(item))
+}
+
+// UpdateCollectionItem godoc
+// @Summary Update a collection item.
+// @Description Update the note of a laptop in a collection.
+// @Tags Collection
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Collection ID"
+// @Param item_id path string true "Collection item ID"
+// @Param Body body CollectionItemNoteInput true "the note of the item"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/collections/{id}/items/{item_id} [put]
+func UpdateCollectionItem(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var input CollectionItemNoteInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection, ok := findMyCollection(c, db)
+	if !ok {
+		return
+	}
+
+	var item models.CollectionItem
+	if err := db.Where("id = ? AND collection_id = ?", c.Param("item_id"), collection.ID).First(&item).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection item not found"})
+		return
+	}
+
+	item.Note = input.Note
+	if err := db.Save(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update collection item"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collection item updated successfully", "item": dto.NewCollectionItem(item)})
+}
+
+// DeleteCollectionItem godoc
+// @Summary Remove a laptop from a collection.
+// @Description Remove an item from a collection.
+// @Tags Collection
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Collection ID"
+// @Param item_id path string true "Collection item ID"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/collections/{id}/items/{item_id} [delete]
+func DeleteCollectionItem(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	collection, ok := findMyCollection(c, db)
+	if !ok {
+		return
+	}
+
+	result := db.Where("id = ? AND collection_id = ?", c.Param("item_id"), collection.ID).Delete(&models.CollectionItem{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove collection item"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection item not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collection item removed successfully"})
+}
+
+// ReorderCollectionItems godoc
+// @Summary Reorder a collection.
+// @Description Set the order of the items of a collection. item_ids must list every item of the collection.
+// @Tags Collection
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Collection ID"
+// @Param Body body CollectionOrderInput true "the item IDs in their new order"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/collections/{id}/order [put]
+func ReorderCollectionItems(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var input CollectionOrderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection, ok := findMyCollection(c, db)
+	if !ok {
+		return
+	}
+
+	var itemIDs []uint
+	if err := db.Model(&models.CollectionItem{}).Where("collection_id = ?", collection.ID).Pluck("id", &itemIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	existing := map[uint]bool{}
+	for _, id := range itemIDs {
+		existing[id] = true
+	}
+	if len(input.ItemIDs) != len(itemIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "item_ids must list every item of the collection once"})
+		return
+	}
+	for _, id := range input.ItemIDs {
+		if !existing[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "item_ids must list every item of the collection once"})
+			return
+		}
+		delete(existing, id)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i, id := range input.ItemIDs {
+			if err := tx.Model(&models.CollectionItem{}).Where("id = ?", id).Update("position", i+1).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder collection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collection reordered successfully"})
+}
+
+// GetSharedCollection godoc
+// @Summary Get a shared collection.
+// @Description Get a public collection by its share token.
+// @Tags Collection
+// @Param token path string true "Share token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/collections/shared/{token} [get]
+func GetSharedCollection(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var collection models.Collection
+	if err := db.Preload("Items", models.OrderedItems).Preload("Items.Laptop").
+		Where("share_token = ? AND visibility = ?", c.Param("token"), models.VisibilityPublic).
+		First(&collection).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"collection": dto.NewCollection(collection)})
+}
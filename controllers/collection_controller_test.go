@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils/token"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCollectionNamesCantBeBlank(t *testing.T) {
+	r, db := newTestRouter(t)
+	if err := db.AutoMigrate(&models.Collection{}); err != nil {
+		t.Fatal(err)
+	}
+	alice := models.User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	db.Create(&alice)
+	collection := models.Collection{UserID: alice.ID, Name: "Gaming", ShareToken: "gaming"}
+	db.Create(&collection)
+
+	me := r.Group("/api/me", func(c *gin.Context) { c.Set(token.UserIDKey, alice.ID) })
+	me.POST("/collections", CreateCollection)
+	me.PUT("/collections/:id", UpdateCollection)
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodPost, "/api/me/collections"},
+		{http.MethodPut, fmt.Sprintf("/api/me/collections/%d", collection.ID)},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(req.method, req.path, strings.NewReader(`{"name":"   "}`)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s = %d %s, want %d", req.method, req.path, w.Code, w.Body.String(), http.StatusBadRequest)
+		}
+	}
+
+	var names []string
+	db.Model(&models.Collection{}).Pluck("name", &names)
+	if len(names) != 1 || names[0] != "Gaming" {
+		t.Fatalf("collections %v", names)
+	}
+}
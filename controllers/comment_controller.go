@@ -1,23 +1,33 @@
 package controllers
 
 import (
+	"final-project-rest-api/dto"
+	"final-project-rest-api/events"
 	"final-project-rest-api/models"
 	"final-project-rest-api/utils/token"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type CommentInput struct {
-	Content  string `json:"content" binding:"required"`
-	Rating   int    `json:"rating" binding:"required"`
-	LaptopID uint   `json:"laptop_id" binding:"required"`
+	Title       string         `json:"title" binding:"max=120"`
+	Content     string         `json:"content" binding:"required"`
+	Rating      int            `json:"rating" binding:"required,min=1,max=5"`
+	LaptopID    uint           `json:"laptop_id" binding:"required"`
+	Aspects     map[string]int `json:"aspects"`
+	Pros        []string       `json:"pros" binding:"max=10,dive,max=200"`
+	Cons        []string       `json:"cons" binding:"max=10,dive,max=200"`
+	UsageMonths int            `json:"usage_months" binding:"min=0,max=600"`
+	UseCases    []string       `json:"use_cases" binding:"max=6"`
 }
 
 // CreateComment godoc
 // @Summary Create a new comment.
-// @Description Create a new comment for a laptop.
+// @Description Create a new comment for a laptop. Newcomers and contributors can only post a limited number of reviews per day.
 // @Tags Comment
 // @Security ApiKeyAuth
 // @Param Authorization header string true "Bearer token"
@@ -39,25 +49,78 @@ func CreateComment(c *gin.Context) {
 		return
 	}
 
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if limit := user.DailyReviewLimit(); limit > 0 {
+		var posted int64
+		if err := db.Model(&models.Comment{}).Where("user_id = ? AND created_at > ?", userID, time.Now().Add(-24*time.Hour)).Count(&posted).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if posted >= int64(limit) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Daily review limit reached for your reputation level"})
+			return
+		}
+	}
+
+	var laptop models.Laptop
+	if err := db.Preload("Category").Where("id = ?", input.LaptopID).First(&laptop).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Laptop not found"})
+		return
+	}
+
+	aspectRatings, err := models.BuildAspectRatings(laptop.Category, input.Aspects)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	useCases, err := models.NormalizeUseCases(input.UseCases)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	comment := models.Comment{
-		Content:  input.Content,
-		Rating:   input.Rating,
-		UserID:   userID,
-		LaptopID: input.LaptopID,
+		Title:         strings.TrimSpace(input.Title),
+		Content:       input.Content,
+		Rating:        input.Rating,
+		Pros:          models.NormalizeHighlights(input.Pros),
+		Cons:          models.NormalizeHighlights(input.Cons),
+		UsageMonths:   input.UsageMonths,
+		UseCases:      useCases,
+		UserID:        userID,
+		LaptopID:      input.LaptopID,
+		AspectRatings: aspectRatings,
 	}
 
-	if err := db.Create(&comment).Error; err != nil {
+	err = events.Transaction(db, func(tx *events.Tx) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		if err := models.RecordCommentRevision(tx.DB, nil, comment, userID); err != nil {
+			return err
+		}
+		return tx.Publish(events.CommentCreated{Comment: comment})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Comment created successfully", "comment": comment})
+	c.JSON(http.StatusOK, gin.H{"message": "Comment created successfully", "comment": dto.NewComment(comment)})
 }
 
 // GetComments godoc
 // @Summary Get all comments.
-// @Description Get a list of all comments.
+// @Description Get a list of all comments. Use verified=true to only get reviews from verified owners.
 // @Tags Comment
+// @Param verified query bool false "Only reviews from verified owners"
+// @Param laptop_id query int false "Only reviews of this laptop"
 // @Produce json
 // @Success 200 {object} map[string]interface{}
 // @Router /api/comments [get]
@@ -65,12 +128,30 @@ func GetComments(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	var comments []models.Comment
 
-	if err := db.Find(&comments).Error; err != nil {
+	query := db.Preload("AspectRatings")
+	if c.Query("verified") == "true" {
+		query = query.Scopes(models.OnlyVerifiedOwners)
+	}
+	if laptopID := c.Query("laptop_id"); laptopID != "" {
+		query = query.Where("laptop_id = ?", laptopID)
+	}
+
+	if err := query.Find(&comments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve comments"})
+		return
+	}
+
+	if err := models.MarkVerifiedOwners(db, comments); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve comments"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"comments": comments})
+	if err := models.LoadAuthors(db, comments); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve comments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"comments": dto.NewComments(comments)})
 }
 
 // GetComment godoc
@@ -84,17 +165,29 @@ func GetComments(c *gin.Context) {
 func GetCommentById(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	var comment models.Comment
-	if err := db.Where("id = ?", c.Param("id")).First(&comment).Error; err != nil {
+	if err := db.Preload("AspectRatings").Where("id = ?", c.Param("id")).First(&comment).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"comment": comment})
+	comments := []models.Comment{comment}
+	if err := models.MarkVerifiedOwners(db, comments); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve comment"})
+		return
+	}
+
+	if err := models.LoadAuthors(db, comments); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve comment"})
+		return
+	}
+	comment = comments[0]
+
+	c.JSON(http.StatusOK, gin.H{"comment": dto.NewComment(comment)})
 }
 
 // UpdateComment godoc
 // @Summary Update a comment.
-// @Description Update a comment by ID.
+// @Description Update a comment by ID. Every edit is kept in the revision log and a review can't be moved to another laptop.
 // @Tags Comment
 // @Security ApiKeyAuth
 // @Param Authorization header string true "Bearer token"
@@ -118,7 +211,7 @@ func UpdateComment(c *gin.Context) {
 	}
 
 	var comment models.Comment
-	if err := db.Where("id = ?", c.Param("id")).First(&comment).Error; err != nil {
+	if err := db.Preload("AspectRatings").Where("id = ?", c.Param("id")).First(&comment).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
 		return
 	}
@@ -128,16 +221,73 @@ func UpdateComment(c *gin.Context) {
 		return
 	}
 
+	if input.LaptopID != comment.LaptopID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A review can't be moved to a different laptop"})
+		return
+	}
+
+	var laptop models.Laptop
+	if err := db.Preload("Category").Where("id = ?", comment.LaptopID).First(&laptop).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Laptop not found"})
+		return
+	}
+
+	aspectRatings, err := models.BuildAspectRatings(laptop.Category, input.Aspects)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	useCases, err := models.NormalizeUseCases(input.UseCases)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	original := comment
+
+	comment.Title = strings.TrimSpace(input.Title)
 	comment.Content = input.Content
 	comment.Rating = input.Rating
-	comment.LaptopID = input.LaptopID
+	comment.Pros = models.NormalizeHighlights(input.Pros)
+	comment.Cons = models.NormalizeHighlights(input.Cons)
+	comment.UsageMonths = input.UsageMonths
+	comment.UseCases = useCases
+	comment.AspectRatings = aspectRatings
+
+	changes, err := models.DiffSnapshots(original.Snapshot(), comment.Snapshot())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+
+	if len(changes) == 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "Comment updated successfully", "comment": dto.NewComment(original)})
+		return
+	}
 
-	if err := db.Save(&comment).Error; err != nil {
+	now := time.Now()
+	comment.Edited = true
+	comment.EditedAt = &now
+
+	err = events.Transaction(db, func(tx *events.Tx) error {
+		if err := tx.Where("comment_id = ?", comment.ID).Delete(&models.AspectRating{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(&comment).Error; err != nil {
+			return err
+		}
+		if err := models.RecordCommentRevision(tx.DB, &original, comment, userID); err != nil {
+			return err
+		}
+		return tx.Publish(events.CommentUpdated{Comment: comment, Changes: changes, ActorID: userID})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Comment updated successfully", "comment": comment})
+	c.JSON(http.StatusOK, gin.H{"message": "Comment updated successfully", "comment": dto.NewComment(comment)})
 }
 
 // DeleteComment godoc
@@ -169,10 +319,55 @@ func DeleteComment(c *gin.Context) {
 		return
 	}
 
-	if err := db.Delete(&comment).Error; err != nil {
+	err = events.Transaction(db, func(tx *events.Tx) error {
+		if err := tx.Delete(&comment).Error; err != nil {
+			return err
+		}
+		return tx.Publish(events.CommentDeleted{Comment: comment, ActorID: userID})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
 }
+
+// GetCommentRevisions godoc
+// @Summary Get the edit history of a comment.
+// @Description Get every revision of a comment with the fields changed compared to the previous revision.
+// @Tags Comment
+// @Param id path string true "Comment ID"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/comment/{id}/revisions [get]
+func GetCommentRevisions(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var comment models.Comment
+	if err := db.Where("id = ?", c.Param("id")).First(&comment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+
+	var revisions []models.CommentRevision
+	if err := db.Where("comment_id = ?", comment.ID).Order("revision").Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve revisions"})
+		return
+	}
+
+	response := make([]dto.CommentRevision, len(revisions))
+	for i, revision := range revisions {
+		changes := []models.FieldChange{}
+		if i > 0 {
+			var err error
+			changes, err = models.DiffSnapshots(revisions[i-1].Snapshot, revision.Snapshot)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve revisions"})
+				return
+			}
+		}
+		response[i] = dto.NewCommentRevision(revision, comment.UserID, changes)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"comment_id": comment.ID, "edited": comment.Edited, "revisions": response})
+}
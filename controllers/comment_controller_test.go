@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils/token"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRatingsAreOneToFive(t *testing.T) {
+	r, db := newTestRouter(t)
+	alice := models.User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	db.Create(&alice)
+	api := r.Group("/api", func(c *gin.Context) { c.Set(token.UserIDKey, alice.ID) })
+	api.POST("/comment", CreateComment)
+
+	for _, rating := range []int{0, -1, 6, 1000} {
+		w := httptest.NewRecorder()
+		body := fmt.Sprintf(`{"content":"Solid machine","rating":%d,"laptop_id":1}`, rating)
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/comment", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("rating %d = %d %s, want %d", rating, w.Code, w.Body.String(), http.StatusBadRequest)
+		}
+	}
+
+	var comments int64
+	db.Model(&models.Comment{}).Count(&comments)
+	if comments != 0 {
+		t.Fatalf("%d comments were saved", comments)
+	}
+}
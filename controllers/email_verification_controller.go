@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils/token"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// VerifyEmail godoc
+// @Summary Verify an email address.
+// @Description Confirm the email address of an account with the token from the verification link.
+// @Tags Auth
+// @Param token query string true "verification token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /verify-email [get]
+func VerifyEmail(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	user, err := models.VerifyEmail(db, c.Query("token"))
+	if err == models.ErrInvalidVerification {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email address"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email address verified", "email": user.Email, "email_verified_at": user.EmailVerifiedAt})
+}
+
+// ResendVerificationEmail godoc
+// @Summary Resend the verification email.
+// @Description Send a new verification link to the logged-in user's email address. Limited to one request per minute.
+// @Tags Auth
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/resend-verification [post]
+func ResendVerificationEmail(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	switch err := models.SendVerificationEmail(db, &user); err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+	case models.ErrAlreadyVerified:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case models.ErrVerificationThrottled:
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+	}
+}
@@ -1,9 +1,13 @@
 package controllers
 
 import (
+	"final-project-rest-api/dto"
+	"final-project-rest-api/events"
 	"final-project-rest-api/models"
+	"final-project-rest-api/utils/token"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -46,18 +50,28 @@ func CreateLaptop(c *gin.Context) {
 		CategoryID:  input.CategoryID,
 	}
 
-	if err := db.Create(&laptop).Error; err != nil {
+	actorID, _ := token.ExtractTokenID(c)
+	err := events.Transaction(db, func(tx *events.Tx) error {
+		if err := tx.Create(&laptop).Error; err != nil {
+			return err
+		}
+		return tx.Publish(events.LaptopCreated{Laptop: laptop, ActorID: actorID})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create laptop"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Laptop created successfully", "laptop": laptop})
+	c.JSON(http.StatusOK, gin.H{"message": "Laptop created successfully", "laptop": dto.NewLaptop(laptop)})
 }
 
 // GetLaptops godoc
 // @Summary Get all laptops.
-// @Description Get a list of all laptops.
+// @Description Get a list of all laptops. Laptops can be sorted by an aspect average with sort=<aspect>
+// @Description and filtered with min_<aspect>=<score>, e.g. ?sort=display&order=desc&min_battery=4.
 // @Tags Laptop
+// @Param sort query string false "Aspect to sort by"
+// @Param order query string false "Sort order, asc or desc (default desc)"
 // @Produce json
 // @Success 200 {object} map[string]interface{}
 // @Router /api/laptops [get]
@@ -65,19 +79,52 @@ func GetLaptops(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	var laptops []models.Laptop
 
-	if err := db.Preload("Brand").Preload("Category").Preload("Comments").Find(&laptops).Error; err != nil {
+	query := db.Model(&models.Laptop{}).Select("laptops.*")
+
+	for key, values := range c.Request.URL.Query() {
+		aspect, ok := strings.CutPrefix(key, "min_")
+		if !ok {
+			continue
+		}
+		if !models.IsValidAspectName(aspect) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid aspect " + aspect})
+			return
+		}
+		min, err := strconv.ParseFloat(values[0], 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid minimum score for " + aspect})
+			return
+		}
+		query = models.FilterLaptopsByAspect(query, db, aspect, min)
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		if !models.IsValidAspectName(sort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort aspect"})
+			return
+		}
+		query = models.SortLaptopsByAspect(query, db, sort, c.DefaultQuery("order", "desc") != "asc")
+	}
+
+	if err := query.Preload("Brand").Preload("Category").Preload("Comments.AspectRatings").Find(&laptops).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve laptops"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"laptops": laptops})
+	if err := models.LoadLaptopStats(db, laptops); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve laptop ratings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"laptops": dto.NewLaptops(laptops)})
 }
 
 // GetLaptopById godoc
 // @Summary Get a laptop.
-// @Description Get a laptop by ID.
+// @Description Get a laptop by ID. Use verified=true to only include reviews from verified owners.
 // @Tags Laptop
 // @Param id path string true "Laptop ID"
+// @Param verified query bool false "Only reviews from verified owners"
 // @Produce json
 // @Success 200 {object} map[string]interface{}
 // @Router /api/laptop/{id} [get]
@@ -91,12 +138,24 @@ func GetLaptopById(c *gin.Context) {
 		return
 	}
 
-	if err := db.Preload("Brand").Preload("Category").Preload("Comments.User").Where("id = ?", id).First(&laptop).Error; err != nil {
+	query := db.Preload("Brand").Preload("Category")
+	if c.Query("verified") == "true" {
+		query = query.Preload("Comments", models.OnlyVerifiedOwners)
+	}
+
+	if err := query.Preload("Comments.AspectRatings").Where("id = ?", id).First(&laptop).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Laptop not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"laptop": laptop})
+	laptops := []models.Laptop{laptop}
+	if err := models.LoadLaptopStats(db, laptops); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve laptop ratings"})
+		return
+	}
+	laptop = laptops[0]
+
+	c.JSON(http.StatusOK, gin.H{"laptop": dto.NewLaptop(laptop)})
 }
 
 // UpdateLaptop godoc
@@ -132,6 +191,8 @@ func UpdateLaptop(c *gin.Context) {
 		return
 	}
 
+	previous := laptop
+
 	laptop.Name = input.Name
 	laptop.ReleaseYear = input.ReleaseYear
 	laptop.Spec = input.Spec
@@ -139,12 +200,19 @@ func UpdateLaptop(c *gin.Context) {
 	laptop.BrandID = input.BrandID
 	laptop.CategoryID = input.CategoryID
 
-	if err := db.Save(&laptop).Error; err != nil {
+	actorID, _ := token.ExtractTokenID(c)
+	err = events.Transaction(db, func(tx *events.Tx) error {
+		if err := tx.Save(&laptop).Error; err != nil {
+			return err
+		}
+		return tx.Publish(events.LaptopUpdated{Laptop: laptop, Previous: previous, ActorID: actorID})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update laptop"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Laptop updated successfully", "laptop": laptop})
+	c.JSON(http.StatusOK, gin.H{"message": "Laptop updated successfully", "laptop": dto.NewLaptop(laptop)})
 }
 
 // DeleteLaptop godoc
@@ -172,7 +240,14 @@ func DeleteLaptop(c *gin.Context) {
 		return
 	}
 
-	if err := db.Delete(&laptop).Error; err != nil {
+	actorID, _ := token.ExtractTokenID(c)
+	err = events.Transaction(db, func(tx *events.Tx) error {
+		if err := tx.Delete(&laptop).Error; err != nil {
+			return err
+		}
+		return tx.Publish(events.LaptopDeleted{Laptop: laptop, ActorID: actorID})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete laptop"})
 		return
 	}
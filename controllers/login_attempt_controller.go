@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"final-project-rest-api/dto"
+	"final-project-rest-api/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetLoginAttempts godoc
+// @Summary Get login attempts.
+// @Description Get the latest login attempts, optionally filtered by username, user ID, IP or outcome. Admin only.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param username query string false "username tried"
+// @Param user_id query string false "user ID"
+// @Param ip query string false "client IP"
+// @Param success query bool false "only successful or only failed attempts"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/login-attempts [get]
+func GetLoginAttempts(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	query := db.Model(&models.LoginAttempt{})
+	if username := c.Query("username"); username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("ip = ?", ip)
+	}
+	if success := c.Query("success"); success != "" {
+		query = query.Where("success = ?", success == "true")
+	}
+
+	var attempts []models.LoginAttempt
+	if err := query.Order("id DESC").Limit(100).Find(&attempts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve login attempts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"login_attempts": dto.NewLoginAttempts(attempts)})
+}
+
+// UnlockUser godoc
+// @Summary Unlock a user.
+// @Description Lift the login lockout of a user and forget their failed logins. Admin only.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "User ID"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/users/{id}/unlock [post]
+func UnlockUser(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	adminID, user, ok := adminTarget(c, db, true)
+	if !ok {
+		return
+	}
+
+	if err := models.UnlockUser(db, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+	logAdminAction(c, db, models.AdminAction{AdminID: adminID, TargetUserID: user.ID, Action: models.AdminActionUnlock})
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"final-project-rest-api/dto"
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils/token"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const maxNotificationsPerPage = 100
+
+// GetMyNotifications godoc
+// @Summary Get my notifications.
+// @Description Get the inbox of the logged-in user, newest first.
+// @Tags Notification
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param unread query bool false "only unread notifications"
+// @Param page query int false "page number, starting at 1"
+// @Param per_page query int false "notifications per page, up to 100 (default 20)"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/notifications [get]
+func GetMyNotifications(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive number"})
+		return
+	}
+	perPage, err := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if err != nil || perPage < 1 || perPage > maxNotificationsPerPage {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "per_page must be between 1 and 100"})
+		return
+	}
+
+	query := db.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notifications"})
+		return
+	}
+
+	var notifications []models.Notification
+	if err := query.Order("created_at DESC, id DESC").Limit(perPage).Offset((page - 1) * perPage).Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"notifications": dto.NewNotifications(notifications), "total": total, "page": page, "per_page": perPage})
+}
+
+// GetUnreadNotificationCount godoc
+// @Summary Count my unread notifications.
+// @Description Get how many notifications of the logged-in user are unread.
+// @Tags Notification
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/notifications/unread-count [get]
+func GetUnreadNotificationCount(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var count int64
+	if err := db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread": count})
+}
+
+// MarkNotificationRead godoc
+// @Summary Mark a notification as read.
+// @Description Mark a notification of the logged-in user as read.
+// @Tags Notification
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Notification ID"
+// @Produce json
+// @Success 200 {object} dto.Notification
+// @Router /api/me/notifications/{id}/read [put]
+func MarkNotificationRead(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var notification models.Notification
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&notification).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		if err := db.Model(&notification).Update("read_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, dto.NewNotification(notification))
+}
+
+// MarkAllNotificationsRead godoc
+// @Summary Mark all notifications as read.
+// @Description Mark every unread notification of the logged-in user as read.
+// @Tags Notification
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/notifications/read-all [put]
+func MarkAllNotificationsRead(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	result := db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Update("read_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notifications marked as read", "updated": result.RowsAffected})
+}
+
+// GetNotificationPreferences godoc
+// @Summary Get my notification preferences.
+// @Description Get which activity notifications the logged-in user receives. These are the user's preferences.
+// @Tags Notification
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]bool
+// @Router /api/me/notification-preferences [get]
+func GetNotificationPreferences(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	preferences, err := models.NotificationPreferences(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notification preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+// UpdateNotificationPreferences godoc
+// @Summary Update my notification preferences.
+// @Description Turn activity notification types on or off, e.g. {"activity.new_review": false}. Types left out keep their setting.
+// @Tags Notification
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param Body body map[string]bool true "enabled state per notification type"
+// @Produce json
+// @Success 200 {object} map[string]bool
+// @Router /api/me/notification-preferences [put]
+func UpdateNotificationPreferences(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input map[string]bool
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for notificationType := range input {
+		if !models.IsNotificationType(notificationType) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown notification type " + notificationType})
+			return
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for notificationType, enabled := range input {
+			preference := models.NotificationPreference{UserID: userID, Type: notificationType}
+			if err := tx.Where(&preference).FirstOrInit(&preference).Error; err != nil {
+				return err
+			}
+			preference.Enabled = enabled
+			if err := tx.Save(&preference).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification preferences"})
+		return
+	}
+
+	preferences, err := models.NotificationPreferences(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notification preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"final-project-rest-api/dto"
+	"final-project-rest-api/events"
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils"
+	"final-project-rest-api/utils/oidc"
+	"final-project-rest-api/utils/token"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const oidcStateCookie = "oidc_state"
+
+func oidcRedirectURL(provider string) string {
+	return models.AppURL() + "/auth/oidc/" + provider + "/callback"
+}
+
+// oidcRedirectAllowed reports whether the login may send its result to target, which must
+// be on one of the comma separated URLs in OIDC_ALLOWED_REDIRECTS: same scheme and host,
+// under the same path.
+func oidcRedirectAllowed(target string) bool {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Fragment != "" {
+		return false
+	}
+	for _, entry := range strings.Split(utils.Getenv("OIDC_ALLOWED_REDIRECTS", ""), ",") {
+		allowed, err := url.Parse(strings.TrimSpace(entry))
+		if err != nil || allowed.Host == "" {
+			continue
+		}
+		if u.Scheme == allowed.Scheme && u.Host == allowed.Host && strings.HasPrefix(u.Path, allowed.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// oidcResult answers the callback: by redirecting to the page the login started from with
+// the result in the URL fragment, or as JSON when there is no such page.
+func oidcResult(c *gin.Context, redirectTo string, status int, result gin.H) {
+	if redirectTo == "" {
+		c.JSON(status, result)
+		return
+	}
+	fragment := url.Values{}
+	for k, v := range result {
+		if s, ok := v.(string); ok {
+			fragment.Set(k, s)
+		} else if b, ok := v.(bool); ok && b {
+			fragment.Set(k, "true")
+		}
+	}
+	c.Redirect(http.StatusFound, redirectTo+"#"+fragment.Encode())
+}
+
+// GetOIDCProviders godoc
+// @Summary Get social login providers.
+// @Description Get the OpenID Connect providers users can log in with.
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/oidc [get]
+func GetOIDCProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": oidc.ProviderNames()})
+}
+
+// StartOIDCLogin godoc
+// @Summary Log in with a provider.
+// @Description Redirects the browser to the OpenID Connect provider to log in. Afterwards the provider sends it back to the callback,
+// @Description which answers with our JWT token, or a two-factor challenge, like /login. With redirect_to, the callback redirects
+// @Description there instead with the result in the URL fragment; it must be allowed by OIDC_ALLOWED_REDIRECTS.
+// @Description Users are linked to an existing account with the same verified email address, or get a new account.
+// @Tags Auth
+// @Param provider path string true "provider name"
+// @Param redirect_to query string false "page to send the result to"
+// @Param login_hint query string false "email or username to suggest to the provider"
+// @Success 302
+// @Router /auth/oidc/{provider}/login [get]
+func StartOIDCLogin(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	provider, ok := oidc.Lookup(c.Param("provider"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown login provider"})
+		return
+	}
+
+	redirectTo := c.Query("redirect_to")
+	if redirectTo != "" && !oidcRedirectAllowed(redirectTo) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "redirect_to is not allowed"})
+		return
+	}
+
+	login, state, err := models.BeginOIDCLogin(db, provider.Name, redirectTo)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	authURL, err := provider.AuthCodeURL(oidcRedirectURL(provider.Name), state, login.Nonce, login.CodeVerifier, c.Query("login_hint"))
+	if err != nil {
+		log.Printf("Failed to reach login provider %s: %v", provider.Name, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Login provider is unavailable"})
+		return
+	}
+
+	// ties the callback to this browser, so nobody can complete their own login in someone else's
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, 10*60, "/auth/oidc", "", secure, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback godoc
+// @Summary Complete a provider login.
+// @Description Where the OpenID Connect provider sends the browser back to after logging in.
+// @Tags Auth
+// @Param provider path string true "provider name"
+// @Param code query string false "authorization code"
+// @Param state query string true "login state"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/oidc/{provider}/callback [get]
+func OIDCCallback(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	provider, ok := oidc.Lookup(c.Param("provider"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown login provider"})
+		return
+	}
+
+	state := c.Query("state")
+	cookie, _ := c.Cookie(oidcStateCookie)
+	c.SetCookie(oidcStateCookie, "", -1, "/auth/oidc", "", false, true)
+	if state == "" || cookie != state {
+		c.JSON(http.StatusBadRequest, gin.H{"error": models.ErrInvalidOIDCState.Error()})
+		return
+	}
+	login, err := models.ConsumeOIDCLogin(db, provider.Name, state)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": models.ErrInvalidOIDCState.Error()})
+		return
+	}
+
+	if providerError := c.Query("error"); providerError != "" {
+		oidcResult(c, login.RedirectTo, http.StatusUnauthorized, gin.H{"error": "Login was cancelled or refused: " + providerError})
+		return
+	}
+	claims, err := provider.Exchange(oidcRedirectURL(provider.Name), c.Query("code"), login.CodeVerifier, login.Nonce)
+	if err != nil {
+		log.Printf("Login with %s failed: %v", provider.Name, err)
+		oidcResult(c, login.RedirectTo, http.StatusUnauthorized, gin.H{"error": "Login with the provider failed"})
+		return
+	}
+
+	var user models.User
+	err = events.Transaction(db, func(tx *events.Tx) error {
+		var created bool
+		var err error
+		user, created, err = models.LinkOIDCIdentity(tx.DB, provider.Name, claims)
+		if err != nil || !created {
+			return err
+		}
+		return tx.Publish(events.UserRegistered{UserID: user.ID, Username: user.Username})
+	})
+	switch err {
+	case nil:
+	case models.ErrOIDCEmailMissing:
+		oidcResult(c, login.RedirectTo, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case models.ErrOIDCAccountNotLinked:
+		oidcResult(c, login.RedirectTo, http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	default:
+		oidcResult(c, login.RedirectTo, http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+		return
+	}
+
+	if err := user.LoginAllowed(); err != nil {
+		recordLoginAttempt(db, user.Username, c.ClientIP(), loginRefusal(err))
+		oidcResult(c, login.RedirectTo, http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	if user.TwoFactorEnabled() {
+		challenge, err := models.IssueLoginChallenge(db, user)
+		if err != nil {
+			oidcResult(c, login.RedirectTo, http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor login"})
+			return
+		}
+		recordLoginAttempt(db, user.Username, c.ClientIP(), models.LoginTwoFactorRequired)
+		oidcResult(c, login.RedirectTo, http.StatusOK, gin.H{
+			"message":             "two-factor authentication required",
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+		})
+		return
+	}
+
+	jwtToken, err := startSession(c, db, user)
+	if err != nil {
+		oidcResult(c, login.RedirectTo, http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	recordLoginAttempt(db, user.Username, c.ClientIP(), models.LoginSucceeded)
+
+	oidcResult(c, login.RedirectTo, http.StatusOK, gin.H{"message": "login success", "token": jwtToken})
+}
+
+// GetMyIdentities godoc
+// @Summary Get my linked logins.
+// @Description Get the provider accounts, such as Google, linked to the logged-in user.
+// @Tags Auth
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/identities [get]
+func GetMyIdentities(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var identities []models.UserIdentity
+	if err := db.Where("user_id = ?", userID).Find(&identities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve linked logins"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"identities": dto.NewIdentities(identities)})
+}
+
+// UnlinkIdentity godoc
+// @Summary Unlink a login.
+// @Description Unlink a provider account from the logged-in user. Accounts created through a provider need a password, set with a password reset, to log in afterwards.
+// @Tags Auth
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "identity ID"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/identities/{id} [delete]
+func UnlinkIdentity(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	res := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).Delete(&models.UserIdentity{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink login"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Linked login not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Login unlinked"})
+}
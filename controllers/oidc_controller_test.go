@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"encoding/json"
+	"final-project-rest-api/middleware"
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils/oidc/mock"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	testDBs      int64
+	mockOnce     sync.Once
+	mockProvider *mock.Provider
+)
+
+// startMockProvider serves the mock provider as the "mock" login provider. The providers
+// are read from the environment once, so every test shares it.
+func startMockProvider(t *testing.T) *mock.Provider {
+	t.Helper()
+	mockOnce.Do(func() {
+		var handler http.Handler = http.NotFoundHandler()
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler.ServeHTTP(w, r)
+		}))
+		p, err := mock.New(srv.URL, "test-client", "test-secret")
+		if err != nil {
+			panic(err)
+		}
+		handler = p
+		mockProvider = p
+
+		os.Setenv("OIDC_PROVIDERS", "mock")
+		os.Setenv("OIDC_MOCK_ISSUER", srv.URL)
+		os.Setenv("OIDC_MOCK_CLIENT_ID", "test-client")
+		os.Setenv("OIDC_MOCK_CLIENT_SECRET", "test-secret")
+		os.Setenv("APP_URL", "http://app.test")
+	})
+	return mockProvider
+}
+
+func newTestRouter(t *testing.T) (*gin.Engine, *gorm.DB) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:controllers%d?mode=memory&cache=shared", atomic.AddInt64(&testDBs, 1))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Profile{}, &models.Comment{}, &models.OutboxEvent{}, &models.Webhook{}, &models.WebhookDelivery{},
+		&models.LoginAttempt{}, &models.LoginChallenge{}, &models.UserIdentity{}, &models.OIDCLoginState{}, &models.Session{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.Use(middleware.DatabaseMiddleware(db))
+	r.GET("/auth/oidc/:provider/login", StartOIDCLogin)
+	r.GET("/auth/oidc/:provider/callback", OIDCCallback)
+	return r, db
+}
+
+type oidcLogin struct {
+	authURL string
+	cookie  *http.Cookie
+}
+
+// startLogin starts a login with the mock provider and returns where it sends the browser.
+func startLogin(t *testing.T, r *gin.Engine, loginHint string) oidcLogin {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/oidc/mock/login?login_hint="+url.QueryEscape(loginHint), nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("login = %d %s", w.Code, w.Body.String())
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("login set %d cookies", len(cookies))
+	}
+	return oidcLogin{authURL: w.Header().Get("Location"), cookie: cookies[0]}
+}
+
+// authorize approves the login at the provider and returns the callback query it
+// redirects back with.
+func authorize(t *testing.T, authURL string) url.Values {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize = %s", resp.Status)
+	}
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return callback.Query()
+}
+
+func callback(t *testing.T, r *gin.Engine, query url.Values, cookie *http.Cookie) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/mock/callback?"+query.Encode(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var body map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w.Code, body
+}
+
+func TestOIDCCallbackLogsIn(t *testing.T) {
+	startMockProvider(t)
+	r, db := newTestRouter(t)
+
+	login := startLogin(t, r, "carol@example.com")
+	status, body := callback(t, r, authorize(t, login.authURL), login.cookie)
+	if status != http.StatusOK || body["token"] == nil {
+		t.Fatalf("callback = %d %v", status, body)
+	}
+
+	var identity models.UserIdentity
+	if err := db.Where("provider = ? AND subject = ?", "mock", "mock|carol@example.com").First(&identity).Error; err != nil {
+		t.Fatalf("no identity was linked: %v", err)
+	}
+}
+
+func TestOIDCCallbackRejectsStateMismatch(t *testing.T) {
+	startMockProvider(t)
+	r, _ := newTestRouter(t)
+
+	login := startLogin(t, r, "carol@example.com")
+	other := startLogin(t, r, "carol@example.com")
+	query := authorize(t, login.authURL)
+
+	// the state of a login started in another browser
+	if status, body := callback(t, r, query, other.cookie); status != http.StatusBadRequest || body["error"] != models.ErrInvalidOIDCState.Error() {
+		t.Fatalf("callback with another login's cookie = %d %v", status, body)
+	}
+	if status, body := callback(t, r, query, nil); status != http.StatusBadRequest || body["error"] != models.ErrInvalidOIDCState.Error() {
+		t.Fatalf("callback without the cookie = %d %v", status, body)
+	}
+}
+
+func TestOIDCCallbackRejectsNonceMismatch(t *testing.T) {
+	startMockProvider(t)
+	r, db := newTestRouter(t)
+
+	login := startLogin(t, r, "carol@example.com")
+	authURL, err := url.Parse(login.authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// e.g. an ID token replayed from another login
+	params := authURL.Query()
+	params.Set("nonce", "another-nonce")
+	authURL.RawQuery = params.Encode()
+
+	status, body := callback(t, r, authorize(t, authURL.String()), login.cookie)
+	if status != http.StatusUnauthorized || body["token"] != nil {
+		t.Fatalf("callback with another nonce = %d %v", status, body)
+	}
+	var count int64
+	db.Model(&models.UserIdentity{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("%d identities were linked", count)
+	}
+}
+
+func TestOIDCCallbackDoesNotLinkUnverifiedEmail(t *testing.T) {
+	startMockProvider(t)
+	r, db := newTestRouter(t)
+
+	// someone registered the address without verifying it
+	squatter := models.User{Username: "squatter", Email: "dave@example.com", Password: "password1"}
+	if _, err := squatter.SaveUser(db); err != nil {
+		t.Fatal(err)
+	}
+
+	login := startLogin(t, r, "dave@example.com")
+	status, body := callback(t, r, authorize(t, login.authURL), login.cookie)
+	if status != http.StatusConflict || body["error"] != models.ErrOIDCAccountNotLinked.Error() {
+		t.Fatalf("callback for an unverified account = %d %v", status, body)
+	}
+
+	// and the provider not vouching for the address doesn't link a verified account either
+	now := time.Now()
+	db.Model(&squatter).Update("email_verified_at", now)
+	login = startLogin(t, r, "unverified:dave@example.com")
+	status, body = callback(t, r, authorize(t, login.authURL), login.cookie)
+	if status != http.StatusBadRequest || body["error"] != models.ErrOIDCEmailMissing.Error() {
+		t.Fatalf("callback with an unverified provider email = %d %v", status, body)
+	}
+
+	var count int64
+	db.Model(&models.UserIdentity{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("%d identities were linked", count)
+	}
+}
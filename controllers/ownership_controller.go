@@ -0,0 +1,340 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"final-project-rest-api/dto"
+	"final-project-rest-api/events"
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils"
+	"final-project-rest-api/utils/token"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const maxReceiptSize = 5 << 20
+
+var receiptExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".pdf": true}
+
+type OwnershipInput struct {
+	LaptopID uint `form:"laptop_id" json:"laptop_id" binding:"required"`
+}
+
+type OwnershipReviewInput struct {
+	Note string `json:"note"`
+}
+
+// CreateOwnershipClaim godoc
+// @Summary Claim ownership of a laptop.
+// @Description Register a laptop the user owns, optionally with a receipt (jpg, png or pdf up to 5MB) for verification.
+// @Tags Ownership
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Accept multipart/form-data
+// @Param laptop_id formData int true "Laptop ID"
+// @Param receipt formData file false "Purchase receipt"
+// @Produce json
+// @Success 201 {object} dto.OwnershipClaim
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 404 {object} map[string]interface{} "Laptop not found"
+// @Failure 409 {object} map[string]interface{} "Ownership already claimed"
+// @Router /api/ownership [post]
+func CreateOwnershipClaim(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input OwnershipInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var laptop models.Laptop
+	if err := db.Where("id = ?", input.LaptopID).First(&laptop).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Laptop not found"})
+		return
+	}
+
+	var claim models.OwnershipClaim
+	err = db.Where("user_id = ? AND laptop_id = ?", userID, input.LaptopID).First(&claim).Error
+	if err == nil && claim.Status != models.OwnershipRejected {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ownership already claimed for this laptop"})
+		return
+	} else if err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	receiptPath, err := saveReceipt(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claim.UserID = userID
+	claim.LaptopID = input.LaptopID
+	claim.Status = models.OwnershipPending
+	claim.Note = ""
+	claim.ReviewedByID = nil
+	claim.ReviewedAt = nil
+	replaced := ""
+	if receiptPath != "" {
+		replaced = claim.ReceiptPath
+		claim.ReceiptPath = receiptPath
+		claim.HasReceipt = true
+	}
+
+	if err := db.Save(&claim).Error; err != nil {
+		models.RemoveReceipt(receiptPath)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create ownership claim"})
+		return
+	}
+	// a resubmitted claim keeps its old receipt unless a new one was uploaded
+	models.RemoveReceipt(replaced)
+
+	c.JSON(http.StatusCreated, dto.NewOwnershipClaim(claim))
+}
+
+// saveReceipt stores the optional receipt upload and returns its path.
+func saveReceipt(c *gin.Context) (string, error) {
+	file, err := c.FormFile("receipt")
+	if err == http.ErrMissingFile || err == http.ErrNotMultipart {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if !receiptExtensions[ext] {
+		return "", errors.New("receipt must be a jpg, png or pdf file")
+	}
+	if file.Size > maxReceiptSize {
+		return "", errors.New("receipt must not be larger than 5MB")
+	}
+
+	dir := filepath.Join(utils.Getenv("UPLOAD_DIR", "uploads"), "receipts")
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", err
+	}
+
+	name := make([]byte, 16)
+	if _, err := rand.Read(name); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, hex.EncodeToString(name)+ext)
+	if err := c.SaveUploadedFile(file, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// GetMyOwnershipClaims godoc
+// @Summary Get my ownership claims.
+// @Description Get the laptops the logged-in user claimed to own.
+// @Tags Ownership
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/ownerships [get]
+func GetMyOwnershipClaims(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var claims []models.OwnershipClaim
+	if err := db.Preload("Laptop").Where("user_id = ?", userID).Find(&claims).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve ownership claims"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ownerships": dto.NewOwnershipClaims(claims)})
+}
+
+// GetOwnershipReceipt godoc
+// @Summary Download an ownership receipt.
+// @Description Download the receipt of an ownership claim. Only the owner, admins and editors can access it.
+// @Tags Ownership
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Ownership claim ID"
+// @Success 200 {file} file
+// @Router /api/ownership/{id}/receipt [get]
+func GetOwnershipReceipt(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var claim models.OwnershipClaim
+	if err := db.Where("id = ?", c.Param("id")).First(&claim).Error; err != nil || !claim.HasReceipt {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
+		return
+	}
+
+	if claim.UserID != userID && !user.HasRole(models.RoleAdmin, models.RoleEditor) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access this resource"})
+		return
+	}
+
+	c.File(claim.ReceiptPath)
+}
+
+// DeleteOwnershipClaim godoc
+// @Summary Withdraw an ownership claim.
+// @Description Withdraw an ownership claim of the logged-in user.
+// @Tags Ownership
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Ownership claim ID"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/ownership/{id} [delete]
+func DeleteOwnershipClaim(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var claim models.OwnershipClaim
+	if err := db.Where("id = ?", c.Param("id")).First(&claim).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ownership claim not found"})
+		return
+	}
+
+	if claim.UserID != userID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := db.Delete(&claim).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete ownership claim"})
+		return
+	}
+	models.RemoveReceipt(claim.ReceiptPath)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ownership claim deleted successfully"})
+}
+
+// GetOwnershipClaims godoc
+// @Summary Get ownership claims to review.
+// @Description Get all ownership claims, optionally filtered by status. Admin and editor only.
+// @Tags Ownership
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param status query string false "pending, verified or rejected"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/ownerships [get]
+func GetOwnershipClaims(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	query := db.Preload("Laptop")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var claims []models.OwnershipClaim
+	if err := query.Order("created_at").Find(&claims).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve ownership claims"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ownerships": dto.NewOwnershipClaims(claims)})
+}
+
+// VerifyOwnershipClaim godoc
+// @Summary Verify an ownership claim.
+// @Description Mark an ownership claim as verified. Admin and editor only.
+// @Tags Ownership
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Ownership claim ID"
+// @Param Body body OwnershipReviewInput false "optional note for the owner"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/ownership/{id}/verify [put]
+func VerifyOwnershipClaim(c *gin.Context) {
+	reviewOwnershipClaim(c, models.OwnershipVerified)
+}
+
+// RejectOwnershipClaim godoc
+// @Summary Reject an ownership claim.
+// @Description Mark an ownership claim as rejected. Admin and editor only.
+// @Tags Ownership
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Ownership claim ID"
+// @Param Body body OwnershipReviewInput false "optional reason for the owner"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/ownership/{id}/reject [put]
+func RejectOwnershipClaim(c *gin.Context) {
+	reviewOwnershipClaim(c, models.OwnershipRejected)
+}
+
+func reviewOwnershipClaim(c *gin.Context, status string) {
+	db := c.MustGet("db").(*gorm.DB)
+	reviewerID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input OwnershipReviewInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var claim models.OwnershipClaim
+	if err := db.Where("id = ?", c.Param("id")).First(&claim).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ownership claim not found"})
+		return
+	}
+
+	now := time.Now()
+	claim.Status = status
+	claim.Note = input.Note
+	claim.ReviewedByID = &reviewerID
+	claim.ReviewedAt = &now
+
+	err = events.Transaction(db, func(tx *events.Tx) error {
+		if err := tx.Save(&claim).Error; err != nil {
+			return err
+		}
+		return tx.Publish(events.OwnershipReviewed{Claim: claim, ReviewerID: reviewerID})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ownership claim"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ownership claim " + status, "ownership": dto.NewOwnershipClaim(claim)})
+}
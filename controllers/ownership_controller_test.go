@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils/token"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestReplacedReceiptsAreRemoved(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("UPLOAD_DIR", dir)
+	r, db := newTestRouter(t)
+	if err := db.AutoMigrate(&models.Laptop{}, &models.OwnershipClaim{}); err != nil {
+		t.Fatal(err)
+	}
+	alice := models.User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	db.Create(&alice)
+	db.Create(&models.Laptop{ID: 1, BrandID: 1, CategoryID: 1, Name: "Acme Book"})
+	db.Create(&models.Laptop{ID: 2, BrandID: 1, CategoryID: 1, Name: "Acme Book Pro"})
+
+	api := r.Group("/api", func(c *gin.Context) { c.Set(token.UserIDKey, alice.ID) })
+	api.POST("/ownership", CreateOwnershipClaim)
+	claim := func(laptopID string) int {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("laptop_id", laptopID)
+		receipt, _ := form.CreateFormFile("receipt", "receipt.pdf")
+		receipt.Write([]byte("%PDF-1.4"))
+		form.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/ownership", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	receipts := func() []string {
+		files, _ := filepath.Glob(filepath.Join(dir, "receipts", "*"))
+		return files
+	}
+
+	if code := claim("1"); code != http.StatusCreated {
+		t.Fatalf("claim = %d", code)
+	}
+	db.Model(&models.OwnershipClaim{}).Where("user_id = ?", alice.ID).Update("status", models.OwnershipRejected)
+	if code := claim("1"); code != http.StatusCreated {
+		t.Fatalf("resubmitted claim = %d", code)
+	}
+	var saved models.OwnershipClaim
+	db.Where("user_id = ?", alice.ID).First(&saved)
+	if files := receipts(); len(files) != 1 || files[0] != saved.ReceiptPath {
+		t.Fatalf("receipts after resubmitting %v, want only %s", files, saved.ReceiptPath)
+	}
+
+	db.Callback().Create().Before("gorm:create").Register("fail_claims", func(tx *gorm.DB) {
+		tx.AddError(errors.New("database is down"))
+	})
+	if code := claim("2"); code != http.StatusInternalServerError {
+		t.Fatalf("claim with a failing database = %d", code)
+	}
+	if files := receipts(); len(files) != 1 {
+		t.Fatalf("receipts after a failed claim %v", files)
+	}
+	if _, err := os.Stat(saved.ReceiptPath); err != nil {
+		t.Fatal(err)
+	}
+}
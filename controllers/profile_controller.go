@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"final-project-rest-api/dto"
 	"final-project-rest-api/models"
 	"final-project-rest-api/utils/token"
 	"net/http"
@@ -10,19 +11,32 @@ import (
 )
 
 type ProfileInput struct {
-	Fullname string `json:"fullname" binding:"required"`
-	Bio      string `json:"bio" binding:"required"`
+	Fullname  string `json:"fullname" binding:"required"`
+	Bio       string `json:"bio" binding:"required"`
+	AvatarURL string `json:"avatar_url" binding:"omitempty,http_url,max=500"`
+}
+
+// ProfilePatchInput changes only the fields it contains.
+type ProfilePatchInput struct {
+	Fullname  *string `json:"fullname" binding:"omitempty,min=1"`
+	Bio       *string `json:"bio"`
+	AvatarURL *string `json:"avatar_url" binding:"omitempty,http_url,max=500"`
+}
+
+type AvatarInput struct {
+	AvatarURL string `json:"avatar_url" binding:"required,http_url,max=500" example:"https://example.com/avatar.png"`
 }
 
 // CreateProfile godoc
 // @Summary Create a new profile.
-// @Description Create a new profile for a user.
+// @Description Create a new profile for the logged-in user. Prefer PUT /api/me/profile.
 // @Tags Profile
+// @Deprecated
 // @Security ApiKeyAuth
 // @Param Authorization header string true "Bearer token"
 // @Param Body body ProfileInput true "the body to create a profile"
 // @Produce json
-// @Success 201 {object} models.Profile
+// @Success 201 {object} dto.Profile
 // @Failure 400 {object} map[string]interface{} "Invalid request body"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 409 {object} map[string]interface{} "Profile already exists for this user"
@@ -53,9 +67,10 @@ func CreateProfile(c *gin.Context) {
 	}
 
 	profile := models.Profile{
-		UserID:   userID,
-		Fullname: input.Fullname,
-		Bio:      input.Bio,
+		UserID:    userID,
+		Fullname:  input.Fullname,
+		Bio:       input.Bio,
+		AvatarURL: input.AvatarURL,
 	}
 
 	if err := db.Create(&profile).Error; err != nil {
@@ -63,15 +78,15 @@ func CreateProfile(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, profile)
+	c.JSON(http.StatusCreated, dto.NewProfile(profile))
 }
 
 // GetProfile godoc
 // @Summary Get all profiles.
-// @Description Retrieve all profiles.
+// @Description Retrieve all profiles, without the fields their users hid.
 // @Tags Profile
 // @Produce json
-// @Success 200 {array} models.Profile
+// @Success 200 {array} dto.Profile
 // @Failure 500 {object} map[string]interface{} "Failed to retrieve profiles"
 // @Router /api/profiles [get]
 func GetProfile(c *gin.Context) {
@@ -83,23 +98,37 @@ func GetProfile(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"profiles": profiles})
+	if err := models.LoadProfileReputations(db, profiles); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve profiles"})
+		return
+	}
+
+	if err := models.RedactProfiles(db, profiles); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve profiles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"profiles": dto.NewProfiles(profiles)})
 }
 
 // UpdateProfile godoc
 // @Summary Update a profile.
-// @Description Update a profile by user ID.
+// @Description Update the profile of the user with the given ID, which must be the logged-in user. Prefer
+// @Description PUT /api/me/profile; admins edit other users' profiles through /api/admin/users/{id}/profile.
 // @Tags Profile
+// @Deprecated
 // @Security ApiKeyAuth
 // @Param Authorization header string true "Bearer token"
+// @Param id path string true "User ID"
 // @Param Body body ProfileInput true "the body to update a profile"
 // @Produce json
-// @Success 200 {object} models.Profile
+// @Success 200 {object} dto.Profile
 // @Failure 400 {object} map[string]interface{} "Invalid request body"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
-// @Failure 404 {object} map[string]interface{} "Profile not found"
+// @Failure 403 {object} map[string]interface{} "Not your profile"
+// @Failure 404 {object} map[string]interface{} "User or profile not found"
 // @Failure 500 {object} map[string]interface{} "Failed to update profile"
-// @Router /api/profile [put]
+// @Router /api/profile/{id} [put]
 func UpdateProfile(c *gin.Context) {
 	var input ProfileInput
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -114,6 +143,16 @@ func UpdateProfile(c *gin.Context) {
 	}
 
 	db := c.MustGet("db").(*gorm.DB)
+	var user models.User
+	if err := db.Select("id").First(&user, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.ID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only update your own profile"})
+		return
+	}
+
 	var profile models.Profile
 	if err := db.Where("user_id = ?", userID).First(&profile).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Profile not found"})
@@ -122,11 +161,312 @@ func UpdateProfile(c *gin.Context) {
 
 	profile.Fullname = input.Fullname
 	profile.Bio = input.Bio
+	profile.AvatarURL = input.AvatarURL
 
 	if err := db.Save(&profile).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
 		return
 	}
 
-	c.JSON(http.StatusOK, profile)
+	c.JSON(http.StatusOK, dto.NewProfile(profile))
+}
+
+// GetMyProfile godoc
+// @Summary Get my profile.
+// @Description Get the logged-in user's profile, including the fields hidden from their public page.
+// @Tags Profile
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} dto.Profile
+// @Failure 404 {object} map[string]interface{} "Profile not found"
+// @Router /api/me/profile [get]
+func GetMyProfile(c *gin.Context) {
+	if userID, ok := myUserID(c); ok {
+		getProfile(c, userID)
+	}
+}
+
+// PutMyProfile godoc
+// @Summary Create or replace my profile.
+// @Description Set the logged-in user's whole profile, creating it if they don't have one yet.
+// @Tags Profile
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param Body body ProfileInput true "the profile"
+// @Produce json
+// @Success 200 {object} dto.Profile
+// @Success 201 {object} dto.Profile
+// @Router /api/me/profile [put]
+func PutMyProfile(c *gin.Context) {
+	if userID, ok := myUserID(c); ok {
+		putProfile(c, userID)
+	}
+}
+
+// PatchMyProfile godoc
+// @Summary Update my profile.
+// @Description Change only the given fields of the logged-in user's profile.
+// @Tags Profile
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param Body body ProfilePatchInput true "the fields to change"
+// @Produce json
+// @Success 200 {object} dto.Profile
+// @Failure 404 {object} map[string]interface{} "Profile not found"
+// @Router /api/me/profile [patch]
+func PatchMyProfile(c *gin.Context) {
+	if userID, ok := myUserID(c); ok {
+		patchProfile(c, userID)
+	}
+}
+
+// DeleteMyProfile godoc
+// @Summary Delete my profile.
+// @Description Delete the logged-in user's profile. The account itself is kept.
+// @Tags Profile
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{} "Profile not found"
+// @Router /api/me/profile [delete]
+func DeleteMyProfile(c *gin.Context) {
+	if userID, ok := myUserID(c); ok {
+		deleteProfile(c, userID)
+	}
+}
+
+// UpdateMyAvatar godoc
+// @Summary Set my avatar.
+// @Description Set the image URL shown as the logged-in user's avatar.
+// @Tags Profile
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param Body body AvatarInput true "the avatar URL"
+// @Produce json
+// @Success 200 {object} dto.Profile
+// @Failure 404 {object} map[string]interface{} "Profile not found"
+// @Router /api/me/avatar [put]
+func UpdateMyAvatar(c *gin.Context) {
+	var input AvatarInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if userID, ok := myUserID(c); ok {
+		setAvatar(c, userID, input.AvatarURL)
+	}
+}
+
+// DeleteMyAvatar godoc
+// @Summary Remove my avatar.
+// @Description Remove the logged-in user's avatar.
+// @Tags Profile
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} dto.Profile
+// @Failure 404 {object} map[string]interface{} "Profile not found"
+// @Router /api/me/avatar [delete]
+func DeleteMyAvatar(c *gin.Context) {
+	if userID, ok := myUserID(c); ok {
+		setAvatar(c, userID, "")
+	}
+}
+
+// GetUserProfile godoc
+// @Summary Get a user's profile.
+// @Description Get any user's profile, including the fields hidden from their public page.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "User ID"
+// @Produce json
+// @Success 200 {object} dto.Profile
+// @Router /api/admin/users/{id}/profile [get]
+func GetUserProfile(c *gin.Context) {
+	if userID, ok := pathUserID(c); ok {
+		getProfile(c, userID)
+	}
+}
+
+// PutUserProfile godoc
+// @Summary Create or replace a user's profile.
+// @Description Set a user's whole profile, creating it if they don't have one yet.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "User ID"
+// @Param Body body ProfileInput true "the profile"
+// @Produce json
+// @Success 200 {object} dto.Profile
+// @Success 201 {object} dto.Profile
+// @Router /api/admin/users/{id}/profile [put]
+func PutUserProfile(c *gin.Context) {
+	if userID, ok := pathUserID(c); ok {
+		putProfile(c, userID)
+	}
+}
+
+// PatchUserProfile godoc
+// @Summary Update a user's profile.
+// @Description Change only the given fields of a user's profile, e.g. to remove an offensive bio.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "User ID"
+// @Param Body body ProfilePatchInput true "the fields to change"
+// @Produce json
+// @Success 200 {object} dto.Profile
+// @Router /api/admin/users/{id}/profile [patch]
+func PatchUserProfile(c *gin.Context) {
+	if userID, ok := pathUserID(c); ok {
+		patchProfile(c, userID)
+	}
+}
+
+// DeleteUserProfile godoc
+// @Summary Delete a user's profile.
+// @Description Delete a user's profile. The account itself is kept.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "User ID"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/users/{id}/profile [delete]
+func DeleteUserProfile(c *gin.Context) {
+	if userID, ok := pathUserID(c); ok {
+		deleteProfile(c, userID)
+	}
+}
+
+func myUserID(c *gin.Context) (uint, bool) {
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return 0, false
+	}
+	return userID, true
+}
+
+// pathUserID returns the ID of the user named in the path, if they exist.
+func pathUserID(c *gin.Context) (uint, bool) {
+	db := c.MustGet("db").(*gorm.DB)
+	var user models.User
+	if err := db.Select("id").First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return 0, false
+	}
+	return user.ID, true
+}
+
+func findProfile(c *gin.Context, userID uint) (models.Profile, bool) {
+	db := c.MustGet("db").(*gorm.DB)
+	var profile models.Profile
+	if err := db.Where("user_id = ?", userID).First(&profile).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Profile not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return models.Profile{}, false
+	}
+	return profile, true
+}
+
+func getProfile(c *gin.Context, userID uint) {
+	if profile, ok := findProfile(c, userID); ok {
+		c.JSON(http.StatusOK, dto.NewProfile(profile))
+	}
+}
+
+func putProfile(c *gin.Context, userID uint) {
+	var input ProfileInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	profile := models.Profile{UserID: userID}
+	if err := db.Where(&profile).FirstOrInit(&profile).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	status := http.StatusOK
+	if profile.ID == 0 {
+		status = http.StatusCreated
+	}
+
+	profile.Fullname = input.Fullname
+	profile.Bio = input.Bio
+	profile.AvatarURL = input.AvatarURL
+	if err := db.Save(&profile).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save profile"})
+		return
+	}
+
+	c.JSON(status, dto.NewProfile(profile))
+}
+
+func patchProfile(c *gin.Context, userID uint) {
+	var input ProfilePatchInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile, ok := findProfile(c, userID)
+	if !ok {
+		return
+	}
+	if input.Fullname != nil {
+		profile.Fullname = *input.Fullname
+	}
+	if input.Bio != nil {
+		profile.Bio = *input.Bio
+	}
+	if input.AvatarURL != nil {
+		profile.AvatarURL = *input.AvatarURL
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	if err := db.Save(&profile).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewProfile(profile))
+}
+
+func setAvatar(c *gin.Context, userID uint, avatarURL string) {
+	profile, ok := findProfile(c, userID)
+	if !ok {
+		return
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	if err := db.Model(&profile).Update("avatar_url", avatarURL).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update avatar"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewProfile(profile))
+}
+
+func deleteProfile(c *gin.Context, userID uint) {
+	profile, ok := findProfile(c, userID)
+	if !ok {
+		return
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	if err := db.Delete(&profile).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Profile deleted"})
 }
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils/token"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUpdateProfileTakesTheUserID(t *testing.T) {
+	r, db := newTestRouter(t)
+	alice := models.User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	bob := models.User{Username: "bob", Email: "bob@example.com", Password: "x"}
+	db.Create(&alice)
+	db.Create(&bob)
+	db.Create(&models.Profile{UserID: alice.ID, Fullname: "Alice"})
+	db.Create(&models.Profile{UserID: bob.ID, Fullname: "Bob"})
+
+	api := r.Group("/api", func(c *gin.Context) { c.Set(token.UserIDKey, alice.ID) })
+	api.PUT("/profile/:id", UpdateProfile)
+
+	for _, tc := range []struct {
+		id   uint
+		want int
+	}{
+		{alice.ID, http.StatusOK},
+		{bob.ID, http.StatusForbidden},
+		{bob.ID + 100, http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/profile/%d", tc.id), strings.NewReader(`{"fullname":"Alice Liddell","bio":"Down the rabbit hole"}`)))
+		if w.Code != tc.want {
+			t.Errorf("PUT /api/profile/%d = %d %s, want %d", tc.id, w.Code, w.Body.String(), tc.want)
+		}
+	}
+
+	var profile models.Profile
+	db.Where("user_id = ?", bob.ID).First(&profile)
+	if profile.Fullname != "Bob" {
+		t.Fatalf("bob's profile was changed to %q", profile.Fullname)
+	}
+}
@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"final-project-rest-api/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RecomputeReputations godoc
+// @Summary Recompute reputations.
+// @Description Recompute the reputation, level and badges of every user. Admin only.
+// @Tags Reputation
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/reputations/recompute [post]
+func RecomputeReputations(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	if err := models.RecomputeAllReputations(db); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recompute reputations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reputations recomputed successfully"})
+}
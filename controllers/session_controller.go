@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"final-project-rest-api/dto"
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils/token"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetMySessions godoc
+// @Summary Get my sessions.
+// @Description Get the devices the logged-in user is logged in on, with user agent, IP and when each was last used.
+// @Description The session of this request is marked current.
+// @Tags Auth
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/sessions [get]
+func GetMySessions(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	currentID, _ := token.ExtractSessionID(c)
+
+	sessions, err := models.ActiveSessions(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve sessions"})
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": dto.NewSessions(sessions)})
+}
+
+// TerminateSession godoc
+// @Summary Log out a session.
+// @Description Log the logged-in user out of one of their sessions. Its token stops working right away.
+// @Tags Auth
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Session ID"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me/sessions/{id} [delete]
+func TerminateSession(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	terminated, err := models.TerminateSession(db, userID, uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to terminate session"})
+		return
+	}
+	if !terminated {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session terminated"})
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"final-project-rest-api/dto"
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils/token"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetJWKS godoc
+// @Summary Get the JSON Web Key Set.
+// @Description The public keys login tokens are signed with, for other services to verify them.
+// @Description Tokens name their key in the kid header. Upcoming keys are published before they are used.
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /.well-known/jwks.json [get]
+func GetJWKS(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	keys, err := models.PublishedSigningKeys(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve keys"})
+		return
+	}
+
+	jwks := make([]token.JWK, 0, len(keys))
+	for _, k := range keys {
+		key, err := token.ParseKey(k.KID, k.Algorithm, k.PublicKey, "")
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", k.KID, err)
+			continue
+		}
+		jwks = append(jwks, key.JWK())
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": jwks})
+}
+
+// GetSigningKeys godoc
+// @Summary Get signing keys.
+// @Description Get the published token signing keys with their schedule. Admin only.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/signing-keys [get]
+func GetSigningKeys(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	keys, err := models.PublishedSigningKeys(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"algorithm": token.SigningAlgorithm(), "signing_keys": dto.NewSigningKeys(keys)})
+}
+
+// RotateSigningKeys godoc
+// @Summary Rotate the signing key.
+// @Description Start signing tokens with a new key right away, e.g. when a key may have leaked.
+// @Description Tokens signed with the previous key stay valid until they expire. Admin only.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/signing-keys/rotate [post]
+func RotateSigningKeys(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	if token.SigningAlgorithm() == token.AlgHS256 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tokens are signed with API_SECRET, set JWT_SIGNING_ALG to RS256 or EdDSA to use signing keys"})
+		return
+	}
+	if err := models.RotateSigningKeys(db, true); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate signing keys"})
+		return
+	}
+	if err := models.LoadSigningKeys(db); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load signing keys"})
+		return
+	}
+
+	keys, err := models.PublishedSigningKeys(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Signing key rotated", "signing_keys": dto.NewSigningKeys(keys)})
+}
@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils/token"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TwoFactorCodeInput struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactorInput struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+func currentUser(c *gin.Context, db *gorm.DB) (models.User, bool) {
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return models.User{}, false
+	}
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// GetTwoFactorStatus godoc
+// @Summary Get two-factor authentication status.
+// @Description Whether two-factor authentication is on, whether the user's role requires it and how many recovery codes are left.
+// @Tags Auth
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/2fa [get]
+func GetTwoFactorStatus(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	user, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+
+	remaining, err := models.RemainingRecoveryCodes(db, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  user.TwoFactorEnabled(),
+		"enabled_at":               user.TwoFactorEnabledAt,
+		"required":                 models.TwoFactorRequired(user.Role),
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// EnrollTwoFactor godoc
+// @Summary Start two-factor enrollment.
+// @Description Provision a new TOTP secret. Add it to an authenticator app, by hand or through the otpauth URI as a QR code,
+// @Description then confirm with a code from the app to turn two-factor authentication on.
+// @Tags Auth
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/2fa/enroll [post]
+func EnrollTwoFactor(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	user, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+
+	secret, uri, err := models.BeginTwoFactorEnrollment(db, &user)
+	if err == models.ErrTwoFactorEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"secret": secret, "otpauth_uri": uri})
+}
+
+// ConfirmTwoFactor godoc
+// @Summary Confirm two-factor enrollment.
+// @Description Turn two-factor authentication on with a code from the authenticator app.
+// @Description The response holds the recovery codes, which are only shown once.
+// @Tags Auth
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param Body body TwoFactorCodeInput true "the code from the authenticator app"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/2fa/confirm [post]
+func ConfirmTwoFactor(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var input TwoFactorCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+
+	codes, err := models.ConfirmTwoFactor(db, &user, input.Code)
+	switch err {
+	case nil:
+	case models.ErrTwoFactorEnabled, models.ErrTwoFactorNotEnrolled:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case models.ErrInvalidTwoFactorCode:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes.
+// @Description Replace the recovery codes with a new set, which is only shown once. Requires a current code.
+// @Tags Auth
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param Body body TwoFactorCodeInput true "a code from the authenticator app or a recovery code"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/2fa/recovery-codes [post]
+func RegenerateRecoveryCodes(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var input TwoFactorCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+	if !verifySecondFactor(c, db, &user, input.Code) {
+		return
+	}
+
+	codes, err := models.RegenerateRecoveryCodes(db, &user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableTwoFactor godoc
+// @Summary Disable two-factor authentication.
+// @Description Turn two-factor authentication off. Requires the password and a current code,
+// @Description and isn't allowed for roles that must use two-factor authentication.
+// @Tags Auth
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param Body body DisableTwoFactorInput true "the password and a code"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/2fa/disable [post]
+func DisableTwoFactor(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var input DisableTwoFactorInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+	if models.TwoFactorRequired(user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role"})
+		return
+	}
+	if err := user.VerifyPassword(input.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid password"})
+		return
+	}
+	if !verifySecondFactor(c, db, &user, input.Code) {
+		return
+	}
+
+	if err := models.DisableTwoFactor(db, &user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
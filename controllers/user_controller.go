@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"final-project-rest-api/dto"
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils/token"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// recentReviewsLimit is how many reviews a user's public page shows.
+const recentReviewsLimit = 5
+
+// GetUserPage godoc
+// @Summary Get a user's public page.
+// @Description Get a user's public profile with their reputation, joined date, review statistics and most recent reviews.
+// @Description Fields the user hid in their privacy settings are left out.
+// @Tags User
+// @Param username path string true "Username"
+// @Produce json
+// @Success 200 {object} dto.UserPage
+// @Router /api/users/{username} [get]
+func GetUserPage(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var user models.User
+	if err := db.Preload("Profile").Where("username = ? AND username <> ?", c.Param("username"), models.DeletedUsername).
+		First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	settings, err := models.PrivacySettings(db, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+	models.RedactProfile(&user.Profile, settings)
+
+	var stats models.ReviewStats
+	if settings[models.PrivacyStats] {
+		if stats, err = models.UserReviewStats(db, user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+			return
+		}
+	}
+
+	var reviews []models.Comment
+	if settings[models.PrivacyReviews] {
+		if err := db.Preload("AspectRatings").Where("user_id = ?", user.ID).
+			Order("created_at DESC").Limit(recentReviewsLimit).Find(&reviews).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+			return
+		}
+		if err := models.MarkVerifiedOwners(db, reviews); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, dto.NewUserPage(user, settings, stats, reviews))
+}
+
+// GetPrivacySettings godoc
+// @Summary Get my privacy settings.
+// @Description Get which parts of the logged-in user's public page are visible.
+// @Tags User
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]bool
+// @Router /api/me/privacy [get]
+func GetPrivacySettings(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	settings, err := models.PrivacySettings(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve privacy settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdatePrivacySettings godoc
+// @Summary Update my privacy settings.
+// @Description Show or hide parts of the logged-in user's public page, e.g. {"bio": false, "stats": false}.
+// @Description The fields are fullname, bio, avatar, joined_date, stats and reviews; fields left out keep their setting.
+// @Tags User
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param Body body map[string]bool true "visibility per field"
+// @Produce json
+// @Success 200 {object} map[string]bool
+// @Router /api/me/privacy [put]
+func UpdatePrivacySettings(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input map[string]bool
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for field := range input {
+		if !models.IsPrivacyField(field) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown privacy field " + field})
+			return
+		}
+	}
+
+	if err := models.UpdatePrivacySettings(db, userID, input); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update privacy settings"})
+		return
+	}
+
+	settings, err := models.PrivacySettings(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve privacy settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// GetMe godoc
+// @Summary Get my account.
+// @Description Get the logged-in user's account with their profile, null when they have none. The rest of the account
+// @Description is under /api/me too: the notification preferences at /api/me/notification-preferences, and the
+// @Description linked provider accounts at /api/me/identities.
+// @Tags User
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/me [get]
+func GetMe(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	user, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+
+	var profile *dto.Profile
+	var p models.Profile
+	if err := db.Where("user_id = ?", user.ID).First(&p).Error; err == nil {
+		converted := dto.NewProfile(p)
+		profile = &converted
+	} else if err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"account": dto.NewAccount(user), "profile": profile})
+}
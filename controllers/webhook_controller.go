@@ -0,0 +1,314 @@
+package controllers
+
+import (
+	"final-project-rest-api/dto"
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils/token"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type WebhookInput struct {
+	URL         string   `json:"url" binding:"required,max=500"`
+	Description string   `json:"description" binding:"max=255"`
+	Events      []string `json:"events" binding:"required"`
+	Active      *bool    `json:"active"`
+}
+
+func (input WebhookInput) apply(w *models.Webhook) {
+	w.URL = input.URL
+	w.Description = input.Description
+	w.Events = input.Events
+	if input.Active != nil {
+		w.Active = *input.Active
+	}
+}
+
+// GetWebhooks godoc
+// @Summary Get webhooks.
+// @Description Get every registered webhook endpoint. Admin only.
+// @Tags Webhook
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/webhooks [get]
+func GetWebhooks(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var webhooks []models.Webhook
+	if err := db.Find(&webhooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhooks": dto.NewWebhooks(webhooks), "events": models.WebhookEvents})
+}
+
+// CreateWebhook godoc
+// @Summary Register a webhook.
+// @Description Register an endpoint that receives the subscribed events ("*" for all) as signed JSON POSTs.
+// @Description The signing secret is only returned here and when it is rotated. Receivers should check
+// @Description the X-Webhook-Signature header, "sha256=" + hex HMAC-SHA256 of "<X-Webhook-Timestamp>.<body>".
+// @Description The URL must be a public address; redirects are not followed. Admin only.
+// @Tags Webhook
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param Body body WebhookInput true "the body to register a webhook"
+// @Produce json
+// @Success 201 {object} map[string]interface{}
+// @Router /api/admin/webhooks [post]
+func CreateWebhook(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID, err := token.ExtractTokenID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input WebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := models.GenerateWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	webhook := models.Webhook{Secret: secret, Active: true, CreatedByID: userID}
+	input.apply(&webhook)
+	if err := webhook.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Create(&webhook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"webhook": dto.NewWebhook(webhook), "secret": secret})
+}
+
+// GetWebhook godoc
+// @Summary Get a webhook.
+// @Description Get a registered webhook endpoint by ID. Admin only.
+// @Tags Webhook
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Webhook ID"
+// @Produce json
+// @Success 200 {object} dto.Webhook
+// @Router /api/admin/webhooks/{id} [get]
+func GetWebhook(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var webhook models.Webhook
+	if err := db.First(&webhook, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewWebhook(webhook))
+}
+
+// UpdateWebhook godoc
+// @Summary Update a webhook.
+// @Description Update the URL, events or active state of a webhook. Admin only.
+// @Tags Webhook
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Webhook ID"
+// @Param Body body WebhookInput true "the body to update a webhook"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/webhooks/{id} [put]
+func UpdateWebhook(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var input WebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var webhook models.Webhook
+	if err := db.First(&webhook, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	input.apply(&webhook)
+	if err := webhook.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Save(&webhook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook updated successfully", "webhook": dto.NewWebhook(webhook)})
+}
+
+// DeleteWebhook godoc
+// @Summary Delete a webhook.
+// @Description Delete a webhook and its delivery log. Admin only.
+// @Tags Webhook
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Webhook ID"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/webhooks/{id} [delete]
+func DeleteWebhook(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var webhook models.Webhook
+	if err := db.First(&webhook, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", webhook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&webhook).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// RotateWebhookSecret godoc
+// @Summary Rotate a webhook secret.
+// @Description Replace the signing secret of a webhook. Deliveries are signed with the new secret right away. Admin only.
+// @Tags Webhook
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Webhook ID"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/webhooks/{id}/secret [post]
+func RotateWebhookSecret(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var webhook models.Webhook
+	if err := db.First(&webhook, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	secret, err := models.GenerateWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate webhook secret"})
+		return
+	}
+	if err := db.Model(&webhook).Update("secret", secret).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate webhook secret"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhook": dto.NewWebhook(webhook), "secret": secret})
+}
+
+// PingWebhook godoc
+// @Summary Ping a webhook.
+// @Description Send a signed "ping" event to the webhook right away and return the delivery. Admin only.
+// @Tags Webhook
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Webhook ID"
+// @Produce json
+// @Success 200 {object} dto.WebhookDelivery
+// @Router /api/admin/webhooks/{id}/ping [post]
+func PingWebhook(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var webhook models.Webhook
+	if err := db.First(&webhook, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	delivery, err := models.PingWebhook(db, webhook)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ping webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewWebhookDelivery(delivery))
+}
+
+// GetWebhookDeliveries godoc
+// @Summary Get webhook deliveries.
+// @Description Get the delivery log of a webhook, newest first, optionally filtered by status (pending, succeeded, failed). Admin only.
+// @Tags Webhook
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Webhook ID"
+// @Param status query string false "delivery status"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/webhooks/{id}/deliveries [get]
+func GetWebhookDeliveries(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var webhook models.Webhook
+	if err := db.First(&webhook, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	query := db.Where("webhook_id = ?", webhook.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("id DESC").Limit(100).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": dto.NewWebhookDeliveries(deliveries)})
+}
+
+// RedeliverWebhookDelivery godoc
+// @Summary Redeliver a webhook delivery.
+// @Description Send the payload of a past delivery again as a new delivery and return it. Admin only.
+// @Tags Webhook
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Webhook ID"
+// @Param delivery_id path string true "Delivery ID"
+// @Produce json
+// @Success 200 {object} dto.WebhookDelivery
+// @Router /api/admin/webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func RedeliverWebhookDelivery(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var original models.WebhookDelivery
+	if err := db.Where("id = ? AND webhook_id = ?", c.Param("delivery_id"), c.Param("id")).First(&original).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+
+	delivery, err := models.Redeliver(db, original)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeliver"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewWebhookDelivery(delivery))
+}
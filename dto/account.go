@@ -0,0 +1,123 @@
+package dto
+
+import (
+	"final-project-rest-api/models"
+	"time"
+)
+
+// APIKey is a key as listed to its owner; the key itself is only shown when created.
+type APIKey struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type Session struct {
+	ID         uint      `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Identity is a provider login linked to the account. The provider's ID for the user
+// stays on the server.
+type Identity struct {
+	ID          uint       `json:"id"`
+	Provider    string     `json:"provider"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type DataExport struct {
+	ID          uint       `json:"id"`
+	Format      string     `json:"format"`
+	Status      string     `json:"status"`
+	Size        int        `json:"size"`
+	Error       string     `json:"error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func NewAPIKey(k models.APIKey) APIKey {
+	return APIKey{
+		ID:         k.ID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.Scopes,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		LastUsedIP: k.LastUsedIP,
+		RevokedAt:  k.RevokedAt,
+		CreatedAt:  k.CreatedAt,
+	}
+}
+
+func NewAPIKeys(keys []models.APIKey) []APIKey {
+	out := make([]APIKey, len(keys))
+	for i, k := range keys {
+		out[i] = NewAPIKey(k)
+	}
+	return out
+}
+
+func NewSessions(sessions []models.Session) []Session {
+	out := make([]Session, len(sessions))
+	for i, s := range sessions {
+		out[i] = Session{
+			ID:         s.ID,
+			UserAgent:  s.UserAgent,
+			IP:         s.IP,
+			Current:    s.Current,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			ExpiresAt:  s.ExpiresAt,
+		}
+	}
+	return out
+}
+
+func NewIdentities(identities []models.UserIdentity) []Identity {
+	out := make([]Identity, len(identities))
+	for i, identity := range identities {
+		out[i] = Identity{
+			ID:          identity.ID,
+			Provider:    identity.Provider,
+			Email:       identity.Email,
+			LastLoginAt: identity.LastLoginAt,
+			CreatedAt:   identity.CreatedAt,
+		}
+	}
+	return out
+}
+
+func NewDataExport(e models.DataExport) DataExport {
+	return DataExport{
+		ID:          e.ID,
+		Format:      e.Format,
+		Status:      e.Status,
+		Size:        e.Size,
+		Error:       e.Error,
+		CompletedAt: e.CompletedAt,
+		ExpiresAt:   e.ExpiresAt,
+		CreatedAt:   e.CreatedAt,
+	}
+}
+
+func NewDataExports(exports []models.DataExport) []DataExport {
+	out := make([]DataExport, len(exports))
+	for i, e := range exports {
+		out[i] = NewDataExport(e)
+	}
+	return out
+}
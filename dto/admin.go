@@ -0,0 +1,139 @@
+package dto
+
+import (
+	"final-project-rest-api/models"
+	"time"
+)
+
+type LoginAttempt struct {
+	ID        uint      `json:"id"`
+	Username  string    `json:"username"`
+	UserID    *uint     `json:"user_id"`
+	IP        string    `json:"ip"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AdminAction struct {
+	ID           uint                   `json:"id"`
+	AdminID      uint                   `json:"admin_id"`
+	TargetUserID uint                   `json:"target_user_id"`
+	Action       string                 `json:"action"`
+	Reason       string                 `json:"reason,omitempty"`
+	Details      map[string]interface{} `json:"details,omitempty"`
+	IP           string                 `json:"ip"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
+
+type AuditLog struct {
+	ID             uint                   `json:"id"`
+	ActorID        *uint                  `json:"actor_id"`
+	ImpersonatorID *uint                  `json:"impersonator_id,omitempty"`
+	IP             string                 `json:"ip"`
+	Method         string                 `json:"method"`
+	Route          string                 `json:"route"`
+	Action         string                 `json:"action"`
+	Entity         string                 `json:"entity"`
+	EntityID       string                 `json:"entity_id"`
+	Before         map[string]interface{} `json:"before"`
+	After          map[string]interface{} `json:"after"`
+	ScrubbedAt     *time.Time             `json:"scrubbed_at,omitempty"`
+	ScrubbedHash   string                 `json:"scrubbed_hash,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+	ContentHash    string                 `json:"content_hash"`
+	Seq            *uint64                `json:"seq"`
+	PrevHash       string                 `json:"prev_hash"`
+	Hash           string                 `json:"hash"`
+}
+
+// SigningKey is a token signing key as admins see it: its public half and schedule.
+type SigningKey struct {
+	ID          uint       `json:"id"`
+	KID         string     `json:"kid"`
+	Algorithm   string     `json:"algorithm"`
+	PublicKey   string     `json:"public_key"`
+	Status      string     `json:"status"`
+	ActivatesAt time.Time  `json:"activates_at"`
+	RetiredAt   *time.Time `json:"retired_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func NewLoginAttempts(attempts []models.LoginAttempt) []LoginAttempt {
+	out := make([]LoginAttempt, len(attempts))
+	for i, a := range attempts {
+		out[i] = LoginAttempt{
+			ID:        a.ID,
+			Username:  a.Username,
+			UserID:    a.UserID,
+			IP:        a.IP,
+			Success:   a.Success,
+			Reason:    a.Reason,
+			CreatedAt: a.CreatedAt,
+		}
+	}
+	return out
+}
+
+func NewAdminActions(actions []models.AdminAction) []AdminAction {
+	out := make([]AdminAction, len(actions))
+	for i, a := range actions {
+		out[i] = AdminAction{
+			ID:           a.ID,
+			AdminID:      a.AdminID,
+			TargetUserID: a.TargetUserID,
+			Action:       a.Action,
+			Reason:       a.Reason,
+			Details:      a.Details,
+			IP:           a.IP,
+			CreatedAt:    a.CreatedAt,
+		}
+	}
+	return out
+}
+
+func NewAuditLogs(entries []models.AuditLog) []AuditLog {
+	out := make([]AuditLog, len(entries))
+	for i, e := range entries {
+		out[i] = AuditLog{
+			ID:             e.ID,
+			ActorID:        e.ActorID,
+			ImpersonatorID: e.ImpersonatorID,
+			IP:             e.IP,
+			Method:         e.Method,
+			Route:          e.Route,
+			Action:         e.Action,
+			Entity:         e.Entity,
+			EntityID:       e.EntityID,
+			Before:         e.Before,
+			After:          e.After,
+			ScrubbedAt:     e.ScrubbedAt,
+			ScrubbedHash:   e.ScrubbedHash,
+			CreatedAt:      e.CreatedAt,
+			ContentHash:    e.ContentHash,
+			Seq:            e.Seq,
+			PrevHash:       e.PrevHash,
+			Hash:           e.Hash,
+		}
+	}
+	return out
+}
+
+func NewSigningKeys(keys []models.SigningKey) []SigningKey {
+	out := make([]SigningKey, len(keys))
+	for i, k := range keys {
+		out[i] = SigningKey{
+			ID:          k.ID,
+			KID:         k.KID,
+			Algorithm:   k.Algorithm,
+			PublicKey:   k.PublicKey,
+			Status:      k.Status,
+			ActivatesAt: k.ActivatesAt,
+			RetiredAt:   k.RetiredAt,
+			ExpiresAt:   k.ExpiresAt,
+			CreatedAt:   k.CreatedAt,
+		}
+	}
+	return out
+}
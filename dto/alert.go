@@ -0,0 +1,50 @@
+package dto
+
+import (
+	"final-project-rest-api/models"
+	"time"
+)
+
+type Alert struct {
+	ID              uint       `json:"id"`
+	UserID          uint       `json:"user_id"`
+	Type            string     `json:"type"`
+	LaptopID        *uint      `json:"laptop_id"`
+	BrandID         *uint      `json:"brand_id"`
+	TargetPrice     float64    `json:"target_price"`
+	DropPercent     float64    `json:"drop_percent"`
+	ReferencePrice  float64    `json:"reference_price"`
+	Channels        []string   `json:"channels"`
+	WebhookURL      string     `json:"webhook_url"`
+	Active          bool       `json:"active"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func NewAlert(a models.AlertSubscription) Alert {
+	return Alert{
+		ID:              a.ID,
+		UserID:          a.UserID,
+		Type:            a.Type,
+		LaptopID:        a.LaptopID,
+		BrandID:         a.BrandID,
+		TargetPrice:     a.TargetPrice,
+		DropPercent:     a.DropPercent,
+		ReferencePrice:  a.ReferencePrice,
+		Channels:        a.Channels,
+		WebhookURL:      a.WebhookURL,
+		Active:          a.Active,
+		LastTriggeredAt: a.LastTriggeredAt,
+		CreatedAt:       a.CreatedAt,
+		UpdatedAt:       a.UpdatedAt,
+	}
+}
+
+func NewAlerts(alerts []models.AlertSubscription) []Alert {
+	out := make([]Alert, len(alerts))
+	for i, a := range alerts {
+		out[i] = NewAlert(a)
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package dto
+
+import (
+	"final-project-rest-api/models"
+	"time"
+)
+
+type CollectionItem struct {
+	ID           uint      `json:"id"`
+	CollectionID uint      `json:"collection_id"`
+	LaptopID     uint      `json:"laptop_id"`
+	Note         string    `json:"note"`
+	Position     int       `json:"position"`
+	Laptop       *Laptop   `json:"laptop,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type Collection struct {
+	ID         uint             `json:"id"`
+	UserID     uint             `json:"user_id"`
+	Name       string           `json:"name"`
+	IsDefault  bool             `json:"is_default"`
+	Visibility string           `json:"visibility"`
+	ShareToken string           `json:"share_token"`
+	Items      []CollectionItem `json:"items"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+}
+
+func NewCollectionItem(item models.CollectionItem) CollectionItem {
+	return CollectionItem{
+		ID:           item.ID,
+		CollectionID: item.CollectionID,
+		LaptopID:     item.LaptopID,
+		Note:         item.Note,
+		Position:     item.Position,
+		Laptop:       NewLaptopRef(item.Laptop),
+		CreatedAt:    item.CreatedAt,
+		UpdatedAt:    item.UpdatedAt,
+	}
+}
+
+func NewCollection(c models.Collection) Collection {
+	var items []CollectionItem
+	if c.Items != nil {
+		items = make([]CollectionItem, len(c.Items))
+		for i, item := range c.Items {
+			items[i] = NewCollectionItem(item)
+		}
+	}
+	return Collection{
+		ID:         c.ID,
+		UserID:     c.UserID,
+		Name:       c.Name,
+		IsDefault:  c.IsDefault,
+		Visibility: c.Visibility,
+		ShareToken: c.ShareToken,
+		Items:      items,
+		CreatedAt:  c.CreatedAt,
+		UpdatedAt:  c.UpdatedAt,
+	}
+}
+
+func NewCollections(collections []models.Collection) []Collection {
+	out := make([]Collection, len(collections))
+	for i, c := range collections {
+		out[i] = NewCollection(c)
+	}
+	return out
+}
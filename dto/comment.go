@@ -0,0 +1,97 @@
+package dto
+
+import (
+	"final-project-rest-api/models"
+	"time"
+)
+
+type AspectRating struct {
+	Aspect string `json:"aspect"`
+	Score  int    `json:"score"`
+}
+
+type Comment struct {
+	ID            uint           `json:"id"`
+	UserID        uint           `json:"user_id"`
+	LaptopID      uint           `json:"laptop_id"`
+	Title         string         `json:"title"`
+	Content       string         `json:"content"`
+	Rating        int            `json:"rating"`
+	Pros          []string       `json:"pros"`
+	Cons          []string       `json:"cons"`
+	UsageMonths   int            `json:"usage_months"`
+	UseCases      []string       `json:"use_cases"`
+	AspectRatings []AspectRating `json:"aspect_ratings"`
+	VerifiedOwner bool           `json:"verified_owner"`
+	Author        *Author        `json:"author,omitempty"`
+	Edited        bool           `json:"edited"`
+	EditedAt      *time.Time     `json:"edited_at"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+func NewComment(c models.Comment) Comment {
+	aspects := make([]AspectRating, len(c.AspectRatings))
+	for i, rating := range c.AspectRatings {
+		aspects[i] = AspectRating{Aspect: rating.Aspect, Score: rating.Score}
+	}
+	comment := Comment{
+		ID:            c.ID,
+		UserID:        c.UserID,
+		LaptopID:      c.LaptopID,
+		Title:         c.Title,
+		Content:       c.Content,
+		Rating:        c.Rating,
+		Pros:          c.Pros,
+		Cons:          c.Cons,
+		UsageMonths:   c.UsageMonths,
+		UseCases:      c.UseCases,
+		AspectRatings: aspects,
+		VerifiedOwner: c.VerifiedOwner,
+		Edited:        c.Edited,
+		EditedAt:      c.EditedAt,
+		CreatedAt:     c.CreatedAt,
+		UpdatedAt:     c.UpdatedAt,
+	}
+	if c.User != nil {
+		author := NewAuthor(*c.User)
+		comment.Author = &author
+	}
+	return comment
+}
+
+// NewComments converts a list of comments; nil stays nil, as for comments that weren't loaded.
+func NewComments(comments []models.Comment) []Comment {
+	if comments == nil {
+		return nil
+	}
+	out := make([]Comment, len(comments))
+	for i, c := range comments {
+		out[i] = NewComment(c)
+	}
+	return out
+}
+
+// CommentRevision is a version of a review with what changed since the previous one. Who
+// made a moderator's edit stays on the server.
+type CommentRevision struct {
+	ID                uint                   `json:"id"`
+	CommentID         uint                   `json:"comment_id"`
+	Revision          int                    `json:"revision"`
+	EditedByModerator bool                   `json:"edited_by_moderator"`
+	Snapshot          models.CommentSnapshot `json:"snapshot"`
+	Changes           []models.FieldChange   `json:"changes"`
+	CreatedAt         time.Time              `json:"created_at"`
+}
+
+func NewCommentRevision(r models.CommentRevision, authorID uint, changes []models.FieldChange) CommentRevision {
+	return CommentRevision{
+		ID:                r.ID,
+		CommentID:         r.CommentID,
+		Revision:          r.Revision,
+		EditedByModerator: r.EditorID != authorID,
+		Snapshot:          r.Snapshot,
+		Changes:           changes,
+		CreatedAt:         r.CreatedAt,
+	}
+}
@@ -0,0 +1,111 @@
+package dto
+
+import (
+	"final-project-rest-api/models"
+	"time"
+)
+
+// Brand and Category keep the keys the brand and category endpoints render.
+type Brand struct {
+	ID        uint   `json:"ID"`
+	BrandName string `json:"BrandName"`
+}
+
+type Category struct {
+	ID           uint     `json:"ID"`
+	CategoryName string   `json:"CategoryName"`
+	Aspects      []string `json:"Aspects"`
+}
+
+func NewBrand(b models.Brand) Brand {
+	return Brand{ID: b.ID, BrandName: b.BrandName}
+}
+
+func NewBrands(brands []models.Brand) []Brand {
+	out := make([]Brand, len(brands))
+	for i, b := range brands {
+		out[i] = NewBrand(b)
+	}
+	return out
+}
+
+func NewCategory(c models.Category) Category {
+	return Category{ID: c.ID, CategoryName: c.CategoryName, Aspects: c.Aspects}
+}
+
+func NewCategories(categories []models.Category) []Category {
+	out := make([]Category, len(categories))
+	for i, c := range categories {
+		out[i] = NewCategory(c)
+	}
+	return out
+}
+
+type Laptop struct {
+	ID                  uint                   `json:"id"`
+	BrandID             uint                   `json:"brand_id"`
+	CategoryID          uint                   `json:"category_id"`
+	Name                string                 `json:"name"`
+	ReleaseYear         int                    `json:"release_year"`
+	Spec                string                 `json:"spec"`
+	Price               float64                `json:"price"`
+	Comments            []Comment              `json:"comments"`
+	Brand               *Brand                 `json:"brand,omitempty"`
+	Category            *Category              `json:"category,omitempty"`
+	Aspects             []models.AspectSummary `json:"aspects"`
+	TopPros             []models.Highlight     `json:"top_pros"`
+	TopCons             []models.Highlight     `json:"top_cons"`
+	AverageRating       float64                `json:"average_rating"`
+	ReviewCount         int64                  `json:"review_count"`
+	VerifiedReviewCount int64                  `json:"verified_review_count"`
+	CreatedAt           time.Time              `json:"created_at"`
+	UpdatedAt           time.Time              `json:"updated_at"`
+}
+
+func NewLaptop(l models.Laptop) Laptop {
+	laptop := Laptop{
+		ID:                  l.ID,
+		BrandID:             l.BrandID,
+		CategoryID:          l.CategoryID,
+		Name:                l.Name,
+		ReleaseYear:         l.ReleaseYear,
+		Spec:                l.Spec,
+		Price:               l.Price,
+		Comments:            NewComments(l.Comments),
+		Aspects:             l.Aspects,
+		TopPros:             l.TopPros,
+		TopCons:             l.TopCons,
+		AverageRating:       l.AverageRating,
+		ReviewCount:         l.ReviewCount,
+		VerifiedReviewCount: l.VerifiedReviewCount,
+		CreatedAt:           l.CreatedAt,
+		UpdatedAt:           l.UpdatedAt,
+	}
+	// only when preloaded
+	if l.Brand.ID != 0 {
+		brand := NewBrand(l.Brand)
+		laptop.Brand = &brand
+	}
+	if l.Category.ID != 0 {
+		category := NewCategory(l.Category)
+		laptop.Category = &category
+	}
+	return laptop
+}
+
+func NewLaptops(laptops []models.Laptop) []Laptop {
+	out := make([]Laptop, len(laptops))
+	for i, l := range laptops {
+		out[i] = NewLaptop(l)
+	}
+	return out
+}
+
+// NewLaptopRef converts an optional laptop association.
+func NewLaptopRef(l *models.Laptop) *Laptop {
+	if l == nil {
+		return nil
+	}
+	laptop := NewLaptop(*l)
+	return &laptop
+}
@@ -0,0 +1,38 @@
+package dto
+
+import (
+	"final-project-rest-api/models"
+	"time"
+)
+
+type Notification struct {
+	ID        uint                   `json:"id"`
+	UserID    uint                   `json:"user_id"`
+	Type      string                 `json:"type"`
+	Title     string                 `json:"title"`
+	Body      string                 `json:"body"`
+	Data      map[string]interface{} `json:"data"`
+	ReadAt    *time.Time             `json:"read_at"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+func NewNotification(n models.Notification) Notification {
+	return Notification{
+		ID:        n.ID,
+		UserID:    n.UserID,
+		Type:      n.Type,
+		Title:     n.Title,
+		Body:      n.Body,
+		Data:      n.Data,
+		ReadAt:    n.ReadAt,
+		CreatedAt: n.CreatedAt,
+	}
+}
+
+func NewNotifications(notifications []models.Notification) []Notification {
+	out := make([]Notification, len(notifications))
+	for i, n := range notifications {
+		out[i] = NewNotification(n)
+	}
+	return out
+}
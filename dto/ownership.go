@@ -0,0 +1,44 @@
+package dto
+
+import (
+	"final-project-rest-api/models"
+	"time"
+)
+
+type OwnershipClaim struct {
+	ID           uint       `json:"id"`
+	UserID       uint       `json:"user_id"`
+	LaptopID     uint       `json:"laptop_id"`
+	HasReceipt   bool       `json:"has_receipt"`
+	Status       string     `json:"status"`
+	Note         string     `json:"note"`
+	ReviewedByID *uint      `json:"reviewed_by_id"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+	Laptop       *Laptop    `json:"laptop,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func NewOwnershipClaim(claim models.OwnershipClaim) OwnershipClaim {
+	return OwnershipClaim{
+		ID:           claim.ID,
+		UserID:       claim.UserID,
+		LaptopID:     claim.LaptopID,
+		HasReceipt:   claim.HasReceipt,
+		Status:       claim.Status,
+		Note:         claim.Note,
+		ReviewedByID: claim.ReviewedByID,
+		ReviewedAt:   claim.ReviewedAt,
+		Laptop:       NewLaptopRef(claim.Laptop),
+		CreatedAt:    claim.CreatedAt,
+		UpdatedAt:    claim.UpdatedAt,
+	}
+}
+
+func NewOwnershipClaims(claims []models.OwnershipClaim) []OwnershipClaim {
+	out := make([]OwnershipClaim, len(claims))
+	for i, claim := range claims {
+		out[i] = NewOwnershipClaim(claim)
+	}
+	return out
+}
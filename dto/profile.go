@@ -0,0 +1,38 @@
+package dto
+
+import (
+	"final-project-rest-api/models"
+	"time"
+)
+
+type Profile struct {
+	ID         uint                      `json:"id"`
+	UserID     uint                      `json:"user_id"`
+	Fullname   string                    `json:"fullname"`
+	Bio        string                    `json:"bio"`
+	AvatarURL  string                    `json:"avatar_url"`
+	Reputation *models.ReputationSummary `json:"reputation,omitempty"`
+	CreatedAt  time.Time                 `json:"created_at"`
+	UpdatedAt  time.Time                 `json:"updated_at"`
+}
+
+func NewProfile(p models.Profile) Profile {
+	return Profile{
+		ID:         p.ID,
+		UserID:     p.UserID,
+		Fullname:   p.Fullname,
+		Bio:        p.Bio,
+		AvatarURL:  p.AvatarURL,
+		Reputation: p.Reputation,
+		CreatedAt:  p.CreatedAt,
+		UpdatedAt:  p.UpdatedAt,
+	}
+}
+
+func NewProfiles(profiles []models.Profile) []Profile {
+	out := make([]Profile, len(profiles))
+	for i, p := range profiles {
+		out[i] = NewProfile(p)
+	}
+	return out
+}
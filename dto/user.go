@@ -0,0 +1,162 @@
+// Package dto is what the API renders. Controllers convert models to these types instead
+// of serializing GORM models, so a new column or a preloaded association never reaches a
+// response by accident. Public types are safe to show anyone; private ones only to the
+// user they describe, or to admins.
+package dto
+
+import (
+	"final-project-rest-api/models"
+	"time"
+)
+
+// User is the public view of an account.
+type User struct {
+	ID         uint                     `json:"id"`
+	Username   string                   `json:"username"`
+	Reputation models.ReputationSummary `json:"reputation"`
+	CreatedAt  time.Time                `json:"created_at"`
+}
+
+// UserPage is a user's public page. The fields the user hid are left out.
+type UserPage struct {
+	Username    string                   `json:"username"`
+	DisplayName string                   `json:"display_name"`
+	Fullname    string                   `json:"fullname,omitempty"`
+	Bio         string                   `json:"bio,omitempty"`
+	AvatarURL   string                   `json:"avatar_url,omitempty"`
+	Reputation  models.ReputationSummary `json:"reputation"`
+	JoinedAt    *time.Time               `json:"joined_at,omitempty"`
+	Stats       *models.ReviewStats      `json:"stats,omitempty"`
+	Reviews     []Comment                `json:"recent_reviews,omitempty"`
+}
+
+// Author is who wrote a review, as shown next to it.
+type Author struct {
+	ID          uint                     `json:"id"`
+	Username    string                   `json:"username"`
+	DisplayName string                   `json:"display_name"`
+	AvatarURL   string                   `json:"avatar_url"`
+	Reputation  models.ReputationSummary `json:"reputation"`
+}
+
+// Account is the private view of an account, for its owner and admins.
+type Account struct {
+	ID                  uint                     `json:"id"`
+	Username            string                   `json:"username"`
+	Email               string                   `json:"email"`
+	Role                string                   `json:"role"`
+	Reputation          models.ReputationSummary `json:"reputation"`
+	EmailVerifiedAt     *time.Time               `json:"email_verified_at"`
+	TwoFactorEnabledAt  *time.Time               `json:"two_factor_enabled_at"`
+	LockedUntil         *time.Time               `json:"locked_until"`
+	DeletionScheduledAt *time.Time               `json:"deletion_scheduled_at"`
+	CreatedAt           time.Time                `json:"created_at"`
+	UpdatedAt           time.Time                `json:"updated_at"`
+}
+
+// AdminUser is an account as admins manage it.
+type AdminUser struct {
+	Account
+	Status                string     `json:"status"`
+	SuspendedAt           *time.Time `json:"suspended_at"`
+	SuspendedUntil        *time.Time `json:"suspended_until"`
+	SuspensionReason      string     `json:"suspension_reason,omitempty"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	Profile               *Profile   `json:"profile,omitempty"`
+}
+
+func NewUser(u models.User) User {
+	return User{
+		ID:         u.ID,
+		Username:   u.Username,
+		Reputation: u.ReputationSummary(),
+		CreatedAt:  u.CreatedAt,
+	}
+}
+
+// NewAuthor converts a comment's author loaded with their profile.
+func NewAuthor(u models.User) Author {
+	if u.Username == models.DeletedUsername {
+		return Author{ID: u.ID, Username: u.Username, DisplayName: "Deleted user", Reputation: u.ReputationSummary()}
+	}
+	displayName := u.Profile.Fullname
+	if displayName == "" {
+		displayName = u.Username
+	}
+	return Author{
+		ID:          u.ID,
+		Username:    u.Username,
+		DisplayName: displayName,
+		AvatarURL:   u.Profile.AvatarURL,
+		Reputation:  u.ReputationSummary(),
+	}
+}
+
+// NewUserPage builds the public page of a user loaded with their profile, already
+// redacted by their privacy settings.
+func NewUserPage(u models.User, settings map[string]bool, stats models.ReviewStats, reviews []models.Comment) UserPage {
+	displayName := u.Profile.Fullname
+	if displayName == "" {
+		displayName = u.Username
+	}
+	page := UserPage{
+		Username:    u.Username,
+		DisplayName: displayName,
+		Fullname:    u.Profile.Fullname,
+		Bio:         u.Profile.Bio,
+		AvatarURL:   u.Profile.AvatarURL,
+		Reputation:  u.ReputationSummary(),
+	}
+	if settings[models.PrivacyJoined] {
+		joinedAt := u.CreatedAt
+		page.JoinedAt = &joinedAt
+	}
+	if settings[models.PrivacyStats] {
+		page.Stats = &stats
+	}
+	if settings[models.PrivacyReviews] {
+		page.Reviews = NewComments(reviews)
+	}
+	return page
+}
+
+func NewAccount(u models.User) Account {
+	return Account{
+		ID:                  u.ID,
+		Username:            u.Username,
+		Email:               u.Email,
+		Role:                u.Role,
+		Reputation:          u.ReputationSummary(),
+		EmailVerifiedAt:     u.EmailVerifiedAt,
+		TwoFactorEnabledAt:  u.TwoFactorEnabledAt,
+		LockedUntil:         u.LockedUntil,
+		DeletionScheduledAt: u.DeletionScheduledAt,
+		CreatedAt:           u.CreatedAt,
+		UpdatedAt:           u.UpdatedAt,
+	}
+}
+
+// NewAdminUser converts an account; its profile is included when it was preloaded.
+func NewAdminUser(u models.User) AdminUser {
+	user := AdminUser{
+		Account:               NewAccount(u),
+		Status:                u.Status(),
+		PasswordResetRequired: u.PasswordResetRequired,
+	}
+	if u.Suspension() != nil {
+		user.SuspendedAt, user.SuspendedUntil, user.SuspensionReason = u.SuspendedAt, u.SuspendedUntil, u.SuspensionReason
+	}
+	if u.Profile.ID != 0 {
+		profile := NewProfile(u.Profile)
+		user.Profile = &profile
+	}
+	return user
+}
+
+func NewAdminUsers(users []models.User) []AdminUser {
+	out := make([]AdminUser, len(users))
+	for i, u := range users {
+		out[i] = NewAdminUser(u)
+	}
+	return out
+}
@@ -0,0 +1,84 @@
+package dto
+
+import (
+	"final-project-rest-api/models"
+	"time"
+)
+
+type Webhook struct {
+	ID          uint      `json:"id"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	Events      []string  `json:"events"`
+	Active      bool      `json:"active"`
+	CreatedByID uint      `json:"created_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             uint       `json:"id"`
+	WebhookID      uint       `json:"webhook_id"`
+	Event          string     `json:"event"`
+	Payload        string     `json:"payload"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	LastStatusCode int        `json:"last_status_code"`
+	LastResponse   string     `json:"last_response"`
+	LastError      string     `json:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	RedeliveryOfID *uint      `json:"redelivery_of_id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func NewWebhook(w models.Webhook) Webhook {
+	return Webhook{
+		ID:          w.ID,
+		URL:         w.URL,
+		Description: w.Description,
+		Events:      w.Events,
+		Active:      w.Active,
+		CreatedByID: w.CreatedByID,
+		CreatedAt:   w.CreatedAt,
+		UpdatedAt:   w.UpdatedAt,
+	}
+}
+
+func NewWebhooks(webhooks []models.Webhook) []Webhook {
+	out := make([]Webhook, len(webhooks))
+	for i, w := range webhooks {
+		out[i] = NewWebhook(w)
+	}
+	return out
+}
+
+func NewWebhookDelivery(d models.WebhookDelivery) WebhookDelivery {
+	return WebhookDelivery{
+		ID:             d.ID,
+		WebhookID:      d.WebhookID,
+		Event:          d.Event,
+		Payload:        d.Payload,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		NextAttemptAt:  d.NextAttemptAt,
+		LastAttemptAt:  d.LastAttemptAt,
+		LastStatusCode: d.LastStatusCode,
+		LastResponse:   d.LastResponse,
+		LastError:      d.LastError,
+		DeliveredAt:    d.DeliveredAt,
+		RedeliveryOfID: d.RedeliveryOfID,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+	}
+}
+
+func NewWebhookDeliveries(deliveries []models.WebhookDelivery) []WebhookDelivery {
+	out := make([]WebhookDelivery, len(deliveries))
+	for i, d := range deliveries {
+		out[i] = NewWebhookDelivery(d)
+	}
+	return out
+}
@@ -0,0 +1,51 @@
+package events
+
+import (
+	"final-project-rest-api/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DeleteDueAccounts deletes the accounts whose deletion grace period has ended. Each
+// deleted review is published as a comment deletion.
+func DeleteDueAccounts(db *gorm.DB) error {
+	ids, err := models.DueAccountDeletions(db)
+	if err != nil {
+		return err
+	}
+
+	policy := models.DeletedReviewsPolicy()
+	for _, id := range ids {
+		err := Transaction(db, func(tx *Tx) error {
+			deleted, err := models.DeleteAccount(tx.DB, id, policy)
+			if err != nil {
+				return err
+			}
+			for _, comment := range deleted {
+				if err := tx.Publish(CommentDeleted{Comment: comment, ActorID: id}); err != nil {
+					return err
+				}
+			}
+			return tx.Publish(UserDeleted{UserID: id, ReviewsPolicy: policy, DeletedReviews: len(deleted)})
+		})
+		if err != nil {
+			log.Printf("Failed to delete account %d: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// StartAccountDeletion deletes due accounts every interval until the process exits.
+func StartAccountDeletion(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := DeleteDueAccounts(db); err != nil {
+				log.Printf("Failed to delete accounts: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,246 @@
+// Package events is the in-process domain event bus. Changes publish typed events
+// inside their database transaction; the events are stored in the outbox and handed to
+// subscribers once the transaction commits. Events left undispatched by a crash are
+// replayed on startup and failed subscribers are retried, so subscribers must tolerate
+// seeing an event more than once. Handlers get the event's ID in their database context,
+// and the notifications and webhook deliveries they send are keyed by it, so each is sent
+// once per event.
+package events
+
+import (
+	"encoding/json"
+	"final-project-rest-api/models"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// staleAfter is how long a dispatch may run before another dispatcher takes it over.
+	staleAfter = 5 * time.Minute
+
+	// An event whose subscribers fail is retried after retryBase, doubled for each
+	// attempt, until maxAttempts.
+	retryBase   = 30 * time.Second
+	maxAttempts = 8
+)
+
+type Event interface {
+	Name() string
+}
+
+// Handler reacts to an event. Errors are logged and recorded on the outbox event; they
+// don't stop other subscribers, and the event is handed to the failed ones again later.
+type Handler func(db *gorm.DB, e Event) error
+
+type subscriber struct {
+	name    string
+	handler Handler
+	async   bool
+}
+
+var (
+	mu          sync.RWMutex
+	subscribers = map[string][]subscriber{}
+	eventTypes  = map[string]reflect.Type{}
+	defaults    sync.Once
+)
+
+// Subscribe runs the handler right after the publishing transaction commits, before the
+// request returns. The subscriber name identifies the handler among the event's
+// subscribers across restarts, so it must stay the same.
+func Subscribe(event string, name string, handler Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	subscribers[event] = append(subscribers[event], subscriber{name: name, handler: handler})
+}
+
+// SubscribeAsync runs the handler in the background after the publishing transaction commits.
+func SubscribeAsync(event string, name string, handler Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	subscribers[event] = append(subscribers[event], subscriber{name: name, handler: handler, async: true})
+}
+
+func subscribersFor(name string) []subscriber {
+	defaults.Do(registerDefaults)
+	mu.RLock()
+	defer mu.RUnlock()
+	return subscribers[name]
+}
+
+// register makes an event type decodable from the outbox.
+func register(events ...Event) {
+	for _, e := range events {
+		eventTypes[e.Name()] = reflect.TypeOf(e)
+	}
+}
+
+func decode(outbox models.OutboxEvent) (Event, error) {
+	t, ok := eventTypes[outbox.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown event %q", outbox.Name)
+	}
+	v := reflect.New(t)
+	if err := json.Unmarshal([]byte(outbox.Payload), v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Elem().Interface().(Event), nil
+}
+
+type published struct {
+	outbox models.OutboxEvent
+	event  Event
+}
+
+// Tx is a database transaction that can publish events.
+type Tx struct {
+	*gorm.DB
+	published []published
+}
+
+// Publish writes the event to the outbox in this transaction. It is dispatched after commit.
+func (tx *Tx) Publish(e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	outbox := models.OutboxEvent{Name: e.Name(), Payload: string(payload), Status: models.OutboxPending, UserID: ownerOf(e)}
+	if err := tx.Create(&outbox).Error; err != nil {
+		return err
+	}
+	tx.published = append(tx.published, published{outbox: outbox, event: e})
+	return nil
+}
+
+// Transaction runs fn in a database transaction and dispatches the events it published
+// once the transaction commits. Nothing is dispatched if fn fails.
+func Transaction(db *gorm.DB, fn func(tx *Tx) error) error {
+	var events []published
+	err := db.Transaction(func(gtx *gorm.DB) error {
+		tx := &Tx{DB: gtx}
+		if err := fn(tx); err != nil {
+			return err
+		}
+		events = tx.published
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, p := range events {
+		dispatch(db, p.outbox.ID, p.event)
+	}
+	return nil
+}
+
+func dispatch(db *gorm.DB, id uint, e Event) {
+	outbox, claimed, err := models.ClaimOutboxEvent(db, id, staleAfter)
+	if err != nil {
+		log.Printf("Failed to claim event %d (%s): %v", id, e.Name(), err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	db = db.WithContext(models.WithOutboxEvent(db.Statement.Context, outbox.ID))
+
+	var (
+		resultsMu sync.Mutex
+		errs      []string
+		async     []subscriber
+	)
+	run := func(s subscriber) {
+		err := s.handler(db, e)
+		resultsMu.Lock()
+		defer resultsMu.Unlock()
+		if err != nil {
+			log.Printf("Subscriber %s of %s failed for event %d: %v", s.name, outbox.Name, outbox.ID, err)
+			errs = append(errs, s.name+": "+err.Error())
+			return
+		}
+		outbox.Completed = append(outbox.Completed, s.name)
+	}
+
+	var pending []subscriber
+	for _, s := range subscribersFor(e.Name()) {
+		if !outbox.HasCompleted(s.name) {
+			pending = append(pending, s)
+		}
+	}
+	for _, s := range pending {
+		if s.async {
+			async = append(async, s)
+			continue
+		}
+		run(s)
+	}
+
+	finish := func() {
+		var retryAt *time.Time
+		if len(errs) > 0 && outbox.Attempts+1 < maxAttempts {
+			next := time.Now().Add(retryBase << outbox.Attempts)
+			retryAt = &next
+		}
+		if err := models.FinishOutboxEvent(db, outbox, strings.Join(errs, "; "), retryAt); err != nil {
+			log.Printf("Failed to mark event %d (%s) dispatched: %v", outbox.ID, outbox.Name, err)
+		}
+	}
+	if len(async) == 0 {
+		finish()
+		return
+	}
+
+	// the async subscribers outlive the request that published the event
+	db = models.Detached(db)
+	go func() {
+		var wg sync.WaitGroup
+		for _, s := range async {
+			wg.Add(1)
+			go func(s subscriber) {
+				defer wg.Done()
+				run(s)
+			}(s)
+		}
+		wg.Wait()
+		finish()
+	}()
+}
+
+// ReplayPending dispatches the outbox events that were never dispatched, such as the
+// ones committed right before the process stopped, and retries the due failed ones.
+func ReplayPending(db *gorm.DB) error {
+	defaults.Do(registerDefaults)
+
+	outbox, err := models.UndispatchedOutboxEvents(db, staleAfter, 500)
+	if err != nil {
+		return err
+	}
+	for _, o := range outbox {
+		e, err := decode(o)
+		if err != nil {
+			log.Printf("Skipping event %d: %v", o.ID, err)
+			continue
+		}
+		dispatch(db, o.ID, e)
+	}
+	return nil
+}
+
+// Start replays undispatched events now and then every interval until the process exits.
+func Start(db *gorm.DB, interval time.Duration) {
+	go func() {
+		for {
+			if err := ReplayPending(db); err != nil {
+				log.Printf("Failed to replay events: %v", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,201 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"final-project-rest-api/models"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type testEvent struct {
+	Value string `json:"value"`
+}
+
+func (testEvent) Name() string { return "test.event" }
+
+var testDBs int
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	testDBs++
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:test%d?mode=memory&cache=shared", testDBs)), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.OutboxEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// subscribeForTest replaces the subscribers of testEvent for the duration of the test.
+func subscribeForTest(t *testing.T) {
+	defaults.Do(registerDefaults)
+	register(testEvent{})
+	t.Cleanup(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(subscribers, testEvent{}.Name())
+	})
+}
+
+func outboxEvent(t *testing.T, db *gorm.DB) models.OutboxEvent {
+	t.Helper()
+	var outbox models.OutboxEvent
+	if err := db.First(&outbox).Error; err != nil {
+		t.Fatal(err)
+	}
+	return outbox
+}
+
+func TestPublishDispatchesAfterCommit(t *testing.T) {
+	db := newTestDB(t)
+	subscribeForTest(t)
+
+	var got []string
+	Subscribe(testEvent{}.Name(), "recorder", func(db *gorm.DB, e Event) error {
+		got = append(got, e.(testEvent).Value)
+		return nil
+	})
+
+	err := Transaction(db, func(tx *Tx) error {
+		if err := tx.Publish(testEvent{Value: "a"}); err != nil {
+			return err
+		}
+		if len(got) != 0 {
+			t.Error("subscriber ran before the transaction committed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "a" {
+		t.Fatalf("subscriber got %v, want [a]", got)
+	}
+	if outbox := outboxEvent(t, db); outbox.Status != models.OutboxDispatched || outbox.Attempts != 1 {
+		t.Fatalf("outbox = %+v, want dispatched after one attempt", outbox)
+	}
+
+	// a failed transaction leaves nothing to dispatch
+	err = Transaction(db, func(tx *Tx) error {
+		if err := tx.Publish(testEvent{Value: "b"}); err != nil {
+			return err
+		}
+		return errors.New("rolled back")
+	})
+	if err == nil {
+		t.Fatal("Transaction didn't return the error")
+	}
+	var count int64
+	db.Model(&models.OutboxEvent{}).Count(&count)
+	if count != 1 || len(got) != 1 {
+		t.Fatalf("%d outbox events and %d dispatches after a rollback, want 1 and 1", count, len(got))
+	}
+}
+
+func TestFailedSubscriberIsRetried(t *testing.T) {
+	db := newTestDB(t)
+	subscribeForTest(t)
+
+	okCalls, flakyCalls := 0, 0
+	Subscribe(testEvent{}.Name(), "ok", func(db *gorm.DB, e Event) error {
+		okCalls++
+		return nil
+	})
+	Subscribe(testEvent{}.Name(), "flaky", func(db *gorm.DB, e Event) error {
+		flakyCalls++
+		if flakyCalls == 1 {
+			return errors.New("receiver down")
+		}
+		return nil
+	})
+
+	if err := Transaction(db, func(tx *Tx) error { return tx.Publish(testEvent{Value: "a"}) }); err != nil {
+		t.Fatal(err)
+	}
+	outbox := outboxEvent(t, db)
+	if outbox.Status != models.OutboxPending || outbox.NextAttemptAt == nil || !outbox.NextAttemptAt.After(time.Now()) {
+		t.Fatalf("outbox = %+v, want pending with a retry scheduled", outbox)
+	}
+	if !outbox.HasCompleted("ok") || outbox.HasCompleted("flaky") || !strings.Contains(outbox.LastError, "flaky: receiver down") {
+		t.Fatalf("outbox = %+v, want only ok completed", outbox)
+	}
+
+	// not due yet
+	if err := ReplayPending(db); err != nil {
+		t.Fatal(err)
+	}
+	if flakyCalls != 1 {
+		t.Fatalf("flaky ran %d times before its retry was due", flakyCalls)
+	}
+
+	db.Model(&models.OutboxEvent{}).Where("id = ?", outbox.ID).Update("next_attempt_at", time.Now().Add(-time.Second))
+	if err := ReplayPending(db); err != nil {
+		t.Fatal(err)
+	}
+	outbox = outboxEvent(t, db)
+	if outbox.Status != models.OutboxDispatched || outbox.Attempts != 2 || outbox.LastError != "" {
+		t.Fatalf("outbox = %+v, want dispatched on the second attempt", outbox)
+	}
+	if okCalls != 1 || flakyCalls != 2 {
+		t.Fatalf("ok ran %d times and flaky %d times, want 1 and 2", okCalls, flakyCalls)
+	}
+}
+
+func TestFailingSubscriberIsGivenUpOn(t *testing.T) {
+	db := newTestDB(t)
+	subscribeForTest(t)
+
+	Subscribe(testEvent{}.Name(), "broken", func(db *gorm.DB, e Event) error {
+		return errors.New("always fails")
+	})
+
+	if err := Transaction(db, func(tx *Tx) error { return tx.Publish(testEvent{}) }); err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&models.OutboxEvent{}).Where("id = ?", 1).Updates(map[string]interface{}{
+		"attempts":        maxAttempts - 1,
+		"next_attempt_at": time.Now().Add(-time.Second),
+	})
+	if err := ReplayPending(db); err != nil {
+		t.Fatal(err)
+	}
+	if outbox := outboxEvent(t, db); outbox.Status != models.OutboxFailed || outbox.NextAttemptAt != nil || outbox.Attempts != maxAttempts {
+		t.Fatalf("outbox = %+v, want failed after %d attempts", outbox, maxAttempts)
+	}
+}
+
+func TestWebhookDataRendersDTOs(t *testing.T) {
+	brand := models.Brand{ID: 1, BrandName: "Acme", Laptops: []models.Laptop{{Name: "Acme Book", Spec: "internal spec"}}}
+	payload, err := json.Marshal(webhookData(BrandUpdated{Brand: brand}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(payload), `{"brand":{"ID":1,"BrandName":"Acme"}}`; got != want {
+		t.Fatalf("brand payload = %s, want %s", got, want)
+	}
+
+	// events without models go out as published
+	e := UserDeleted{UserID: 1}
+	if webhookData(e) != Event(e) {
+		t.Fatal("an event without models was converted")
+	}
+}
+
+func TestOwnedEventsRecordTheirUser(t *testing.T) {
+	owner := ownerOf(CommentCreated{Comment: models.Comment{ID: 3, UserID: 7}})
+	if owner == nil || *owner != 7 {
+		t.Fatalf("owner of a review event = %v, want its author 7", owner)
+	}
+	if owner := ownerOf(LaptopCreated{Laptop: models.Laptop{Name: "Acme Book"}, ActorID: 7}); owner != nil {
+		t.Fatalf("owner of a catalog event = %d, want none", *owner)
+	}
+}
@@ -0,0 +1,79 @@
+package events
+
+import (
+	"final-project-rest-api/models"
+
+	"gorm.io/gorm"
+)
+
+func registerDefaults() {
+	register(
+		LaptopCreated{}, LaptopUpdated{}, LaptopDeleted{},
+		CommentCreated{}, CommentUpdated{}, CommentDeleted{},
+		BrandCreated{}, BrandUpdated{}, BrandDeleted{},
+		CategoryCreated{}, CategoryUpdated{}, CategoryDeleted{},
+		UserRegistered{}, UserDeleted{}, DataExportRequested{}, OwnershipReviewed{},
+	)
+
+	// Reputation is recomputed before the request returns so the author sees it right away.
+	Subscribe(models.EventCommentCreated, "reputation", func(db *gorm.DB, e Event) error {
+		return models.RecomputeReputation(db, e.(CommentCreated).Comment.UserID)
+	})
+	Subscribe(models.EventCommentUpdated, "reputation", func(db *gorm.DB, e Event) error {
+		return models.RecomputeReputation(db, e.(CommentUpdated).Comment.UserID)
+	})
+	Subscribe(models.EventCommentDeleted, "reputation", func(db *gorm.DB, e Event) error {
+		return models.RecomputeReputation(db, e.(CommentDeleted).Comment.UserID)
+	})
+	Subscribe(OwnershipReviewedEvent, "reputation", func(db *gorm.DB, e Event) error {
+		return models.RecomputeReputation(db, e.(OwnershipReviewed).Claim.UserID)
+	})
+
+	// Registration
+	SubscribeAsync(UserRegisteredEvent, "verification_email", func(db *gorm.DB, e Event) error {
+		var user models.User
+		if err := db.First(&user, e.(UserRegistered).UserID).Error; err != nil {
+			return err
+		}
+		err := models.SendVerificationEmail(db, &user)
+		if err == models.ErrAlreadyVerified || err == models.ErrVerificationThrottled {
+			// already handled, e.g. when the event is replayed
+			return nil
+		}
+		return err
+	})
+
+	// Personal data exports are prepared in the background.
+	SubscribeAsync(DataExportRequestedEvent, "data_export", func(db *gorm.DB, e Event) error {
+		return models.BuildDataExport(db, e.(DataExportRequested).ExportID)
+	})
+
+	// Alerts and notifications
+	SubscribeAsync(models.EventLaptopCreated, "new_laptop_alerts", func(db *gorm.DB, e Event) error {
+		return models.EvaluateNewLaptopAlerts(db, e.(LaptopCreated).Laptop)
+	})
+	SubscribeAsync(models.EventLaptopUpdated, "price_alerts", func(db *gorm.DB, e Event) error {
+		updated := e.(LaptopUpdated)
+		return models.EvaluatePriceAlerts(db, updated.Laptop, updated.Previous.Price)
+	})
+	SubscribeAsync(models.EventLaptopUpdated, "reviewer_notifications", func(db *gorm.DB, e Event) error {
+		updated := e.(LaptopUpdated)
+		return models.NotifyReviewersOfLaptopChange(db, updated.Laptop, updated.Laptop.ChangedFields(updated.Previous), updated.ActorID)
+	})
+	SubscribeAsync(models.EventCommentCreated, "new_review_alerts", func(db *gorm.DB, e Event) error {
+		return models.EvaluateNewReviewAlerts(db, e.(CommentCreated).Comment)
+	})
+	SubscribeAsync(models.EventCommentCreated, "reviewer_notifications", func(db *gorm.DB, e Event) error {
+		return models.NotifyReviewersOfNewReview(db, e.(CommentCreated).Comment)
+	})
+	SubscribeAsync(OwnershipReviewedEvent, "owner_notification", func(db *gorm.DB, e Event) error {
+		return models.NotifyOwnershipReviewed(db, e.(OwnershipReviewed).Claim)
+	})
+
+	// Webhooks receive the catalog and review events as the API renders them.
+	for _, name := range models.WebhookEvents {
+		SubscribeAsync(name, "webhooks", func(db *gorm.DB, e Event) error {
+			return models.PublishWebhookEvent(db, e.Name(), ownerOf(e), webhookData(e))
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package events
+
+import (
+	"final-project-rest-api/dto"
+	"final-project-rest-api/models"
+)
+
+const (
+	UserRegisteredEvent      = "user.registered"
+	UserDeletedEvent         = "user.deleted"
+	DataExportRequestedEvent = "data_export.requested"
+	OwnershipReviewedEvent   = "ownership.reviewed"
+)
+
+type LaptopCreated struct {
+	Laptop  models.Laptop `json:"laptop"`
+	ActorID uint          `json:"actor_id"`
+}
+
+type LaptopUpdated struct {
+	Laptop   models.Laptop `json:"laptop"`
+	Previous models.Laptop `json:"previous"`
+	ActorID  uint          `json:"actor_id"`
+}
+
+type LaptopDeleted struct {
+	Laptop  models.Laptop `json:"laptop"`
+	ActorID uint          `json:"actor_id"`
+}
+
+type CommentCreated struct {
+	Comment models.Comment `json:"comment"`
+}
+
+type CommentUpdated struct {
+	Comment models.Comment       `json:"comment"`
+	Changes []models.FieldChange `json:"changes"`
+	ActorID uint                 `json:"actor_id"`
+}
+
+type CommentDeleted struct {
+	Comment models.Comment `json:"comment"`
+	ActorID uint           `json:"actor_id"`
+}
+
+type BrandCreated struct {
+	Brand models.Brand `json:"brand"`
+}
+
+type BrandUpdated struct {
+	Brand models.Brand `json:"brand"`
+}
+
+type BrandDeleted struct {
+	Brand models.Brand `json:"brand"`
+}
+
+type CategoryCreated struct {
+	Category models.Category `json:"category"`
+}
+
+type CategoryUpdated struct {
+	Category models.Category `json:"category"`
+}
+
+type CategoryDeleted struct {
+	Category models.Category `json:"category"`
+}
+
+type UserRegistered struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+}
+
+type UserDeleted struct {
+	UserID         uint   `json:"user_id"`
+	ReviewsPolicy  string `json:"reviews_policy"`
+	DeletedReviews int    `json:"deleted_reviews"`
+}
+
+type DataExportRequested struct {
+	ExportID uint `json:"export_id"`
+	UserID   uint `json:"user_id"`
+}
+
+type OwnershipReviewed struct {
+	Claim      models.OwnershipClaim `json:"claim"`
+	ReviewerID uint                  `json:"reviewer_id"`
+}
+
+func (LaptopCreated) Name() string       { return models.EventLaptopCreated }
+func (LaptopUpdated) Name() string       { return models.EventLaptopUpdated }
+func (LaptopDeleted) Name() string       { return models.EventLaptopDeleted }
+func (CommentCreated) Name() string      { return models.EventCommentCreated }
+func (CommentUpdated) Name() string      { return models.EventCommentUpdated }
+func (CommentDeleted) Name() string      { return models.EventCommentDeleted }
+func (BrandCreated) Name() string        { return models.EventBrandCreated }
+func (BrandUpdated) Name() string        { return models.EventBrandUpdated }
+func (BrandDeleted) Name() string        { return models.EventBrandDeleted }
+func (CategoryCreated) Name() string     { return models.EventCategoryCreated }
+func (CategoryUpdated) Name() string     { return models.EventCategoryUpdated }
+func (CategoryDeleted) Name() string     { return models.EventCategoryDeleted }
+func (UserRegistered) Name() string      { return UserRegisteredEvent }
+func (UserDeleted) Name() string         { return UserDeletedEvent }
+func (DataExportRequested) Name() string { return DataExportRequestedEvent }
+func (OwnershipReviewed) Name() string   { return OwnershipReviewedEvent }
+
+// ownedEvent is an event holding a copy of a user's data, such as their review. Its
+// outbox row and webhook deliveries are deleted with the user's account.
+type ownedEvent interface {
+	Event
+	ownerID() uint
+}
+
+func (e CommentCreated) ownerID() uint      { return e.Comment.UserID }
+func (e CommentUpdated) ownerID() uint      { return e.Comment.UserID }
+func (e CommentDeleted) ownerID() uint      { return e.Comment.UserID }
+func (e UserRegistered) ownerID() uint      { return e.UserID }
+func (e DataExportRequested) ownerID() uint { return e.UserID }
+func (e OwnershipReviewed) ownerID() uint   { return e.Claim.UserID }
+
+// ownerOf returns the user whose data the event holds, if any.
+func ownerOf(e Event) *uint {
+	owned, ok := e.(ownedEvent)
+	if !ok {
+		return nil
+	}
+	id := owned.ownerID()
+	return &id
+}
+
+type laptopPayload struct {
+	Laptop   dto.Laptop  `json:"laptop"`
+	Previous *dto.Laptop `json:"previous,omitempty"`
+	ActorID  uint        `json:"actor_id"`
+}
+
+type commentPayload struct {
+	Comment dto.Comment          `json:"comment"`
+	Changes []models.FieldChange `json:"changes,omitempty"`
+	ActorID uint                 `json:"actor_id,omitempty"`
+}
+
+type brandPayload struct {
+	Brand dto.Brand `json:"brand"`
+}
+
+type categoryPayload struct {
+	Category dto.Category `json:"category"`
+}
+
+// webhookData is what the event's webhook deliveries carry: the models it holds for the
+// subscribers, converted to what the API renders.
+func webhookData(e Event) interface{} {
+	switch e := e.(type) {
+	case LaptopCreated:
+		return laptopPayload{Laptop: dto.NewLaptop(e.Laptop), ActorID: e.ActorID}
+	case LaptopUpdated:
+		return laptopPayload{Laptop: dto.NewLaptop(e.Laptop), Previous: dto.NewLaptopRef(&e.Previous), ActorID: e.ActorID}
+	case LaptopDeleted:
+		return laptopPayload{Laptop: dto.NewLaptop(e.Laptop), ActorID: e.ActorID}
+	case CommentCreated:
+		return commentPayload{Comment: dto.NewComment(e.Comment)}
+	case CommentUpdated:
+		return commentPayload{Comment: dto.NewComment(e.Comment), Changes: e.Changes, ActorID: e.ActorID}
+	case CommentDeleted:
+		return commentPayload{Comment: dto.NewComment(e.Comment), ActorID: e.ActorID}
+	case BrandCreated:
+		return brandPayload{Brand: dto.NewBrand(e.Brand)}
+	case BrandUpdated:
+		return brandPayload{Brand: dto.NewBrand(e.Brand)}
+	case BrandDeleted:
+		return brandPayload{Brand: dto.NewBrand(e.Brand)}
+	case CategoryCreated:
+		return categoryPayload{Category: dto.NewCategory(e.Category)}
+	case CategoryUpdated:
+		return categoryPayload{Category: dto.NewCategory(e.Category)}
+	case CategoryDeleted:
+		return categoryPayload{Category: dto.NewCategory(e.Category)}
+	}
+	return e
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils/token"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// DatabaseMiddleware sets the database handlers use, recording the changes they make in
+// the audit log as made by the request's user.
+func DatabaseMiddleware(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		audit := models.NewAuditRequest(c.ClientIP(), c.Request.Method, c.FullPath(), func() (uint, uint) {
+			userID, err := token.ExtractTokenID(c)
+			if err != nil {
+				return 0, 0
+			}
+			impersonatorID, _ := token.ExtractImpersonatorID(c)
+			return userID, impersonatorID
+		})
+		// work that outlives the request takes models.Detached, so it isn't cancelled with it
+		c.Set("db", db.WithContext(models.WithAuditRequest(c.Request.Context(), audit)))
+		c.Next()
+		// settled while the request is still live, for work that outlives it
+		audit.Actor()
+	}
+}
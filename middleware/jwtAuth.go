@@ -1,18 +1,46 @@
 package middleware
 
 import (
+	"final-project-rest-api/models"
 	"final-project-rest-api/utils/token"
 	"log"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-func JwtAuthMiddleware() gin.HandlerFunc {
+const APIKeyHeader = "X-API-Key"
+
+// authUserKey is where the auth middleware keeps the user it checked, for the middleware
+// that runs after it.
+const authUserKey = "auth_user"
+
+// JwtAuthMiddleware authenticates the request with a Bearer token. Routes that pass scopes
+// also accept an API key in the X-API-Key header, as long as the key has every one of them.
+func JwtAuthMiddleware(scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := c.MustGet("db").(*gorm.DB)
+
+		if apiKey := c.GetHeader(APIKeyHeader); apiKey != "" && c.GetHeader("Authorization") == "" {
+			userID, ok := authenticateAPIKey(c, db, apiKey, scopes)
+			if !ok {
+				c.Abort()
+				return
+			}
+			user, ok := checkUser(c, db, userID)
+			if !ok {
+				c.Abort()
+				return
+			}
+			c.Set(token.UserIDKey, userID)
+			c.Set(authUserKey, user)
+			c.Next()
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
-		log.Printf("Auth header: %s", authHeader)
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
 			c.Abort()
@@ -34,7 +62,182 @@ func JwtAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		log.Println("Token validated successfully")
+		userID, err := token.ExtractTokenID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+		issuedAt, err := token.ExtractIssuedAt(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+		user, ok := checkUser(c, db, userID)
+		if !ok {
+			c.Abort()
+			return
+		}
+		// tokens issued before the user's sessions were revoked, e.g. by a password reset
+		if user.TokensValidAfter != nil && issuedAt < user.TokensValidAfter.Unix() {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked, please log in again"})
+			c.Abort()
+			return
+		}
+
+		sessionID, err := token.ExtractSessionID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+		// tokens issued before sessions were tracked have none
+		if sessionID != 0 {
+			err := models.TouchSession(db, userID, sessionID, c.ClientIP())
+			if err == models.ErrSessionTerminated {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been terminated, please log in again"})
+				c.Abort()
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				c.Abort()
+				return
+			}
+		}
+
+		impersonatorID, err := token.ExtractImpersonatorID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+		if impersonatorID != 0 && !checkImpersonation(c, db, impersonatorID, userID) {
+			c.Abort()
+			return
+		}
+
+		c.Set(authUserKey, user)
 		c.Next()
 	}
 }
+
+// APIKeyScopeMiddleware checks API keys sent to routes that are open to everyone: the
+// request goes through anonymously without one, but a key must have the scope.
+func APIKeyScopeMiddleware(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := c.GetHeader(APIKeyHeader)
+		if apiKey == "" || c.GetHeader("Authorization") != "" {
+			c.Next()
+			return
+		}
+
+		db := c.MustGet("db").(*gorm.DB)
+		userID, ok := authenticateAPIKey(c, db, apiKey, []string{scope})
+		if !ok {
+			c.Abort()
+			return
+		}
+		user, ok := checkUser(c, db, userID)
+		if !ok {
+			c.Abort()
+			return
+		}
+		c.Set(token.UserIDKey, userID)
+		c.Set(authUserKey, user)
+		c.Next()
+	}
+}
+
+// authUser returns the user the auth middleware checked for the request.
+func authUser(c *gin.Context) (models.User, bool) {
+	user, ok := c.Get(authUserKey)
+	if !ok {
+		return models.User{}, false
+	}
+	return user.(models.User), true
+}
+
+func authenticateAPIKey(c *gin.Context, db *gorm.DB, apiKey string, scopes []string) (uint, bool) {
+	if len(scopes) == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API keys can't be used for this endpoint, use a Bearer token"})
+		return 0, false
+	}
+
+	key, err := models.AuthenticateAPIKey(db, apiKey, c.ClientIP())
+	if err == models.ErrInvalidAPIKey {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return 0, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return 0, false
+	}
+
+	for _, scope := range scopes {
+		if !key.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key is missing the " + scope + " scope"})
+			return 0, false
+		}
+	}
+	return key.UserID, true
+}
+
+// checkUser loads the authenticated user and rejects the ones who can't log in, and the
+// ones whose role requires two-factor authentication they haven't set up yet.
+func checkUser(c *gin.Context, db *gorm.DB, userID uint) (models.User, bool) {
+	var user models.User
+	if err := db.Select("id", "role", "email_verified_at", "tokens_valid_after", "two_factor_enabled_at", "suspended_at", "suspended_until", "suspension_reason", "password_reset_required").
+		First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return user, false
+	}
+
+	// API keys keep working through a suspension or a required reset unless refused here
+	if err := user.LoginAllowed(); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return user, false
+	}
+
+	// roles that must use two-factor authentication can only reach the enrollment routes until they do
+	if models.TwoFactorRequired(user.Role) && !user.TwoFactorEnabled() && !strings.HasPrefix(c.FullPath(), "/auth/2fa") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role, enable it at /auth/2fa/enroll"})
+		return user, false
+	}
+	return user, true
+}
+
+// impersonationBlocked are the routes an admin can't use while acting as someone else:
+// the account's credentials, keys, data and deletion stay the user's own.
+var impersonationBlocked = []string{"/auth/", "/api/me/api-keys", "/api/me/exports", "/api/me/deletion", "/api/me/identities"}
+
+// checkImpersonation lets an admin's impersonation token through while they are still an
+// admin, and records every request they make as the user, reads included.
+func checkImpersonation(c *gin.Context, db *gorm.DB, adminID uint, userID uint) bool {
+	var admin models.User
+	if err := db.Select("id", "role", "suspended_at", "suspended_until").First(&admin, adminID).Error; err != nil ||
+		admin.Role != models.RoleAdmin || admin.Suspension() != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Impersonation is no longer allowed, please log in again"})
+		return false
+	}
+
+	for _, prefix := range impersonationBlocked {
+		if strings.HasPrefix(c.FullPath(), prefix) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This can't be done while impersonating a user"})
+			return false
+		}
+	}
+
+	if err := models.LogAdminAction(db, models.AdminAction{
+		AdminID:      adminID,
+		TargetUserID: userID,
+		Action:       models.AdminActionImpersonatedRequest,
+		Details:      map[string]interface{}{"method": c.Request.Method, "path": c.Request.URL.Path},
+		IP:           c.ClientIP(),
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleAuthMiddleware only lets users with one of the given roles through.
+// It must run after JwtAuthMiddleware.
+func RoleAuthMiddleware(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := authUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+
+		if !user.HasRole(roles...) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access this resource"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerifiedEmailMiddleware only lets users with a verified email address through.
+// It must run after JwtAuthMiddleware.
+func VerifiedEmailMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := authUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+
+		if !user.EmailVerified() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Verify your email address first"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
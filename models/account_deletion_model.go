@@ -0,0 +1,233 @@
+package models
+
+import (
+	"errors"
+	"final-project-rest-api/utils"
+	"final-project-rest-api/utils/mailer"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DeletedUsername is the account the reviews of deleted users are kept under when
+	// they are anonymized. Nobody can register it or log in to it.
+	DeletedUsername = "deleted"
+
+	ReviewsAnonymize = "anonymize"
+	ReviewsDelete    = "delete"
+)
+
+var (
+	ErrUsernameReserved     = errors.New("this username is reserved")
+	ErrDeletionScheduled    = errors.New("the account is already scheduled for deletion")
+	ErrDeletionNotScheduled = errors.New("the account is not scheduled for deletion")
+	ErrLastAdmin            = errors.New("the only admin account can't be deleted")
+)
+
+// AccountDeletionGrace is how long a requested deletion waits, so it can be cancelled.
+// It is set with ACCOUNT_DELETION_GRACE_DAYS.
+func AccountDeletionGrace() time.Duration {
+	days, err := strconv.Atoi(utils.Getenv("ACCOUNT_DELETION_GRACE_DAYS", "14"))
+	if err != nil || days < 0 {
+		days = 14
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// DeletedReviewsPolicy is what happens to the reviews of a deleted account, set with
+// ACCOUNT_DELETION_REVIEWS: they are kept without their author, or deleted with it.
+func DeletedReviewsPolicy() string {
+	if strings.ToLower(utils.Getenv("ACCOUNT_DELETION_REVIEWS", ReviewsAnonymize)) == ReviewsDelete {
+		return ReviewsDelete
+	}
+	return ReviewsAnonymize
+}
+
+// ScheduleAccountDeletion schedules the user's account to be deleted after the grace
+// period and mails them how to cancel it.
+func ScheduleAccountDeletion(db *gorm.DB, user *User) error {
+	if user.DeletionScheduledAt != nil {
+		return ErrDeletionScheduled
+	}
+	if user.Role == RoleAdmin {
+		var admins int64
+		if err := db.Model(&User{}).Where("role = ? AND deletion_scheduled_at IS NULL", RoleAdmin).Count(&admins).Error; err != nil {
+			return err
+		}
+		if admins <= 1 {
+			return ErrLastAdmin
+		}
+	}
+
+	scheduledAt := time.Now().Add(AccountDeletionGrace())
+	res := db.Model(&User{}).Where("id = ? AND deletion_scheduled_at IS NULL", user.ID).Update("deletion_scheduled_at", scheduledAt)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrDeletionScheduled
+	}
+	user.DeletionScheduledAt = &scheduledAt
+
+	reviews := "will stay on the site without your name"
+	if DeletedReviewsPolicy() == ReviewsDelete {
+		reviews = "will be deleted too"
+	}
+	return mailer.Default().Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your account will be deleted",
+		Body: fmt.Sprintf("Hi %s,\n\nYour account is scheduled to be deleted on %s. Your reviews %s.\n\nIf you change your mind, log in before then and cancel the deletion from your account settings:\n\n%s\n\nIf it wasn't you, cancel the deletion and change your password.",
+			user.Username, scheduledAt.Format("January 2, 2006 15:04 MST"), reviews, AppURL()+"/account"),
+	})
+}
+
+// CancelAccountDeletion keeps the user's account.
+func CancelAccountDeletion(db *gorm.DB, userID uint) error {
+	res := db.Model(&User{}).Where("id = ? AND deletion_scheduled_at IS NOT NULL", userID).Update("deletion_scheduled_at", nil)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrDeletionNotScheduled
+	}
+	return nil
+}
+
+// DueAccountDeletions returns the users whose grace period has ended.
+func DueAccountDeletions(db *gorm.DB) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&User{}).Where("deletion_scheduled_at <= ?", time.Now()).Order("deletion_scheduled_at").Limit(100).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// deletedUser returns the account anonymized reviews are kept under, creating it the
+// first time. Its password isn't a valid hash, so it can't be logged in to.
+func deletedUser(db *gorm.DB) (User, error) {
+	user := User{Username: DeletedUsername, Email: DeletedUsername + "@users.invalid", Password: "!", Role: RoleUser}
+	err := db.Where("username = ?", DeletedUsername).Attrs(user).FirstOrCreate(&user).Error
+	return user, err
+}
+
+// DeleteAccount removes the user and everything stored about them. Their reviews are
+// anonymized or deleted according to the policy; it returns the deleted ones. What must
+// stay, like revisions of other reviews they edited and the admin and audit logs, is
+// attributed to the deleted user's account instead.
+func DeleteAccount(db *gorm.DB, userID uint, reviewsPolicy string) ([]Comment, error) {
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	ghost, err := deletedUser(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var comments []Comment
+	if err := db.Unscoped().Where("user_id = ?", userID).Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	commentIDs := make([]uint, len(comments))
+	for i, comment := range comments {
+		commentIDs[i] = comment.ID
+	}
+
+	var deleted []Comment
+	if len(comments) > 0 {
+		if reviewsPolicy == ReviewsDelete {
+			if err := db.Where("comment_id IN ?", commentIDs).Delete(&AspectRating{}).Error; err != nil {
+				return nil, err
+			}
+			// revisions are otherwise immutable, but their content is the user's to erase
+			if err := db.Session(&gorm.Session{SkipHooks: true}).Where("comment_id IN ?", commentIDs).Delete(&CommentRevision{}).Error; err != nil {
+				return nil, err
+			}
+			if err := db.Unscoped().Where("id IN ?", commentIDs).Delete(&Comment{}).Error; err != nil {
+				return nil, err
+			}
+			for _, comment := range comments {
+				if !comment.DeletedAt.Valid {
+					deleted = append(deleted, comment)
+				}
+			}
+		} else {
+			if err := db.Unscoped().Model(&Comment{}).Where("id IN ?", commentIDs).Update("user_id", ghost.ID).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var claims []OwnershipClaim
+	if err := db.Where("user_id = ?", userID).Find(&claims).Error; err != nil {
+		return nil, err
+	}
+
+	var collectionIDs []uint
+	if err := db.Model(&Collection{}).Where("user_id = ?", userID).Pluck("id", &collectionIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(collectionIDs) > 0 {
+		if err := db.Where("collection_id IN ?", collectionIDs).Delete(&CollectionItem{}).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// revisions are otherwise immutable, but who made them is the user's to erase
+	if err := db.Session(&gorm.Session{SkipHooks: true}).Model(&CommentRevision{}).Where("editor_id = ?", userID).
+		Update("editor_id", ghost.ID).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&AdminAction{}).Where("admin_id = ?", userID).
+		Updates(map[string]interface{}{"admin_id": ghost.ID, "ip": ""}).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&AdminAction{}).Where("target_user_id = ?", userID).
+		Updates(map[string]interface{}{"target_user_id": ghost.ID, "reason": "", "details": nil}).Error; err != nil {
+		return nil, err
+	}
+
+	// the rows the audit log has entries about, by table
+	entities := map[string][]uint{"users": {userID}, "comments": commentIDs}
+	owned := []interface{}{
+		&Collection{}, &OwnershipClaim{}, &AlertSubscription{}, &Notification{}, &NotificationPreference{}, &PrivacySetting{},
+		&Session{}, &APIKey{}, &UserIdentity{}, &RecoveryCode{}, &LoginChallenge{}, &PasswordResetToken{}, &DataExport{}, &Profile{},
+	}
+	for _, model := range owned {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+		var ids []uint
+		if err := db.Model(model).Where("user_id = ?", userID).Pluck(stmt.Schema.PrioritizedPrimaryField.DBName, &ids).Error; err != nil {
+			return nil, err
+		}
+		entities[stmt.Schema.Table] = ids
+		if err := db.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := ScrubAuditLog(db, userID, ghost.ID, entities); err != nil {
+		return nil, err
+	}
+	// events and webhook deliveries keep copies of the user's data, such as their reviews
+	if err := db.Where("user_id = ?", userID).Delete(&OutboxEvent{}).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("user_id = ?", userID).Delete(&WebhookDelivery{}).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("user_id = ? OR username = ?", userID, user.Username).Delete(&LoginAttempt{}).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Delete(&User{}, userID).Error; err != nil {
+		return nil, err
+	}
+
+	for _, claim := range claims {
+		RemoveReceipt(claim.ReceiptPath)
+	}
+	return deleted, nil
+}
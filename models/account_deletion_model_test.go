@@ -0,0 +1,125 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDeleteAccountErasesTheUser(t *testing.T) {
+	for _, policy := range []string{ReviewsAnonymize, ReviewsDelete} {
+		t.Run(policy, func(t *testing.T) {
+			db := newTestDB(t, &User{}, &Profile{}, &Comment{}, &AspectRating{}, &CommentRevision{}, &OwnershipClaim{}, &Collection{}, &CollectionItem{},
+				&AlertSubscription{}, &Notification{}, &NotificationPreference{}, &PrivacySetting{}, &Session{}, &APIKey{}, &UserIdentity{},
+				&RecoveryCode{}, &LoginChallenge{}, &PasswordResetToken{}, &DataExport{}, &LoginAttempt{}, &AdminAction{}, &AuditLog{}, &AuditChainHead{},
+				&OutboxEvent{}, &WebhookDelivery{})
+			if err := RegisterAuditCallbacks(db); err != nil {
+				t.Fatal(err)
+			}
+			if err := MigrateAuditLog(db); err != nil {
+				t.Fatal(err)
+			}
+
+			other := User{Username: "bob", Email: "bob@example.com", Password: "x"}
+			db.Create(&other)
+			otherComment := Comment{UserID: other.ID, LaptopID: 1, Content: "bob's review", Rating: 4}
+			db.Create(&otherComment)
+
+			user := User{Username: "alice", Email: "alice@example.com", Password: "x"}
+			db.Create(&user)
+			// alice's own changes, audited as made by her
+			adb := db.WithContext(WithAuditRequest(context.Background(), NewAuditRequest("203.0.113.7", "PUT", "/api/me", func() (uint, uint) {
+				return user.ID, 0
+			})))
+			adb.Create(&Profile{UserID: user.ID, Fullname: "Alice Liddell", Bio: "alice's bio"})
+			comment := Comment{UserID: user.ID, LaptopID: 1, Content: "alice's review", Rating: 5}
+			adb.Create(&comment)
+			adb.Model(&User{}).Where("id = ?", user.ID).Update("email", "alice@example.org")
+
+			// alice moderated bob's review, and an admin acted on her account
+			db.Create(&CommentRevision{CommentID: otherComment.ID, Revision: 1, EditorID: user.ID, Snapshot: otherComment.Snapshot()})
+			db.Create(&AdminAction{AdminID: other.ID, TargetUserID: user.ID, Action: AdminActionSuspend, Reason: "spam from alice@example.org", IP: "198.51.100.1"})
+
+			// copies of her review in an event and a webhook delivery
+			db.Create(&OutboxEvent{Name: EventCommentCreated, UserID: &user.ID, Payload: `{"comment":{"content":"alice's review"}}`, Status: OutboxDispatched})
+			db.Create(&WebhookDelivery{WebhookID: 1, Event: EventCommentCreated, UserID: &user.ID, Payload: `{"data":{"comment":{"content":"alice's review"}}}`, Status: DeliverySucceeded})
+
+			if err := ChainAuditLog(db); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := DeleteAccount(db, user.ID, policy); err != nil {
+				t.Fatal(err)
+			}
+			if err := ChainAuditLog(db); err != nil {
+				t.Fatal(err)
+			}
+			var ghost User
+			if err := db.Where("username = ?", DeletedUsername).First(&ghost).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			var revision CommentRevision
+			db.First(&revision)
+			if revision.EditorID != ghost.ID {
+				t.Errorf("revision editor = %d, want the deleted user %d", revision.EditorID, ghost.ID)
+			}
+
+			var action AdminAction
+			db.First(&action)
+			if action.TargetUserID != ghost.ID || action.Reason != "" {
+				t.Errorf("admin action = target %d, reason %q", action.TargetUserID, action.Reason)
+			}
+
+			var remaining int64
+			db.Model(&Comment{}).Where("id = ?", comment.ID).Count(&remaining)
+			switch policy {
+			case ReviewsDelete:
+				if remaining != 0 {
+					t.Error("the review was kept")
+				}
+			case ReviewsAnonymize:
+				var kept Comment
+				db.First(&kept, comment.ID)
+				if kept.UserID != ghost.ID {
+					t.Errorf("review author = %d, want the deleted user %d", kept.UserID, ghost.ID)
+				}
+			}
+
+			var copies int64
+			db.Model(&OutboxEvent{}).Count(&copies)
+			if copies != 0 {
+				t.Errorf("%d outbox events kept", copies)
+			}
+			db.Model(&WebhookDelivery{}).Count(&copies)
+			if copies != 0 {
+				t.Errorf("%d webhook deliveries kept", copies)
+			}
+
+			var entries []AuditLog
+			db.Find(&entries)
+			if len(entries) == 0 {
+				t.Fatal("nothing was audited")
+			}
+			logged, _ := json.Marshal(entries)
+			for _, personal := range []string{"alice@example", "Alice Liddell", "alice's bio", "alice's review", "203.0.113.7"} {
+				if strings.Contains(string(logged), personal) {
+					t.Errorf("the audit log still has %q", personal)
+				}
+			}
+			for _, entry := range entries {
+				if entry.ActorID != nil && *entry.ActorID == user.ID {
+					t.Errorf("audit entry %d is still by the deleted user", entry.ID)
+				}
+			}
+
+			status, err := VerifyAuditChain(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !status.Valid || status.Scrubbed == 0 {
+				t.Fatalf("audit chain after scrubbing = %+v", status)
+			}
+		})
+	}
+}
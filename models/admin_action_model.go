@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AdminActionSuspend             = "user.suspend"
+	AdminActionBan                 = "user.ban"
+	AdminActionUnsuspend           = "user.unsuspend"
+	AdminActionRoleChange          = "user.role_change"
+	AdminActionForcePasswordReset  = "user.force_password_reset"
+	AdminActionUnlock              = "user.unlock"
+	AdminActionImpersonate         = "user.impersonate"
+	AdminActionImpersonatedRequest = "user.impersonated_request"
+)
+
+// AdminAction records an admin acting on a user's account, including what they did while
+// impersonating them.
+type AdminAction struct {
+	ID           uint                   `gorm:"primaryKey" json:"id"`
+	AdminID      uint                   `gorm:"not null;index" json:"admin_id"`
+	TargetUserID uint                   `gorm:"not null;index" json:"target_user_id"`
+	Action       string                 `gorm:"size:40;not null;index" json:"action"`
+	Reason       string                 `gorm:"size:500" json:"reason,omitempty"`
+	Details      map[string]interface{} `gorm:"serializer:json;type:text" json:"details,omitempty"`
+	IP           string                 `gorm:"size:64" json:"ip"`
+	CreatedAt    time.Time              `gorm:"index" json:"created_at"`
+}
+
+func LogAdminAction(db *gorm.DB, action AdminAction) error {
+	return db.Create(&action).Error
+}
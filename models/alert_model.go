@@ -0,0 +1,196 @@
+package models
+
+import (
+	"errors"
+	"final-project-rest-api/utils/notify"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AlertPriceTarget = "price_target"
+	AlertPriceDrop   = "price_drop"
+	AlertNewReview   = "new_review"
+	AlertNewLaptop   = "new_laptop"
+)
+
+type AlertSubscription struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	UserID          uint       `gorm:"not null;index" json:"user_id"`
+	Type            string     `gorm:"size:30;not null;index" json:"type"`
+	LaptopID        *uint      `gorm:"index" json:"laptop_id"`
+	BrandID         *uint      `gorm:"index" json:"brand_id"`
+	TargetPrice     float64    `json:"target_price"`
+	DropPercent     float64    `json:"drop_percent"`
+	ReferencePrice  float64    `json:"reference_price"`
+	Channels        []string   `gorm:"serializer:json;type:text" json:"channels"`
+	WebhookURL      string     `gorm:"size:500" json:"webhook_url"`
+	Active          bool       `gorm:"not null;default:true" json:"active"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Validate checks that the subscription has what its type needs and prepares its
+// reference price.
+func (a *AlertSubscription) Validate(db *gorm.DB) error {
+	switch a.Type {
+	case AlertPriceTarget, AlertPriceDrop, AlertNewReview:
+		if a.LaptopID == nil {
+			return fmt.Errorf("laptop_id is required for %s alerts", a.Type)
+		}
+		var laptop Laptop
+		if err := db.First(&laptop, *a.LaptopID).Error; err != nil {
+			return errors.New("laptop not found")
+		}
+		if a.Type == AlertPriceTarget && a.TargetPrice <= 0 {
+			return errors.New("target_price must be greater than 0")
+		}
+		if a.Type == AlertPriceDrop {
+			if a.DropPercent <= 0 || a.DropPercent >= 100 {
+				return errors.New("drop_percent must be between 0 and 100")
+			}
+			a.ReferencePrice = laptop.Price
+		}
+		a.BrandID = nil
+	case AlertNewLaptop:
+		if a.BrandID == nil {
+			return errors.New("brand_id is required for new_laptop alerts")
+		}
+		var brand Brand
+		if err := db.First(&brand, *a.BrandID).Error; err != nil {
+			return errors.New("brand not found")
+		}
+		a.LaptopID = nil
+	default:
+		return fmt.Errorf("unknown alert type %q", a.Type)
+	}
+
+	if len(a.Channels) == 0 {
+		a.Channels = []string{notify.ChannelInApp}
+	}
+	for _, channel := range a.Channels {
+		switch channel {
+		case notify.ChannelInApp, notify.ChannelEmail:
+		case notify.ChannelWebhook:
+			if err := notify.CheckWebhookURL(a.WebhookURL); errors.Is(err, notify.ErrBlockedAddress) {
+				return errors.New("webhook_url must point to a public address")
+			} else if err != nil {
+				return errors.New("webhook_url must be a valid http or https URL for webhook alerts")
+			}
+		default:
+			return fmt.Errorf("unknown channel %q", channel)
+		}
+	}
+	return nil
+}
+
+func triggerAlert(db *gorm.DB, alert *AlertSubscription, title string, body string, data map[string]interface{}) error {
+	notification := Notification{
+		UserID:   alert.UserID,
+		Type:     "alert." + alert.Type,
+		Title:    title,
+		Body:     body,
+		Data:     data,
+		EventKey: eventKey(db, "alert", alert.ID),
+	}
+	if err := DeliverNotification(db, &notification, alert.Channels, alert.WebhookURL); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	alert.LastTriggeredAt = &now
+	return db.Model(alert).Select("LastTriggeredAt", "ReferencePrice").Updates(alert).Error
+}
+
+// EvaluatePriceAlerts fires the price alerts of a laptop whose price changed from oldPrice.
+func EvaluatePriceAlerts(db *gorm.DB, laptop Laptop, oldPrice float64) error {
+	if laptop.Price <= 0 || laptop.Price >= oldPrice {
+		return nil
+	}
+
+	var alerts []AlertSubscription
+	if err := db.Where("active = ? AND laptop_id = ? AND type IN ?", true, laptop.ID, []string{AlertPriceTarget, AlertPriceDrop}).Find(&alerts).Error; err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{"laptop_id": laptop.ID, "old_price": oldPrice, "new_price": laptop.Price}
+	for i := range alerts {
+		alert := &alerts[i]
+		switch alert.Type {
+		case AlertPriceTarget:
+			// only fire when the price crosses the target, not on every later change
+			if laptop.Price > alert.TargetPrice || oldPrice <= alert.TargetPrice {
+				continue
+			}
+			title := fmt.Sprintf("%s is now %.2f", laptop.Name, laptop.Price)
+			body := fmt.Sprintf("%s dropped from %.2f to %.2f, below your target price of %.2f.", laptop.Name, oldPrice, laptop.Price, alert.TargetPrice)
+			if err := triggerAlert(db, alert, title, body, data); err != nil {
+				return err
+			}
+		case AlertPriceDrop:
+			if alert.ReferencePrice <= 0 {
+				continue
+			}
+			drop := (alert.ReferencePrice - laptop.Price) / alert.ReferencePrice * 100
+			if drop < alert.DropPercent {
+				continue
+			}
+			title := fmt.Sprintf("%s dropped %.0f%% in price", laptop.Name, drop)
+			body := fmt.Sprintf("%s went from %.2f to %.2f.", laptop.Name, alert.ReferencePrice, laptop.Price)
+			alert.ReferencePrice = laptop.Price
+			if err := triggerAlert(db, alert, title, body, data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EvaluateNewReviewAlerts notifies the watchers of the reviewed laptop, except its author.
+func EvaluateNewReviewAlerts(db *gorm.DB, comment Comment) error {
+	var alerts []AlertSubscription
+	if err := db.Where("active = ? AND type = ? AND laptop_id = ? AND user_id <> ?", true, AlertNewReview, comment.LaptopID, comment.UserID).Find(&alerts).Error; err != nil || len(alerts) == 0 {
+		return err
+	}
+
+	var laptop Laptop
+	if err := db.First(&laptop, comment.LaptopID).Error; err != nil {
+		return err
+	}
+
+	title := fmt.Sprintf("New review of %s", laptop.Name)
+	body := fmt.Sprintf("A new %d-star review was posted for %s.", comment.Rating, laptop.Name)
+	data := map[string]interface{}{"laptop_id": laptop.ID, "comment_id": comment.ID, "rating": comment.Rating}
+	for i := range alerts {
+		if err := triggerAlert(db, &alerts[i], title, body, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EvaluateNewLaptopAlerts notifies the watchers of the brand of a newly added laptop.
+func EvaluateNewLaptopAlerts(db *gorm.DB, laptop Laptop) error {
+	var alerts []AlertSubscription
+	if err := db.Where("active = ? AND type = ? AND brand_id = ?", true, AlertNewLaptop, laptop.BrandID).Find(&alerts).Error; err != nil || len(alerts) == 0 {
+		return err
+	}
+
+	var brand Brand
+	if err := db.First(&brand, laptop.BrandID).Error; err != nil {
+		return err
+	}
+
+	title := fmt.Sprintf("New %s laptop: %s", brand.BrandName, laptop.Name)
+	body := fmt.Sprintf("%s was added to the catalog at %.2f.", laptop.Name, laptop.Price)
+	data := map[string]interface{}{"laptop_id": laptop.ID, "brand_id": brand.ID}
+	for i := range alerts {
+		if err := triggerAlert(db, &alerts[i], title, body, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
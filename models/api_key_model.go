@@ -0,0 +1,139 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ScopeCatalogRead  = "catalog:read"
+	ScopeCatalogWrite = "catalog:write"
+	ScopeReviewsWrite = "reviews:write"
+
+	apiKeyPrefix = "lrk_"
+
+	// last use is recorded at most this often per key
+	apiKeyTouchInterval = time.Minute
+)
+
+var APIKeyScopes = []string{ScopeCatalogRead, ScopeCatalogWrite, ScopeReviewsWrite}
+
+var ErrInvalidAPIKey = errors.New("API key is invalid, expired or revoked")
+
+// APIKey lets scripts act as its user without logging in. Only the hash of the key is
+// stored; Prefix identifies it in listings.
+type APIKey struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	Prefix     string     `gorm:"size:16;not null" json:"prefix"`
+	KeyHash    string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Scopes     []string   `gorm:"serializer:json;type:text" json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `gorm:"size:64" json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func IsAPIKeyScope(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *APIKey) Validate() error {
+	if strings.TrimSpace(k.Name) == "" {
+		return errors.New("name is required")
+	}
+	if len(k.Scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+	for _, s := range k.Scopes {
+		if !IsAPIKeyScope(s) {
+			return fmt.Errorf("unknown scope %q", s)
+		}
+	}
+	if k.ExpiresAt != nil && !k.ExpiresAt.After(time.Now()) {
+		return errors.New("expires_at must be in the future")
+	}
+	return nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey stores the key and returns the plain key, which can't be recovered later.
+func CreateAPIKey(db *gorm.DB, key *APIKey) (string, error) {
+	if err := key.Validate(); err != nil {
+		return "", err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	plain := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	key.Prefix = plain[:12]
+	key.KeyHash = hashAPIKey(plain)
+	if err := db.Create(key).Error; err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// AuthenticateAPIKey returns the live key matching plain and records its use.
+func AuthenticateAPIKey(db *gorm.DB, plain string, ip string) (APIKey, error) {
+	now := time.Now()
+	var key APIKey
+	err := db.Where("key_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", hashAPIKey(plain), now).
+		First(&key).Error
+	if err == gorm.ErrRecordNotFound {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval || key.LastUsedIP != ip {
+		if err := db.Model(&key).Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip}).Error; err != nil {
+			return APIKey{}, err
+		}
+	}
+	return key, nil
+}
+
+// RevokeAllAPIKeys stops every live key of the user from working.
+func RevokeAllAPIKeys(db *gorm.DB, userID uint) error {
+	return db.Model(&APIKey{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAPIKey stops the user's key from working. It reports false when there is no
+// such live key.
+func RevokeAPIKey(db *gorm.DB, userID uint, id uint) (bool, error) {
+	res := db.Model(&APIKey{}).Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).Update("revoked_at", time.Now())
+	return res.RowsAffected > 0, res.Error
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewPasswordsRevokeAPIKeys(t *testing.T) {
+	db := newTestDB(t, &User{}, &APIKey{}, &PasswordResetToken{}, &Session{})
+	user := User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	db.Create(&user)
+
+	for name, change := range map[string]func() error{
+		"reset": func() error {
+			db.Create(&PasswordResetToken{UserID: user.ID, TokenHash: hashResetToken("reset"), ExpiresAt: time.Now().Add(time.Hour)})
+			return ResetPassword(db, "reset", "new-password")
+		},
+		"change": func() error {
+			return ChangePassword(db, user.ID, 0, "newer-password")
+		},
+	} {
+		plain, err := CreateAPIKey(db, &APIKey{UserID: user.ID, Name: "script", Scopes: []string{ScopeCatalogRead}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := change(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := AuthenticateAPIKey(db, plain, "127.0.0.1"); err != ErrInvalidAPIKey {
+			t.Fatalf("AuthenticateAPIKey after a password %s = %v, want %v", name, err, ErrInvalidAPIKey)
+		}
+	}
+}
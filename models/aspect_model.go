@@ -0,0 +1,152 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	MinAspectScore = 1
+	MaxAspectScore = 5
+)
+
+// DefaultAspects is used by categories that don't configure their own aspects.
+var DefaultAspects = []string{"performance", "display", "keyboard", "battery", "build", "thermals", "value"}
+
+var aspectNamePattern = regexp.MustCompile(`^[a-z][a-z_]{0,49}$`)
+
+type AspectRating struct {
+	ID        uint   `gorm:"primaryKey" json:"-"`
+	CommentID uint   `gorm:"not null;index" json:"-"`
+	Aspect    string `gorm:"size:50;not null;index" json:"aspect"`
+	Score     int    `gorm:"not null" json:"score"`
+}
+
+type AspectSummary struct {
+	Aspect  string  `json:"aspect"`
+	Average float64 `json:"average"`
+	Count   int64   `json:"count"`
+}
+
+func IsValidAspectName(aspect string) bool {
+	return aspectNamePattern.MatchString(aspect)
+}
+
+func NormalizeAspects(aspects []string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, aspect := range aspects {
+		aspect = strings.ToLower(strings.TrimSpace(aspect))
+		if !IsValidAspectName(aspect) {
+			return nil, fmt.Errorf("invalid aspect name %q", aspect)
+		}
+		if seen[aspect] {
+			continue
+		}
+		seen[aspect] = true
+		normalized = append(normalized, aspect)
+	}
+	return normalized, nil
+}
+
+// BuildAspectRatings validates scores against the aspects configured for the category.
+func BuildAspectRatings(category Category, scores map[string]int) ([]AspectRating, error) {
+	allowed := map[string]bool{}
+	for _, aspect := range category.AspectList() {
+		allowed[aspect] = true
+	}
+
+	ratings := []AspectRating{}
+	for aspect, score := range scores {
+		aspect = strings.ToLower(strings.TrimSpace(aspect))
+		if !allowed[aspect] {
+			return nil, fmt.Errorf("aspect %q is not rated in category %q", aspect, category.CategoryName)
+		}
+		if score < MinAspectScore || score > MaxAspectScore {
+			return nil, fmt.Errorf("aspect %q score must be between %d and %d", aspect, MinAspectScore, MaxAspectScore)
+		}
+		ratings = append(ratings, AspectRating{Aspect: aspect, Score: score})
+	}
+	sort.Slice(ratings, func(i, j int) bool { return ratings[i].Aspect < ratings[j].Aspect })
+	return ratings, nil
+}
+
+// weightedAspectAverage weighs verified owner reviews more, see VerifiedOwnerWeight.
+var weightedAspectAverage = "SUM(aspect_ratings.score * " + reviewWeight + ") * 1.0 / SUM(" + reviewWeight + ")"
+
+func aspectScores(db *gorm.DB) *gorm.DB {
+	return db.Table("aspect_ratings").
+		Joins("JOIN comments ON comments.id = aspect_ratings.comment_id AND comments.deleted_at IS NULL").
+		Joins(verifiedOwnerJoin)
+}
+
+// FilterLaptopsByAspect keeps only laptops whose average score for aspect is at least min.
+func FilterLaptopsByAspect(query *gorm.DB, db *gorm.DB, aspect string, min float64) *gorm.DB {
+	sub := aspectScores(db).
+		Select("comments.laptop_id").
+		Where("aspect_ratings.aspect = ?", aspect).
+		Group("comments.laptop_id").
+		Having(weightedAspectAverage+" >= ?", min)
+	return query.Where("laptops.id IN (?)", sub)
+}
+
+// SortLaptopsByAspect orders laptops by their average score for aspect, unrated laptops last.
+func SortLaptopsByAspect(query *gorm.DB, db *gorm.DB, aspect string, desc bool) *gorm.DB {
+	sub := aspectScores(db).
+		Select("comments.laptop_id AS laptop_id, "+weightedAspectAverage+" AS average").
+		Where("aspect_ratings.aspect = ?", aspect).
+		Group("comments.laptop_id")
+	order := "COALESCE(aspect_sort.average, 0) ASC"
+	if desc {
+		order = "COALESCE(aspect_sort.average, 0) DESC"
+	}
+	return query.Joins("LEFT JOIN (?) AS aspect_sort ON aspect_sort.laptop_id = laptops.id", sub).Order(order)
+}
+
+// LoadLaptopAspects fills the aggregated aspect scores of each laptop.
+func LoadLaptopAspects(db *gorm.DB, laptops []Laptop) error {
+	if len(laptops) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(laptops))
+	for i, laptop := range laptops {
+		ids[i] = laptop.ID
+	}
+
+	var rows []struct {
+		LaptopID uint
+		Aspect   string
+		Average  float64
+		Count    int64
+	}
+	err := aspectScores(db).
+		Select("comments.laptop_id AS laptop_id, aspect_ratings.aspect AS aspect, "+weightedAspectAverage+" AS average, COUNT(*) AS count").
+		Where("comments.laptop_id IN ?", ids).
+		Group("comments.laptop_id, aspect_ratings.aspect").
+		Order("aspect_ratings.aspect").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	summaries := map[uint][]AspectSummary{}
+	for _, row := range rows {
+		summaries[row.LaptopID] = append(summaries[row.LaptopID], AspectSummary{
+			Aspect:  row.Aspect,
+			Average: row.Average,
+			Count:   row.Count,
+		})
+	}
+	for i := range laptops {
+		laptops[i].Aspects = summaries[laptops[i].ID]
+		if laptops[i].Aspects == nil {
+			laptops[i].Aspects = []AspectSummary{}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,703 @@
+package models
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"final-project-rest-api/utils"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+
+	auditBeforeKey = "audit:before"
+)
+
+var ErrAuditLogAppendOnly = errors.New("audit log entries can't be changed")
+
+// auditSkipped are the tables whose writes aren't audited: the audit log itself, and
+// bookkeeping written on every login or request.
+var auditSkipped = map[string]bool{
+	"audit_logs":         true,
+	"audit_chain_heads":  true,
+	"sessions":           true,
+	"login_attempts":     true,
+	"login_challenges":   true,
+	"outbox_events":      true,
+	"webhook_deliveries": true,
+	"oidc_login_states":  true,
+}
+
+// auditIgnored are the bookkeeping columns written on every login or API call. They
+// aren't recorded, and a write changing nothing else isn't audited.
+var auditIgnored = map[string]bool{
+	"api_keys.last_used_at":      true,
+	"api_keys.last_used_ip":      true,
+	"users.failed_logins":        true,
+	"users.last_failed_login_at": true,
+	"users.locked_until":         true,
+}
+
+// auditRedacted are the columns holding credentials, personal data or large blobs,
+// recorded only as having changed, so the append-only log doesn't keep them.
+var auditRedacted = map[string]bool{
+	"email":       true,
+	"bio":         true,
+	"content":     true,
+	"snapshot":    true,
+	"password":    true,
+	"totp_secret": true,
+	"key_hash":    true,
+	"token_hash":  true,
+	"state_hash":  true,
+	"code_hash":   true,
+	"secret":      true,
+	"private_key": true,
+	"share_token": true,
+	"archive":     true,
+}
+
+// AuditLog is a change to a row made while handling a request. Entries are chained by
+// ChainAuditLog, in the background: each one's hash covers the hash of its content and the
+// previous hash, so editing or removing an entry breaks the chain from there on. The hashes
+// are keyed with AUDIT_SECRET, kept out of the database, so they can't be recomputed by
+// whoever edits the rows. Entries scrubbed of a deleted user keep their content hash, so
+// the chain still holds, and their scrubbed content is checked against ScrubbedHash.
+type AuditLog struct {
+	ID             uint                   `gorm:"primaryKey" json:"id"`
+	ActorID        *uint                  `gorm:"index" json:"actor_id"`
+	ImpersonatorID *uint                  `json:"impersonator_id,omitempty"`
+	IP             string                 `gorm:"size:64" json:"ip"`
+	Method         string                 `gorm:"size:10" json:"method"`
+	Route          string                 `gorm:"size:255" json:"route"`
+	Action         string                 `gorm:"size:10;not null;index" json:"action"`
+	Entity         string                 `gorm:"size:64;not null;index:idx_audit_entity" json:"entity"`
+	EntityID       string                 `gorm:"size:64;index:idx_audit_entity" json:"entity_id"`
+	Before         map[string]interface{} `gorm:"serializer:json;type:text" json:"before"`
+	After          map[string]interface{} `gorm:"serializer:json;type:text" json:"after"`
+	CreatedAt      time.Time              `gorm:"index" json:"created_at"`
+	ScrubbedAt     *time.Time             `json:"scrubbed_at,omitempty"`
+	ScrubbedHash   string                 `gorm:"size:64;not null;default:''" json:"scrubbed_hash,omitempty"`
+	ContentHash    string                 `gorm:"size:64;not null;default:''" json:"content_hash"`
+	Seq            *uint64                `gorm:"uniqueIndex" json:"seq"`
+	PrevHash       string                 `gorm:"size:64;not null;default:''" json:"prev_hash"`
+	Hash           string                 `gorm:"size:64;not null;default:''" json:"hash"`
+}
+
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogAppendOnly
+}
+
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogAppendOnly
+}
+
+// AuditChainHead is the position and hash of the latest chained audit log entry. Only
+// ChainAuditLog writes it, locking it so that chaining runs in one process at a time.
+type AuditChainHead struct {
+	ID   uint   `gorm:"primaryKey"`
+	Seq  uint64 `gorm:"not null;default:0"`
+	Hash string `gorm:"size:64;not null;default:''"`
+}
+
+// MigrateAuditLog seeds the chain head. The entries of a chain from before it was keyed
+// are chained again from the start.
+func MigrateAuditLog(db *gorm.DB) error {
+	if db.Migrator().HasIndex(&AuditLog{}, "idx_audit_logs_hash") {
+		if err := db.Migrator().DropIndex(&AuditLog{}, "idx_audit_logs_hash"); err != nil {
+			return err
+		}
+	}
+	if err := db.FirstOrCreate(&AuditChainHead{ID: 1}).Error; err != nil {
+		return err
+	}
+	return db.Model(&AuditChainHead{}).Where("id = ? AND seq = 0", 1).Update("hash", "").Error
+}
+
+func auditSecret() []byte {
+	return []byte(utils.Getenv("AUDIT_SECRET", utils.Getenv("API_SECRET", "secret_key")))
+}
+
+func auditHMAC(data []byte) string {
+	mac := hmac.New(sha256.New, auditSecret())
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ComputeContentHash hashes what the entry records.
+func (a AuditLog) ComputeContentHash() (string, error) {
+	content, err := json.Marshal(struct {
+		ActorID        *uint                  `json:"actor_id"`
+		ImpersonatorID *uint                  `json:"impersonator_id"`
+		IP             string                 `json:"ip"`
+		Method         string                 `json:"method"`
+		Route          string                 `json:"route"`
+		Action         string                 `json:"action"`
+		Entity         string                 `json:"entity"`
+		EntityID       string                 `json:"entity_id"`
+		Before         map[string]interface{} `json:"before"`
+		After          map[string]interface{} `json:"after"`
+		CreatedAt      int64                  `json:"created_at"`
+	}{a.ActorID, a.ImpersonatorID, a.IP, a.Method, a.Route, a.Action, a.Entity, a.EntityID, a.Before, a.After, a.CreatedAt.Unix()})
+	if err != nil {
+		return "", err
+	}
+	return auditHMAC(content), nil
+}
+
+// contentIntact reports whether the entry still holds what it was hashed with when it was
+// written, or when it was last scrubbed. A scrubbed entry without a scrubbed hash isn't.
+func (a AuditLog) contentIntact() (bool, error) {
+	contentHash, err := a.ComputeContentHash()
+	if err != nil {
+		return false, err
+	}
+	if a.ScrubbedAt != nil {
+		return a.ScrubbedHash != "" && a.ScrubbedHash == contentHash, nil
+	}
+	return a.ContentHash == contentHash, nil
+}
+
+// ComputeHash hashes the entry's position and content hash with the previous entry's hash.
+func (a AuditLog) ComputeHash() string {
+	var seq uint64
+	if a.Seq != nil {
+		seq = *a.Seq
+	}
+	return auditHMAC([]byte(fmt.Sprintf("%d\n%s\n%s", seq, a.PrevHash, a.ContentHash)))
+}
+
+// AuditRequest is who made a request and how, for the changes it makes.
+type AuditRequest struct {
+	IP     string
+	Method string
+	Route  string
+
+	resolve        func() (uint, uint)
+	once           sync.Once
+	actorID        uint
+	impersonatorID uint
+}
+
+type auditKey struct{}
+
+// NewAuditRequest describes a request. resolve returns the logged-in user and the admin
+// impersonating them, if any; it is called once, when the request first changes something.
+func NewAuditRequest(ip string, method string, route string, resolve func() (uint, uint)) *AuditRequest {
+	return &AuditRequest{IP: ip, Method: method, Route: route, resolve: resolve}
+}
+
+// Actor returns the user making the request and the admin impersonating them, 0 for none.
+func (r *AuditRequest) Actor() (uint, uint) {
+	r.once.Do(func() {
+		r.actorID, r.impersonatorID = r.resolve()
+	})
+	return r.actorID, r.impersonatorID
+}
+
+// WithAuditRequest returns a context whose database writes are audited as made by the request.
+func WithAuditRequest(ctx context.Context, r *AuditRequest) context.Context {
+	return context.WithValue(ctx, auditKey{}, r)
+}
+
+// Detached returns db for work that outlives the request db was given to: its changes are
+// still audited as made by the request, and keyed by the event it handles, but aren't
+// cancelled when the request ends.
+func Detached(db *gorm.DB) *gorm.DB {
+	ctx := context.Background()
+	if r, ok := db.Statement.Context.Value(auditKey{}).(*AuditRequest); ok {
+		ctx = WithAuditRequest(ctx, r)
+	}
+	if id, ok := db.Statement.Context.Value(outboxEventKey{}).(uint); ok {
+		ctx = WithOutboxEvent(ctx, id)
+	}
+	return db.WithContext(ctx)
+}
+
+// RegisterAuditCallbacks records every create, update and delete made through db with an
+// audit request in its context.
+func RegisterAuditCallbacks(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().After("gorm:create").Register("audit:create", auditCreated); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("audit:before_update", auditLoadBefore); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register("audit:update", auditChanged(AuditUpdate)); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("audit:before_delete", auditLoadBefore); err != nil {
+		return err
+	}
+	return callbacks.Delete().After("gorm:delete").Register("audit:delete", auditChanged(AuditDelete))
+}
+
+func auditRequestOf(db *gorm.DB) (*AuditRequest, bool) {
+	if db.Error != nil || db.Statement.Schema == nil || auditSkipped[db.Statement.Schema.Table] {
+		return nil, false
+	}
+	r, ok := db.Statement.Context.Value(auditKey{}).(*AuditRequest)
+	return r, ok
+}
+
+func auditCreated(db *gorm.DB) {
+	r, ok := auditRequestOf(db)
+	if !ok {
+		return
+	}
+	for _, row := range auditSnapshots(db, db.Statement.ReflectValue) {
+		if err := appendAuditLog(db, r, AuditCreate, row.id, nil, row.values); err != nil {
+			db.AddError(err)
+			return
+		}
+	}
+}
+
+// auditLoadBefore keeps the rows an update or delete is about to change.
+func auditLoadBefore(db *gorm.DB) {
+	if _, ok := auditRequestOf(db); !ok {
+		return
+	}
+	rows, err := auditLoadRows(db, auditConditions(db))
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	db.InstanceSet(auditBeforeKey, rows)
+}
+
+func auditChanged(action string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		r, ok := auditRequestOf(db)
+		if !ok {
+			return
+		}
+		v, _ := db.InstanceGet(auditBeforeKey)
+		before, _ := v.([]auditSnapshot)
+		if len(before) == 0 {
+			return
+		}
+
+		ids := make([]interface{}, len(before))
+		for i, row := range before {
+			ids[i] = row.pk
+		}
+		pk := db.Statement.Schema.PrioritizedPrimaryField
+		after, err := auditLoadRows(db, []clause.Expression{clause.IN{Column: clause.Column{Name: pk.DBName}, Values: ids}})
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+		afterByID := map[string]map[string]interface{}{}
+		for _, row := range after {
+			afterByID[row.id] = row.values
+		}
+
+		for _, row := range before {
+			old, current := auditDiff(row.values, afterByID[row.id])
+			if old == nil && current == nil {
+				continue
+			}
+			if err := appendAuditLog(db, r, action, row.id, old, current); err != nil {
+				db.AddError(err)
+				return
+			}
+		}
+	}
+}
+
+type auditSnapshot struct {
+	pk     interface{}
+	id     string
+	values map[string]interface{}
+}
+
+// auditConditions are the conditions of the statement's update or delete, including the
+// primary keys gorm adds for the model passed to it.
+func auditConditions(db *gorm.DB) []clause.Expression {
+	stmt := db.Statement
+	var exprs []clause.Expression
+	if c, ok := stmt.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok {
+			exprs = append(exprs, where.Exprs...)
+		}
+	}
+
+	pk := stmt.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return nil
+	}
+	var ids []interface{}
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Struct:
+		if id, zero := pk.ValueOf(stmt.Context, stmt.ReflectValue); !zero {
+			ids = append(ids, id)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			if id, zero := pk.ValueOf(stmt.Context, reflect.Indirect(stmt.ReflectValue.Index(i))); !zero {
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) > 0 {
+		exprs = append(exprs, clause.IN{Column: clause.Column{Table: stmt.Table, Name: pk.DBName}, Values: ids})
+	}
+	return exprs
+}
+
+func auditLoadRows(db *gorm.DB, conditions []clause.Expression) ([]auditSnapshot, error) {
+	if len(conditions) == 0 || db.Statement.Schema.PrioritizedPrimaryField == nil {
+		return nil, nil
+	}
+	rows := reflect.New(reflect.SliceOf(db.Statement.Schema.ModelType))
+	err := db.Session(&gorm.Session{NewDB: true}).Unscoped().
+		Table(db.Statement.Table).Clauses(clause.Where{Exprs: conditions}).Find(rows.Interface()).Error
+	if err != nil {
+		return nil, err
+	}
+	return auditSnapshots(db, rows.Elem()), nil
+}
+
+// auditSnapshots reads the columns of the rows in v, a struct or a slice of them.
+func auditSnapshots(db *gorm.DB, v reflect.Value) []auditSnapshot {
+	s := db.Statement.Schema
+	if s.PrioritizedPrimaryField == nil {
+		return nil
+	}
+
+	var rows []reflect.Value
+	switch v.Kind() {
+	case reflect.Struct:
+		rows = append(rows, v)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			rows = append(rows, reflect.Indirect(v.Index(i)))
+		}
+	}
+
+	snapshots := make([]auditSnapshot, 0, len(rows))
+	for _, row := range rows {
+		if row.Kind() != reflect.Struct {
+			continue
+		}
+		pk, _ := s.PrioritizedPrimaryField.ValueOf(db.Statement.Context, row)
+		snapshots = append(snapshots, auditSnapshot{pk: pk, id: fmt.Sprint(pk), values: auditValues(db.Statement.Context, s, row)})
+	}
+	return snapshots
+}
+
+func auditValues(ctx context.Context, s *schema.Schema, row reflect.Value) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, field := range s.Fields {
+		if field.DBName == "" || auditIgnored[s.Table+"."+field.DBName] {
+			continue
+		}
+		// the Go value rather than ValueOf, which wraps serialized fields
+		rv := field.ReflectValueOf(ctx, row)
+		value := rv.Interface()
+		if auditRedacted[field.DBName] {
+			if rv.IsZero() {
+				value = ""
+			} else {
+				// stands for the value without revealing it, so changes still show
+				value = "redacted:" + auditHMAC([]byte(fmt.Sprint(value)))[:8]
+			}
+		}
+		values[field.DBName] = value
+	}
+
+	// stored as JSON, so compare and hash what will be read back
+	normalized := map[string]interface{}{}
+	if b, err := json.Marshal(values); err == nil {
+		json.Unmarshal(b, &normalized)
+	}
+	return normalized
+}
+
+// auditDiff keeps the columns that changed; a row that is gone has no after.
+func auditDiff(before map[string]interface{}, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	if after == nil {
+		return before, nil
+	}
+	old, current := map[string]interface{}{}, map[string]interface{}{}
+	for column, value := range after {
+		if column == "updated_at" || reflect.DeepEqual(before[column], value) {
+			continue
+		}
+		old[column], current[column] = before[column], value
+	}
+	if len(current) == 0 {
+		return nil, nil
+	}
+	return old, current
+}
+
+func appendAuditLog(db *gorm.DB, r *AuditRequest, action string, entityID string, before map[string]interface{}, after map[string]interface{}) error {
+	entry := AuditLog{
+		IP:        r.IP,
+		Method:    r.Method,
+		Route:     r.Route,
+		Action:    action,
+		Entity:    db.Statement.Schema.Table,
+		EntityID:  entityID,
+		Before:    before,
+		After:     after,
+		CreatedAt: time.Now().Truncate(time.Second),
+	}
+	if actorID, impersonatorID := r.Actor(); actorID != 0 {
+		entry.ActorID = &actorID
+		if impersonatorID != 0 {
+			entry.ImpersonatorID = &impersonatorID
+		}
+	}
+
+	contentHash, err := entry.ComputeContentHash()
+	if err != nil {
+		return err
+	}
+	// chained later by ChainAuditLog, so requests don't wait on each other
+	entry.ContentHash = contentHash
+	return db.Session(&gorm.Session{NewDB: true}).Create(&entry).Error
+}
+
+// ChainAuditLog appends the entries written since it last ran to the hash chain, in the
+// order they were committed.
+func ChainAuditLog(db *gorm.DB) error {
+	for {
+		var chained int
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var head AuditChainHead
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&head, 1).Error; err != nil {
+				return err
+			}
+			var entries []AuditLog
+			if err := tx.Where("seq IS NULL").Order("id").Limit(500).Find(&entries).Error; err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				seq := head.Seq + 1
+				entry.Seq = &seq
+				entry.PrevHash = head.Hash
+				entry.Hash = entry.ComputeHash()
+				if err := tx.Session(&gorm.Session{SkipHooks: true}).Model(&AuditLog{}).Where("id = ?", entry.ID).
+					Updates(map[string]interface{}{"seq": seq, "prev_hash": entry.PrevHash, "hash": entry.Hash}).Error; err != nil {
+					return err
+				}
+				head.Seq, head.Hash = seq, entry.Hash
+			}
+			chained = len(entries)
+			if chained == 0 {
+				return nil
+			}
+			return tx.Model(&head).Updates(map[string]interface{}{"seq": head.Seq, "hash": head.Hash}).Error
+		})
+		if err != nil || chained < 500 {
+			return err
+		}
+	}
+}
+
+// StartAuditChain chains new audit log entries every interval until the process exits.
+func StartAuditChain(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := ChainAuditLog(db); err != nil {
+				log.Printf("Failed to chain the audit log: %v", err)
+			}
+		}
+	}()
+}
+
+// AuditChainStatus is the result of checking the audit log's hash chain.
+type AuditChainStatus struct {
+	Valid    bool   `json:"valid"`
+	Checked  int    `json:"checked"`
+	Scrubbed int    `json:"scrubbed"`
+	Pending  int    `json:"pending"`
+	BrokenAt *uint  `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// VerifyAuditChain recomputes the hash of every chained audit log entry in order, and the
+// content hash of the entries not chained yet. The chain is broken at the first entry that
+// was edited, or that follows a removed one.
+func VerifyAuditChain(db *gorm.DB) (AuditChainStatus, error) {
+	status := AuditChainStatus{Valid: true}
+	broken := func(entry AuditLog, reason string) (AuditChainStatus, error) {
+		id := entry.ID
+		status.Valid, status.BrokenAt, status.Reason = false, &id, reason
+		return status, nil
+	}
+
+	prev := ""
+	var lastSeq uint64
+	for {
+		var entries []AuditLog
+		if err := db.Where("seq > ?", lastSeq).Order("seq").Limit(500).Find(&entries).Error; err != nil {
+			return status, err
+		}
+		if len(entries) == 0 {
+			break
+		}
+		for _, entry := range entries {
+			intact, err := entry.contentIntact()
+			if err != nil {
+				return status, err
+			}
+			switch {
+			case *entry.Seq != lastSeq+1 || entry.PrevHash != prev:
+				return broken(entry, "the entry before it was changed or removed")
+			case entry.Hash != entry.ComputeHash() || !intact:
+				return broken(entry, "the entry was changed")
+			}
+			prev, lastSeq = entry.Hash, *entry.Seq
+			status.Checked++
+			if entry.ScrubbedAt != nil {
+				status.Scrubbed++
+			}
+		}
+	}
+
+	var head AuditChainHead
+	if err := db.First(&head, 1).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return status, err
+	}
+	if head.Seq != lastSeq || head.Hash != prev {
+		status.Valid, status.Reason = false, "the latest entries were removed"
+		return status, nil
+	}
+
+	var lastID uint
+	for {
+		var entries []AuditLog
+		if err := db.Where("seq IS NULL AND id > ?", lastID).Order("id").Limit(500).Find(&entries).Error; err != nil {
+			return status, err
+		}
+		if len(entries) == 0 {
+			break
+		}
+		for _, entry := range entries {
+			intact, err := entry.contentIntact()
+			if err != nil {
+				return status, err
+			}
+			if !intact {
+				return broken(entry, "the entry was changed")
+			}
+			status.Pending++
+		}
+		lastID = entries[len(entries)-1].ID
+	}
+	return status, nil
+}
+
+// ScrubAuditLog erases a deleted user from the audit log. The changes they made are kept
+// as made by ghostID, without their IP, and the entries about their rows, given as IDs by
+// table, keep which columns changed but not the values. Each scrubbed entry's new content
+// is hashed with the same key, so it can still be checked.
+func ScrubAuditLog(db *gorm.DB, userID uint, ghostID uint, entities map[string][]uint) error {
+	var entries []AuditLog
+	if err := db.Where("actor_id = ? OR impersonator_id = ?", userID, userID).Find(&entries).Error; err != nil {
+		return err
+	}
+	scrubbed := map[uint]*AuditLog{}
+	for i := range entries {
+		scrubbed[entries[i].ID] = &entries[i]
+	}
+
+	erase := map[uint]bool{}
+	for table, ids := range entities {
+		if len(ids) == 0 {
+			continue
+		}
+		entityIDs := make([]string, len(ids))
+		for i, id := range ids {
+			entityIDs[i] = fmt.Sprint(id)
+		}
+
+		var owned []AuditLog
+		if err := db.Where("entity = ? AND entity_id IN ?", table, entityIDs).Find(&owned).Error; err != nil {
+			return err
+		}
+		for i := range owned {
+			if _, ok := scrubbed[owned[i].ID]; !ok {
+				scrubbed[owned[i].ID] = &owned[i]
+			}
+			erase[owned[i].ID] = true
+		}
+	}
+
+	tx := db.Session(&gorm.Session{SkipHooks: true})
+	now := time.Now()
+	for id, entry := range scrubbed {
+		if entry.ActorID != nil && *entry.ActorID == userID {
+			entry.ActorID, entry.IP = &ghostID, ""
+		}
+		if entry.ImpersonatorID != nil && *entry.ImpersonatorID == userID {
+			entry.ImpersonatorID, entry.IP = &ghostID, ""
+		}
+		if erase[id] {
+			entry.Before, entry.After = auditErased(entry.Before), auditErased(entry.After)
+		}
+		scrubbedHash, err := entry.ComputeContentHash()
+		if err != nil {
+			return err
+		}
+
+		// serializers don't apply to map updates
+		before, err := json.Marshal(entry.Before)
+		if err != nil {
+			return err
+		}
+		after, err := json.Marshal(entry.After)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&AuditLog{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"actor_id":        entry.ActorID,
+			"impersonator_id": entry.ImpersonatorID,
+			"ip":              entry.IP,
+			"before":          string(before),
+			"after":           string(after),
+			"scrubbed_at":     now,
+			"scrubbed_hash":   scrubbedHash,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// auditErased keeps the keys and timestamps of a snapshot, and erases everything else.
+func auditErased(values map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+	erased := make(map[string]interface{}, len(values))
+	for column, value := range values {
+		if column != "id" && !strings.HasSuffix(column, "_id") && !strings.HasSuffix(column, "_at") {
+			value = "erased"
+		}
+		erased[column] = value
+	}
+	return erased
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func newAuditTestDB(t *testing.T, models ...interface{}) (*gorm.DB, *gorm.DB) {
+	t.Helper()
+	db := newTestDB(t, append(models, &AuditLog{}, &AuditChainHead{})...)
+	if err := RegisterAuditCallbacks(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := MigrateAuditLog(db); err != nil {
+		t.Fatal(err)
+	}
+	adb := db.WithContext(WithAuditRequest(context.Background(), NewAuditRequest("203.0.113.7", "PUT", "/api/me", func() (uint, uint) {
+		return 1, 0
+	})))
+	return db, adb
+}
+
+func TestAuditLogIsChainedInTheBackground(t *testing.T) {
+	db, adb := newAuditTestDB(t, &User{})
+	user := User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	adb.Create(&user)
+	adb.Model(&user).Update("email", "alice@example.org")
+
+	status, err := VerifyAuditChain(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Valid || status.Checked != 0 || status.Pending != 2 {
+		t.Fatalf("audit chain before chaining = %+v", status)
+	}
+
+	if err := ChainAuditLog(db); err != nil {
+		t.Fatal(err)
+	}
+	status, err = VerifyAuditChain(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Valid || status.Checked != 2 || status.Pending != 0 {
+		t.Fatalf("audit chain after chaining = %+v", status)
+	}
+
+	var entries []AuditLog
+	db.Find(&entries)
+	logged, _ := json.Marshal(entries)
+	if strings.Contains(string(logged), "alice@example") {
+		t.Error("the audit log has the email address")
+	}
+}
+
+func TestAuditChainNeedsTheSecret(t *testing.T) {
+	t.Setenv("AUDIT_SECRET", "server-secret")
+	db, adb := newAuditTestDB(t, &User{})
+	user := User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	adb.Create(&user)
+	adb.Model(&user).Update("role", RoleAdmin)
+	if err := ChainAuditLog(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// rewrite an entry and recompute the chain from it without knowing the secret
+	t.Setenv("AUDIT_SECRET", "a-guess")
+	var entry AuditLog
+	db.Where("action = ?", AuditUpdate).First(&entry)
+	entry.After["role"] = RoleUser
+	contentHash, _ := entry.ComputeContentHash()
+	entry.ContentHash = contentHash
+	entry.Hash = entry.ComputeHash()
+	after, _ := json.Marshal(entry.After)
+	db.Session(&gorm.Session{SkipHooks: true}).Model(&AuditLog{}).Where("id = ?", entry.ID).
+		Updates(map[string]interface{}{"after": string(after), "content_hash": entry.ContentHash, "hash": entry.Hash})
+	db.Model(&AuditChainHead{ID: 1}).Update("hash", entry.Hash)
+
+	t.Setenv("AUDIT_SECRET", "server-secret")
+	status, err := VerifyAuditChain(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Valid || status.BrokenAt == nil || *status.BrokenAt != entry.ID {
+		t.Fatalf("audit chain after rewriting entry %d = %+v", entry.ID, status)
+	}
+}
+
+func TestBookkeepingIsNotAudited(t *testing.T) {
+	db, adb := newAuditTestDB(t, &User{}, &APIKey{})
+	user := User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	db.Create(&user)
+	plain, err := CreateAPIKey(db, &APIKey{UserID: user.ID, Name: "script", Scopes: []string{ScopeCatalogRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AuthenticateAPIKey(adb, plain, "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	adb.Model(&user).Updates(map[string]interface{}{"failed_logins": 3, "last_failed_login_at": user.CreatedAt})
+
+	var count int64
+	db.Model(&AuditLog{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("%d audit entries for bookkeeping", count)
+	}
+}
+
+func TestForgedScrubBreaksTheChain(t *testing.T) {
+	db, adb := newAuditTestDB(t, &User{})
+	user := User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	adb.Create(&user)
+	adb.Model(&user).Update("role", RoleAdmin)
+	if err := ChainAuditLog(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// passed off as scrubbed, so the rewritten content isn't checked against its hash
+	var entry AuditLog
+	db.Where("action = ?", AuditUpdate).First(&entry)
+	db.Session(&gorm.Session{SkipHooks: true}).Model(&AuditLog{}).Where("id = ?", entry.ID).
+		Updates(map[string]interface{}{"after": `{"role":"user"}`, "ip": "", "scrubbed_at": entry.CreatedAt})
+
+	status, err := VerifyAuditChain(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Valid || status.BrokenAt == nil || *status.BrokenAt != entry.ID {
+		t.Fatalf("audit chain after forging a scrub of entry %d = %+v", entry.ID, status)
+	}
+}
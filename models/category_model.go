@@ -1,7 +1,16 @@
 package models
 
 type Category struct {
-	ID           uint   `gorm:"primaryKey"`
-	CategoryName string `gorm:"not null"`
+	ID           uint     `gorm:"primaryKey"`
+	CategoryName string   `gorm:"not null"`
+	Aspects      []string `gorm:"serializer:json;type:text"`
 	Laptops      []Laptop
 }
+
+// AspectList returns the aspects reviewers can rate for laptops in this category.
+func (c Category) AspectList() []string {
+	if len(c.Aspects) == 0 {
+		return DefaultAspects
+	}
+	return c.Aspects
+}
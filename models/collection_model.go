@@ -0,0 +1,128 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultCollectionName = "wishlist"
+
+	VisibilityPrivate = "private"
+	VisibilityPublic  = "public"
+)
+
+type Collection struct {
+	ID         uint             `gorm:"primaryKey" json:"id"`
+	UserID     uint             `gorm:"not null;index" json:"user_id"`
+	Name       string           `gorm:"size:100;not null" json:"name"`
+	IsDefault  bool             `gorm:"not null;default:false" json:"is_default"`
+	DefaultFor *uint            `gorm:"uniqueIndex" json:"-"`
+	Visibility string           `gorm:"size:20;not null;default:private" json:"visibility"`
+	ShareToken string           `gorm:"size:64;not null;uniqueIndex" json:"share_token"`
+	Items      []CollectionItem `gorm:"foreignKey:CollectionID" json:"items"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+}
+
+type CollectionItem struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CollectionID uint      `gorm:"not null;uniqueIndex:idx_collection_laptop" json:"collection_id"`
+	LaptopID     uint      `gorm:"not null;uniqueIndex:idx_collection_laptop" json:"laptop_id"`
+	Note         string    `gorm:"size:500" json:"note"`
+	Position     int       `gorm:"not null;default:0" json:"position"`
+	Laptop       *Laptop   `gorm:"foreignKey:LaptopID" json:"laptop,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func GenerateShareToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// OrderedItems preloads collection items by their position.
+func OrderedItems(db *gorm.DB) *gorm.DB {
+	return db.Order("position, id")
+}
+
+func (c *Collection) Validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+// EnsureDefaultCollection returns the user's wishlist, creating it on first use.
+// DefaultFor holds the user ID on the wishlist only, so its unique index keeps two
+// concurrent first requests from each creating one.
+func EnsureDefaultCollection(db *gorm.DB, userID uint) (Collection, error) {
+	var collection Collection
+	err := db.Where("user_id = ? AND is_default = ?", userID, true).First(&collection).Error
+	if err != gorm.ErrRecordNotFound {
+		return collection, err
+	}
+
+	shareToken, err := GenerateShareToken()
+	if err != nil {
+		return collection, err
+	}
+	collection = Collection{
+		UserID:     userID,
+		Name:       DefaultCollectionName,
+		IsDefault:  true,
+		DefaultFor: &userID,
+		Visibility: VisibilityPrivate,
+		ShareToken: shareToken,
+	}
+	if err := db.Create(&collection).Error; err != nil {
+		// another request created it first
+		var existing Collection
+		if db.Where("user_id = ? AND is_default = ?", userID, true).First(&existing).Error == nil {
+			return existing, nil
+		}
+		return Collection{}, err
+	}
+	return collection, nil
+}
+
+// MigrateDefaultCollections marks each user's wishlist in DefaultFor when the column is
+// added. Users who already ended up with several keep the oldest as their wishlist.
+func MigrateDefaultCollections(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var collections []Collection
+		if err := tx.Where("is_default = ?", true).Order("id").Find(&collections).Error; err != nil {
+			return err
+		}
+		seen := map[uint]bool{}
+		for _, collection := range collections {
+			updates := map[string]interface{}{"default_for": collection.UserID}
+			if seen[collection.UserID] {
+				updates = map[string]interface{}{"is_default": false}
+			}
+			seen[collection.UserID] = true
+			if err := tx.Model(&Collection{}).Where("id = ?", collection.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// NextItemPosition returns the position for an item appended to the collection.
+func NextItemPosition(db *gorm.DB, collectionID uint) (int, error) {
+	var position int
+	err := db.Model(&CollectionItem{}).
+		Select("COALESCE(MAX(position), 0) + 1").
+		Where("collection_id = ?", collectionID).
+		Scan(&position).Error
+	return position, err
+}
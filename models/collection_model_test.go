@@ -0,0 +1,60 @@
+package models
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestOneDefaultCollectionPerUser(t *testing.T) {
+	db := newTestDB(t, &User{}, &Collection{})
+	alice := User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	db.Create(&alice)
+
+	// another request creates the wishlist between the lookup and the insert
+	raced := false
+	db.Callback().Query().After("gorm:query").Register("race_default_collection", func(*gorm.DB) {
+		if raced {
+			return
+		}
+		raced = true
+		if err := db.Create(&Collection{UserID: alice.ID, Name: DefaultCollectionName, IsDefault: true, DefaultFor: &alice.ID, ShareToken: "raced"}).Error; err != nil {
+			t.Error(err)
+		}
+	})
+
+	collection, err := EnsureDefaultCollection(db, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collection.ShareToken != "raced" {
+		t.Fatalf("EnsureDefaultCollection = %+v, want the wishlist created by the other request", collection)
+	}
+	var defaults int64
+	db.Model(&Collection{}).Where("user_id = ? AND is_default = ?", alice.ID, true).Count(&defaults)
+	if defaults != 1 {
+		t.Fatalf("%d default collections", defaults)
+	}
+}
+
+func TestMigrateDefaultCollectionsKeepsTheOldest(t *testing.T) {
+	db := newTestDB(t, &User{}, &Collection{})
+	alice := User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	db.Create(&alice)
+	first := Collection{UserID: alice.ID, Name: DefaultCollectionName, IsDefault: true, ShareToken: "first"}
+	second := Collection{UserID: alice.ID, Name: DefaultCollectionName, IsDefault: true, ShareToken: "second"}
+	db.Create(&first)
+	db.Create(&second)
+
+	if err := MigrateDefaultCollections(db); err != nil {
+		t.Fatal(err)
+	}
+	collection, err := EnsureDefaultCollection(db, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.First(&second, second.ID)
+	if collection.ID != first.ID || collection.DefaultFor == nil || second.IsDefault {
+		t.Fatalf("wishlist %+v, other collection %+v", collection, second)
+	}
+}
@@ -1,18 +1,153 @@
 package models
 
 import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+const topHighlightsLimit = 5
+
+// UseCases are the tags a reviewer can pick to describe how they use the laptop.
+var UseCases = []string{"gaming", "dev", "office", "creative", "student", "travel"}
+
 type Comment struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	UserID    uint           `gorm:"not null" json:"user_id"`
-	LaptopID  uint           `gorm:"not null" json:"laptop_id"`
-	Content   string         `gorm:"not null" json:"content"`
-	Rating    int            `gorm:"not null" json:"rating"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	UserID        uint           `gorm:"not null" json:"user_id"`
+	LaptopID      uint           `gorm:"not null" json:"laptop_id"`
+	Title         string         `gorm:"size:120" json:"title"`
+	Content       string         `gorm:"not null" json:"content"`
+	Rating        int            `gorm:"not null" json:"rating"`
+	Pros          []string       `gorm:"serializer:json;type:text" json:"pros"`
+	Cons          []string       `gorm:"serializer:json;type:text" json:"cons"`
+	UsageMonths   int            `json:"usage_months"`
+	UseCases      []string       `gorm:"serializer:json;type:text" json:"use_cases"`
+	AspectRatings []AspectRating `gorm:"foreignKey:CommentID" json:"aspect_ratings"`
+	User          *User          `gorm:"foreignKey:UserID" json:"-"`
+	VerifiedOwner bool           `gorm:"-" json:"verified_owner"`
+	Edited        bool           `json:"edited"`
+	EditedAt      *time.Time     `json:"edited_at"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+type ReviewStats struct {
+	ReviewCount   int64   `json:"review_count"`
+	AverageRating float64 `json:"average_rating"`
+}
+
+// UserReviewStats counts the user's reviews and averages the ratings they gave.
+func UserReviewStats(db *gorm.DB, userID uint) (ReviewStats, error) {
+	var stats ReviewStats
+	err := db.Model(&Comment{}).Select("COUNT(*) AS review_count, COALESCE(AVG(rating), 0) AS average_rating").
+		Where("user_id = ?", userID).Scan(&stats).Error
+	stats.AverageRating = math.Round(stats.AverageRating*100) / 100
+	return stats, err
+}
+
+type Highlight struct {
+	Text  string `json:"text"`
+	Count int    `json:"count"`
+}
+
+// NormalizeHighlights trims pros/cons and drops empty or duplicate entries.
+func NormalizeHighlights(items []string) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, item := range items {
+		item = strings.Join(strings.Fields(item), " ")
+		key := strings.ToLower(item)
+		if item == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, item)
+	}
+	return normalized
+}
+
+func NormalizeUseCases(useCases []string) ([]string, error) {
+	allowed := map[string]bool{}
+	for _, useCase := range UseCases {
+		allowed[useCase] = true
+	}
+
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, useCase := range useCases {
+		useCase = strings.ToLower(strings.TrimSpace(useCase))
+		if !allowed[useCase] {
+			return nil, fmt.Errorf("unknown use case %q, expected one of %s", useCase, strings.Join(UseCases, ", "))
+		}
+		if seen[useCase] {
+			continue
+		}
+		seen[useCase] = true
+		normalized = append(normalized, useCase)
+	}
+	return normalized, nil
+}
+
+func topHighlights(counts map[string]*Highlight) []Highlight {
+	highlights := make([]Highlight, 0, len(counts))
+	for _, highlight := range counts {
+		highlights = append(highlights, *highlight)
+	}
+	sort.Slice(highlights, func(i, j int) bool {
+		if highlights[i].Count != highlights[j].Count {
+			return highlights[i].Count > highlights[j].Count
+		}
+		return highlights[i].Text < highlights[j].Text
+	})
+	if len(highlights) > topHighlightsLimit {
+		highlights = highlights[:topHighlightsLimit]
+	}
+	return highlights
+}
+
+// LoadLaptopHighlights fills the most mentioned pros and cons of each laptop.
+func LoadLaptopHighlights(db *gorm.DB, laptops []Laptop) error {
+	if len(laptops) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(laptops))
+	for i, laptop := range laptops {
+		ids[i] = laptop.ID
+	}
+
+	var comments []Comment
+	if err := db.Select("id", "laptop_id", "pros", "cons").Where("laptop_id IN ?", ids).Find(&comments).Error; err != nil {
+		return err
+	}
+
+	pros := map[uint]map[string]*Highlight{}
+	cons := map[uint]map[string]*Highlight{}
+	count := func(counts map[uint]map[string]*Highlight, laptopID uint, items []string) {
+		if counts[laptopID] == nil {
+			counts[laptopID] = map[string]*Highlight{}
+		}
+		for _, item := range items {
+			key := strings.ToLower(item)
+			if counts[laptopID][key] == nil {
+				counts[laptopID][key] = &Highlight{Text: item}
+			}
+			counts[laptopID][key].Count++
+		}
+	}
+	for _, comment := range comments {
+		count(pros, comment.LaptopID, comment.Pros)
+		count(cons, comment.LaptopID, comment.Cons)
+	}
+
+	for i := range laptops {
+		laptops[i].TopPros = topHighlights(pros[laptops[i].ID])
+		laptops[i].TopCons = topHighlights(cons[laptops[i].ID])
+	}
+	return nil
 }
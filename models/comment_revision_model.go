@@ -0,0 +1,140 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrImmutableRevision = errors.New("comment revisions can't be changed")
+
+// CommentSnapshot is the reviewable content of a comment at a point in time.
+type CommentSnapshot struct {
+	Title       string         `json:"title"`
+	Content     string         `json:"content"`
+	Rating      int            `json:"rating"`
+	Pros        []string       `json:"pros"`
+	Cons        []string       `json:"cons"`
+	UsageMonths int            `json:"usage_months"`
+	UseCases    []string       `json:"use_cases"`
+	Aspects     map[string]int `json:"aspects"`
+}
+
+type CommentRevision struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	CommentID uint            `gorm:"not null;uniqueIndex:idx_comment_revision" json:"comment_id"`
+	Revision  int             `gorm:"not null;uniqueIndex:idx_comment_revision" json:"revision"`
+	EditorID  uint            `gorm:"not null" json:"editor_id"`
+	Snapshot  CommentSnapshot `gorm:"serializer:json;type:text" json:"snapshot"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// Revisions are an append-only log.
+func (r *CommentRevision) BeforeUpdate(tx *gorm.DB) error {
+	return ErrImmutableRevision
+}
+
+func (r *CommentRevision) BeforeDelete(tx *gorm.DB) error {
+	return ErrImmutableRevision
+}
+
+func (c Comment) Snapshot() CommentSnapshot {
+	aspects := map[string]int{}
+	for _, rating := range c.AspectRatings {
+		aspects[rating.Aspect] = rating.Score
+	}
+	return CommentSnapshot{
+		Title:       c.Title,
+		Content:     c.Content,
+		Rating:      c.Rating,
+		Pros:        nonNil(c.Pros),
+		Cons:        nonNil(c.Cons),
+		UsageMonths: c.UsageMonths,
+		UseCases:    nonNil(c.UseCases),
+		Aspects:     aspects,
+	}
+}
+
+func nonNil(items []string) []string {
+	if items == nil {
+		return []string{}
+	}
+	return items
+}
+
+// RecordCommentRevision appends the current state of the comment to its revision log.
+// Comments written before revisions were tracked get their original state recorded first.
+func RecordCommentRevision(tx *gorm.DB, original *Comment, current Comment, editorID uint) error {
+	var latest CommentRevision
+	err := tx.Where("comment_id = ?", current.ID).Order("revision DESC").First(&latest).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+
+	if err == gorm.ErrRecordNotFound && original != nil {
+		latest = CommentRevision{
+			CommentID: original.ID,
+			Revision:  1,
+			EditorID:  original.UserID,
+			Snapshot:  original.Snapshot(),
+			CreatedAt: original.CreatedAt,
+		}
+		if err := tx.Create(&latest).Error; err != nil {
+			return err
+		}
+	}
+
+	revision := CommentRevision{
+		CommentID: current.ID,
+		Revision:  latest.Revision + 1,
+		EditorID:  editorID,
+		Snapshot:  current.Snapshot(),
+	}
+	return tx.Create(&revision).Error
+}
+
+// DiffSnapshots lists the fields that differ between two snapshots.
+func DiffSnapshots(from, to CommentSnapshot) ([]FieldChange, error) {
+	fromFields, err := snapshotFields(from)
+	if err != nil {
+		return nil, err
+	}
+	toFields, err := snapshotFields(to)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(toFields))
+	for field := range toFields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	changes := []FieldChange{}
+	for _, field := range fields {
+		if !reflect.DeepEqual(fromFields[field], toFields[field]) {
+			changes = append(changes, FieldChange{Field: field, From: fromFields[field], To: toFields[field]})
+		}
+	}
+	return changes, nil
+}
+
+func snapshotFields(snapshot CommentSnapshot) (map[string]interface{}, error) {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
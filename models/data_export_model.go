@@ -0,0 +1,301 @@
+package models
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"final-project-rest-api/utils/notify"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ExportFormatZIP  = "zip"
+	ExportFormatJSON = "json"
+
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+
+	NotificationDataExportReady = "account.data_export_ready"
+
+	// dataExportTTL is how long a finished archive can be downloaded.
+	dataExportTTL = 7 * 24 * time.Hour
+	// dataExportInterval is the minimum time between two exports of the same account.
+	dataExportInterval = 24 * time.Hour
+)
+
+var (
+	ErrDataExportPending   = errors.New("an export of your data is already being prepared")
+	ErrDataExportThrottled = errors.New("your data was exported recently, please wait a day before requesting another export")
+	ErrDataExportFormat    = fmt.Errorf("format must be %s or %s", ExportFormatZIP, ExportFormatJSON)
+)
+
+// DataExport is a copy of everything stored about a user, prepared in the background.
+type DataExport struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"-"`
+	Format      string     `gorm:"size:10;not null" json:"format"`
+	Status      string     `gorm:"size:20;not null;index" json:"status"`
+	Archive     []byte     `json:"-"`
+	Size        int        `json:"size"`
+	Error       string     `gorm:"size:255" json:"error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ExpiresAt   *time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Filename is the name the archive is downloaded as.
+func (e DataExport) Filename() string {
+	return fmt.Sprintf("data-export-%d-%s.%s", e.ID, e.CreatedAt.Format("20060102"), e.Format)
+}
+
+// PersonalData is everything stored about a user, as included in their export.
+type PersonalData struct {
+	Account                 ExportedAccount          `json:"account"`
+	Profile                 *Profile                 `json:"profile"`
+	Reviews                 []Comment                `json:"reviews"`
+	ReviewRevisions         []CommentRevision        `json:"review_revisions"`
+	OwnershipClaims         []OwnershipClaim         `json:"ownership_claims"`
+	Collections             []Collection             `json:"collections"`
+	Alerts                  []AlertSubscription      `json:"alerts"`
+	Notifications           []Notification           `json:"notifications"`
+	NotificationPreferences []NotificationPreference `json:"notification_preferences"`
+	PrivacySettings         []PrivacySetting         `json:"privacy_settings"`
+	Sessions                []Session                `json:"sessions"`
+	LinkedLogins            []UserIdentity           `json:"linked_logins"`
+	APIKeys                 []APIKey                 `json:"api_keys"`
+	LoginAttempts           []LoginAttempt           `json:"login_attempts"`
+	ExportedAt              time.Time                `json:"exported_at"`
+}
+
+// ExportedAccount is the account itself, without its credentials.
+type ExportedAccount struct {
+	ID                  uint       `json:"id"`
+	Username            string     `json:"username"`
+	Email               string     `json:"email"`
+	Role                string     `json:"role"`
+	Reputation          int        `json:"reputation"`
+	ReputationLevel     string     `json:"reputation_level"`
+	Badges              []string   `json:"badges"`
+	EmailVerifiedAt     *time.Time `json:"email_verified_at"`
+	TwoFactorEnabledAt  *time.Time `json:"two_factor_enabled_at"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// CollectPersonalData gathers everything stored about the user.
+func CollectPersonalData(db *gorm.DB, userID uint) (PersonalData, error) {
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		return PersonalData{}, err
+	}
+
+	data := PersonalData{
+		Account: ExportedAccount{
+			ID:                  user.ID,
+			Username:            user.Username,
+			Email:               user.Email,
+			Role:                user.Role,
+			Reputation:          user.Reputation,
+			ReputationLevel:     user.ReputationLevel,
+			Badges:              user.Badges,
+			EmailVerifiedAt:     user.EmailVerifiedAt,
+			TwoFactorEnabledAt:  user.TwoFactorEnabledAt,
+			DeletionScheduledAt: user.DeletionScheduledAt,
+			CreatedAt:           user.CreatedAt,
+			UpdatedAt:           user.UpdatedAt,
+		},
+		ExportedAt: time.Now(),
+	}
+
+	var profile Profile
+	err := db.Where("user_id = ?", userID).First(&profile).Error
+	if err == nil {
+		data.Profile = &profile
+	} else if err != gorm.ErrRecordNotFound {
+		return PersonalData{}, err
+	}
+
+	if err := db.Preload("AspectRatings").Where("user_id = ?", userID).Order("id").Find(&data.Reviews).Error; err != nil {
+		return PersonalData{}, err
+	}
+	if err := db.Where("editor_id = ?", userID).Order("comment_id, revision").Find(&data.ReviewRevisions).Error; err != nil {
+		return PersonalData{}, err
+	}
+
+	queries := []struct {
+		dest  interface{}
+		query *gorm.DB
+	}{
+		{&data.OwnershipClaims, db.Where("user_id = ?", userID)},
+		{&data.Collections, db.Preload("Items").Where("user_id = ?", userID)},
+		{&data.Alerts, db.Where("user_id = ?", userID)},
+		{&data.Notifications, db.Where("user_id = ?", userID)},
+		{&data.NotificationPreferences, db.Where("user_id = ?", userID)},
+		{&data.PrivacySettings, db.Where("user_id = ?", userID)},
+		{&data.Sessions, db.Where("user_id = ?", userID)},
+		{&data.LinkedLogins, db.Where("user_id = ?", userID)},
+		{&data.APIKeys, db.Where("user_id = ?", userID)},
+		{&data.LoginAttempts, db.Where("user_id = ?", userID)},
+	}
+	for _, q := range queries {
+		if err := q.query.Order("id").Find(q.dest).Error; err != nil {
+			return PersonalData{}, err
+		}
+	}
+	return data, nil
+}
+
+// RequestDataExport queues an export of the user's data in the given format. Exports are
+// limited to one in progress and one a day.
+func RequestDataExport(db *gorm.DB, userID uint, format string) (DataExport, error) {
+	if format == "" {
+		format = ExportFormatZIP
+	}
+	if format != ExportFormatZIP && format != ExportFormatJSON {
+		return DataExport{}, ErrDataExportFormat
+	}
+
+	// expired archives are cleaned up as new ones are requested
+	if err := db.Where("expires_at <= ?", time.Now()).Delete(&DataExport{}).Error; err != nil {
+		return DataExport{}, err
+	}
+
+	var last DataExport
+	err := db.Omit("archive").Where("user_id = ? AND status <> ?", userID, ExportFailed).Order("created_at DESC").First(&last).Error
+	if err == nil {
+		if last.Status == ExportPending {
+			return DataExport{}, ErrDataExportPending
+		}
+		if time.Since(last.CreatedAt) < dataExportInterval {
+			return DataExport{}, ErrDataExportThrottled
+		}
+	} else if err != gorm.ErrRecordNotFound {
+		return DataExport{}, err
+	}
+
+	export := DataExport{UserID: userID, Format: format, Status: ExportPending}
+	if err := db.Create(&export).Error; err != nil {
+		return DataExport{}, err
+	}
+	return export, nil
+}
+
+// BuildDataExport prepares the archive of a pending export and notifies its user that it
+// can be downloaded.
+func BuildDataExport(db *gorm.DB, exportID uint) error {
+	var export DataExport
+	if err := db.Omit("archive").First(&export, exportID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+	if export.Status != ExportPending {
+		return nil
+	}
+
+	archive, err := buildArchive(db, export)
+	if err != nil {
+		log.Printf("Failed to export the data of user %d: %v", export.UserID, err)
+		return db.Model(&export).Updates(map[string]interface{}{"status": ExportFailed, "error": "Failed to prepare the export"}).Error
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(dataExportTTL)
+	if err := db.Model(&export).Updates(map[string]interface{}{
+		"status":       ExportReady,
+		"archive":      archive,
+		"size":         len(archive),
+		"completed_at": now,
+		"expires_at":   expiresAt,
+	}).Error; err != nil {
+		return err
+	}
+
+	notification := Notification{
+		UserID: export.UserID,
+		Type:   NotificationDataExportReady,
+		Title:  "Your data export is ready",
+		Body:   fmt.Sprintf("You can download it until %s.", expiresAt.Format("January 2, 2006")),
+		Data:   map[string]interface{}{"export_id": export.ID},
+	}
+	return DeliverNotification(db, &notification, []string{notify.ChannelInApp, notify.ChannelEmail}, "")
+}
+
+func buildArchive(db *gorm.DB, export DataExport) ([]byte, error) {
+	data, err := CollectPersonalData(db, export.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if export.Format == ExportFormatJSON {
+		return json.MarshalIndent(data, "", "  ")
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{"account.json", data.Account},
+		{"profile.json", data.Profile},
+		{"reviews.json", data.Reviews},
+		{"review_revisions.json", data.ReviewRevisions},
+		{"ownership_claims.json", data.OwnershipClaims},
+		{"collections.json", data.Collections},
+		{"alerts.json", data.Alerts},
+		{"notifications.json", data.Notifications},
+		{"notification_preferences.json", data.NotificationPreferences},
+		{"privacy_settings.json", data.PrivacySettings},
+		{"security/sessions.json", data.Sessions},
+		{"security/linked_logins.json", data.LinkedLogins},
+		{"security/api_keys.json", data.APIKeys},
+		{"security/login_attempts.json", data.LoginAttempts},
+	}
+	for _, file := range files {
+		content, err := json.MarshalIndent(file.v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		w, err := archive.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(content); err != nil {
+			return nil, err
+		}
+	}
+
+	// the receipts the user uploaded as proof of ownership
+	for _, claim := range data.OwnershipClaims {
+		if claim.ReceiptPath == "" {
+			continue
+		}
+		receipt, err := os.ReadFile(claim.ReceiptPath)
+		if err != nil {
+			log.Printf("Leaving receipt of ownership claim %d out of export %d: %v", claim.ID, export.ID, err)
+			continue
+		}
+		w, err := archive.Create(fmt.Sprintf("receipts/claim-%d%s", claim.ID, filepath.Ext(claim.ReceiptPath)))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(receipt); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
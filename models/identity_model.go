@@ -0,0 +1,186 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"final-project-rest-api/utils/oidc"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const oidcLoginTTL = 10 * time.Minute
+
+var (
+	ErrInvalidOIDCState     = errors.New("login request is invalid or has expired, please try again")
+	ErrOIDCEmailMissing     = errors.New("the provider didn't share a verified email address")
+	ErrOIDCAccountNotLinked = errors.New("an account with this email address exists; log in with your password and verify your email address to link it")
+)
+
+// UserIdentity links a user to their account at an OpenID Connect provider.
+type UserIdentity struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	Provider    string     `gorm:"size:50;not null;uniqueIndex:idx_user_identity" json:"provider"`
+	Subject     string     `gorm:"size:255;not null;uniqueIndex:idx_user_identity" json:"subject"`
+	Email       string     `gorm:"size:255" json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// OIDCLoginState carries a login from the redirect to the provider to its callback.
+type OIDCLoginState struct {
+	ID           uint      `gorm:"primaryKey"`
+	StateHash    string    `gorm:"size:64;not null;uniqueIndex"`
+	Provider     string    `gorm:"size:50;not null"`
+	CodeVerifier string    `gorm:"size:100;not null"`
+	Nonce        string    `gorm:"size:100;not null"`
+	RedirectTo   string    `gorm:"size:500"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+	CreatedAt    time.Time
+}
+
+func hashOIDCState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
+
+// BeginOIDCLogin stores a new login with the provider and returns it with its state.
+func BeginOIDCLogin(db *gorm.DB, provider string, redirectTo string) (OIDCLoginState, string, error) {
+	state, err := oidc.RandomString()
+	if err != nil {
+		return OIDCLoginState{}, "", err
+	}
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		return OIDCLoginState{}, "", err
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return OIDCLoginState{}, "", err
+	}
+
+	// abandoned logins are cleaned up as new ones start
+	if err := db.Where("expires_at <= ?", time.Now()).Delete(&OIDCLoginState{}).Error; err != nil {
+		return OIDCLoginState{}, "", err
+	}
+
+	login := OIDCLoginState{
+		StateHash:    hashOIDCState(state),
+		Provider:     provider,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		RedirectTo:   redirectTo,
+		ExpiresAt:    time.Now().Add(oidcLoginTTL),
+	}
+	if err := db.Create(&login).Error; err != nil {
+		return OIDCLoginState{}, "", err
+	}
+	return login, state, nil
+}
+
+// ConsumeOIDCLogin returns the login with the given state and deletes it, so a callback
+// can only be completed once.
+func ConsumeOIDCLogin(db *gorm.DB, provider string, state string) (OIDCLoginState, error) {
+	var login OIDCLoginState
+	err := db.Where("state_hash = ? AND provider = ? AND expires_at > ?", hashOIDCState(state), provider, time.Now()).First(&login).Error
+	if err != nil {
+		return OIDCLoginState{}, ErrInvalidOIDCState
+	}
+	res := db.Delete(&OIDCLoginState{}, login.ID)
+	if res.Error != nil {
+		return OIDCLoginState{}, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return OIDCLoginState{}, ErrInvalidOIDCState
+	}
+	return login, nil
+}
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// availableUsername derives a free username from the provider's claims.
+func availableUsername(db *gorm.DB, claims oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = usernameInvalidChars.ReplaceAllString(base, "")
+	if base == "" || strings.EqualFold(base, DeletedUsername) {
+		base = "user"
+	}
+
+	username := base
+	for i := 2; ; i++ {
+		var count int64
+		if err := db.Model(&User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return username, nil
+		}
+		username = fmt.Sprintf("%s%d", base, i)
+	}
+}
+
+// LinkOIDCIdentity returns the user for a provider login: the user already linked to it,
+// or the user with the same verified email address, who gets linked, or else a new user.
+// created is true for a new user.
+func LinkOIDCIdentity(db *gorm.DB, provider string, claims oidc.Claims) (user User, created bool, err error) {
+	now := time.Now()
+
+	var identity UserIdentity
+	err = db.Where("provider = ? AND subject = ?", provider, claims.Subject).First(&identity).Error
+	if err == nil {
+		if err := db.Model(&identity).Update("last_login_at", now).Error; err != nil {
+			return User{}, false, err
+		}
+		err = db.First(&user, identity.UserID).Error
+		return user, false, err
+	}
+	if err != gorm.ErrRecordNotFound {
+		return User{}, false, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return User{}, false, ErrOIDCEmailMissing
+	}
+
+	err = db.Where("email = ?", claims.Email).First(&user).Error
+	switch {
+	case err == nil:
+		// an unverified address may have been registered by someone else to take over
+		// the account of whoever logs in with it later
+		if !user.EmailVerified() {
+			return User{}, false, ErrOIDCAccountNotLinked
+		}
+	case err == gorm.ErrRecordNotFound:
+		username, err := availableUsername(db, claims)
+		if err != nil {
+			return User{}, false, err
+		}
+		// the password is random so the account can only be used through the provider,
+		// until the user sets one with a password reset
+		password, err := oidc.RandomString()
+		if err != nil {
+			return User{}, false, err
+		}
+		user = User{Username: username, Email: claims.Email, Password: password, EmailVerifiedAt: &now}
+		if _, err := user.SaveUser(db); err != nil {
+			return User{}, false, err
+		}
+		created = true
+	default:
+		return User{}, false, err
+	}
+
+	identity = UserIdentity{UserID: user.ID, Provider: provider, Subject: claims.Subject, Email: claims.Email, LastLoginAt: &now}
+	if err := db.Create(&identity).Error; err != nil {
+		return User{}, false, err
+	}
+	return user, created, nil
+}
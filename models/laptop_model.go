@@ -7,17 +7,77 @@ import (
 )
 
 type Laptop struct {
-	ID          uint           `gorm:"primaryKey" json:"id"`
-	BrandID     uint           `gorm:"not null" json:"brand_id"`
-	CategoryID  uint           `gorm:"not null" json:"category_id"`
-	Name        string         `gorm:"not null" json:"name"`
-	ReleaseYear int            `json:"release_year"`
-	Spec        string         `json:"spec"`
-	Price       float64        `json:"price"`
-	Comments    []Comment      `gorm:"foreignKey:LaptopID" json:"comments"`
-	Brand       Brand          `gorm:"foreignKey:BrandID" json:"brand"`
-	Category    Category       `gorm:"foreignKey:CategoryID" json:"category"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                  uint            `gorm:"primaryKey" json:"id"`
+	BrandID             uint            `gorm:"not null" json:"brand_id"`
+	CategoryID          uint            `gorm:"not null" json:"category_id"`
+	Name                string          `gorm:"not null" json:"name"`
+	ReleaseYear         int             `json:"release_year"`
+	Spec                string          `json:"spec"`
+	Price               float64         `json:"price"`
+	Comments            []Comment       `gorm:"foreignKey:LaptopID" json:"comments"`
+	Brand               Brand           `gorm:"foreignKey:BrandID" json:"brand"`
+	Category            Category        `gorm:"foreignKey:CategoryID" json:"category"`
+	Aspects             []AspectSummary `gorm:"-" json:"aspects"`
+	TopPros             []Highlight     `gorm:"-" json:"top_pros"`
+	TopCons             []Highlight     `gorm:"-" json:"top_cons"`
+	AverageRating       float64         `gorm:"-" json:"average_rating"`
+	ReviewCount         int64           `gorm:"-" json:"review_count"`
+	VerifiedReviewCount int64           `gorm:"-" json:"verified_review_count"`
+	CreatedAt           time.Time       `json:"created_at"`
+	UpdatedAt           time.Time       `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt  `gorm:"index" json:"-"`
+}
+
+// LoadLaptopStats fills the review aggregates of each laptop.
+func LoadLaptopStats(db *gorm.DB, laptops []Laptop) error {
+	if err := LoadLaptopRatings(db, laptops); err != nil {
+		return err
+	}
+	if err := LoadLaptopAspects(db, laptops); err != nil {
+		return err
+	}
+	if err := LoadLaptopHighlights(db, laptops); err != nil {
+		return err
+	}
+
+	// decorate the comments of all laptops at once instead of querying per laptop
+	comments := []Comment{}
+	for _, laptop := range laptops {
+		comments = append(comments, laptop.Comments...)
+	}
+	if err := MarkVerifiedOwners(db, comments); err != nil {
+		return err
+	}
+	if err := LoadAuthors(db, comments); err != nil {
+		return err
+	}
+	for i := range laptops {
+		n := copy(laptops[i].Comments, comments)
+		comments = comments[n:]
+	}
+	return nil
+}
+
+// ChangedFields lists the catalog fields that differ between two versions of a laptop.
+func (l Laptop) ChangedFields(previous Laptop) []string {
+	changed := []string{}
+	if l.Name != previous.Name {
+		changed = append(changed, "name")
+	}
+	if l.ReleaseYear != previous.ReleaseYear {
+		changed = append(changed, "release_year")
+	}
+	if l.Spec != previous.Spec {
+		changed = append(changed, "spec")
+	}
+	if l.Price != previous.Price {
+		changed = append(changed, "price")
+	}
+	if l.BrandID != previous.BrandID {
+		changed = append(changed, "brand")
+	}
+	if l.CategoryID != previous.CategoryID {
+		changed = append(changed, "category")
+	}
+	return changed
 }
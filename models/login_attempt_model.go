@@ -0,0 +1,191 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	LoginSucceeded          = "success"
+	LoginInvalidCredentials = "invalid_credentials"
+	LoginThrottled          = "throttled"
+	LoginTwoFactorRequired  = "2fa_required"
+	LoginInvalidTwoFactor   = "invalid_2fa"
+	LoginSuspended          = "suspended"
+	LoginResetRequired      = "reset_required"
+
+	// failures older than the window are forgotten
+	loginFailureWindow = 15 * time.Minute
+
+	// per account: a growing wait between attempts after a few failures, then a lockout
+	accountDelayAfter    = 3
+	accountLockAfter     = 10
+	accountLockDuration  = 15 * time.Minute
+	maxLoginAttemptDelay = time.Minute
+
+	// per IP, across all usernames
+	ipDelayAfter = 10
+	ipBlockAfter = 50
+)
+
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Username  string    `gorm:"size:255;not null;index" json:"username"`
+	UserID    *uint     `gorm:"index" json:"user_id"`
+	IP        string    `gorm:"size:64;not null;index" json:"ip"`
+	Success   bool      `gorm:"not null" json:"success"`
+	Reason    string    `gorm:"size:30;not null" json:"reason"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// LoginThrottledError means the client must wait before trying to log in again.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, try again in %d seconds", int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// loginDelay doubles from one second for each failure past the threshold, up to a minute.
+func loginDelay(failures int, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+	exponent := failures - threshold
+	if exponent > 6 {
+		return maxLoginAttemptDelay
+	}
+	delay := time.Second << exponent
+	if delay > maxLoginAttemptDelay {
+		return maxLoginAttemptDelay
+	}
+	return delay
+}
+
+// ReserveLoginAttempt returns a *LoginThrottledError when the account is locked or the
+// account or IP has to wait after recent failures. Otherwise the attempt is counted as a
+// failure of the account before the credentials are checked, so concurrent attempts can't
+// all get past the throttle: of those that read the same count, only the first to write
+// it goes ahead. RecordLoginAttempt clears the count on success, and ReleaseLoginAttempt
+// gives the attempt back when the credentials were right but didn't log in.
+func ReserveLoginAttempt(db *gorm.DB, username string, ip string) error {
+	now := time.Now()
+	retryAfter := time.Duration(0)
+
+	var user User
+	err := db.Select("id", "failed_logins", "last_failed_login_at", "locked_until").Where("username = ?", username).First(&user).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	found := err == nil
+	failures := 0
+	if found {
+		if user.LockedUntil != nil && user.LockedUntil.After(now) {
+			retryAfter = user.LockedUntil.Sub(now)
+		} else if user.LastFailedLoginAt != nil && now.Sub(*user.LastFailedLoginAt) < loginFailureWindow {
+			failures = user.FailedLogins
+			if wait := user.LastFailedLoginAt.Add(loginDelay(failures, accountDelayAfter)).Sub(now); wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+
+	ipFailures := db.Model(&LoginAttempt{}).Where("ip = ? AND reason IN ? AND created_at > ?", ip, []string{LoginInvalidCredentials, LoginInvalidTwoFactor}, now.Add(-loginFailureWindow))
+	var ipCount int64
+	if err := ipFailures.Count(&ipCount).Error; err != nil {
+		return err
+	}
+	if ipCount >= ipBlockAfter {
+		if loginFailureWindow > retryAfter {
+			retryAfter = loginFailureWindow
+		}
+	} else if ipCount >= ipDelayAfter {
+		var last LoginAttempt
+		if err := ipFailures.Order("created_at DESC").First(&last).Error; err != nil {
+			return err
+		}
+		if wait := last.CreatedAt.Add(loginDelay(int(ipCount), ipDelayAfter)).Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	if retryAfter > 0 {
+		return &LoginThrottledError{RetryAfter: retryAfter}
+	}
+	if !found {
+		return nil
+	}
+
+	// written only if no other attempt has changed the count since it was read
+	reserve := db.Model(&User{}).Where("id = ? AND failed_logins = ?", user.ID, user.FailedLogins)
+	if user.LastFailedLoginAt == nil {
+		reserve = reserve.Where("last_failed_login_at IS NULL")
+	} else {
+		reserve = reserve.Where("last_failed_login_at <= ?", *user.LastFailedLoginAt)
+	}
+	res := reserve.Updates(map[string]interface{}{"failed_logins": failures + 1, "last_failed_login_at": now})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return &LoginThrottledError{RetryAfter: time.Second}
+	}
+	return nil
+}
+
+// ReleaseLoginAttempt gives back an attempt reserved for the account whose credentials
+// were right but that didn't log in, such as one going on to two-factor authentication.
+func ReleaseLoginAttempt(db *gorm.DB, username string) error {
+	return db.Model(&User{}).Where("username = ? AND failed_logins > 0", username).
+		Update("failed_logins", gorm.Expr("failed_logins - 1")).Error
+}
+
+// RecordLoginAttempt logs a login attempt. A success clears the account's failure count,
+// and a failure, counted when it was reserved, locks the account after too many in a row.
+func RecordLoginAttempt(db *gorm.DB, username string, ip string, reason string) error {
+	attempt := LoginAttempt{Username: username, IP: ip, Success: reason == LoginSucceeded, Reason: reason}
+
+	var user User
+	err := db.Select("id").Where("username = ?", username).First(&user).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	if err == nil {
+		attempt.UserID = &user.ID
+	}
+	if err := db.Create(&attempt).Error; err != nil {
+		return err
+	}
+	if attempt.UserID == nil {
+		return nil
+	}
+
+	switch reason {
+	case LoginSucceeded:
+		return db.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"failed_logins":        0,
+			"last_failed_login_at": nil,
+			"locked_until":         nil,
+		}).Error
+	case LoginInvalidCredentials, LoginInvalidTwoFactor:
+		// counted when the attempt was reserved
+		return db.Model(&User{}).Where("id = ? AND failed_logins >= ?", user.ID, accountLockAfter).Updates(map[string]interface{}{
+			"locked_until":  time.Now().Add(accountLockDuration),
+			"failed_logins": 0,
+		}).Error
+	}
+	return nil
+}
+
+// UnlockUser lifts a lockout and forgets the account's failed logins.
+func UnlockUser(db *gorm.DB, userID uint) error {
+	return db.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"failed_logins":        0,
+		"last_failed_login_at": nil,
+		"locked_until":         nil,
+	}).Error
+}
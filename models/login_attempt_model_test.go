@@ -0,0 +1,124 @@
+package models
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestConcurrentAttemptsAreReservedOnce(t *testing.T) {
+	db := newTestDB(t, &User{}, &LoginAttempt{})
+
+	user := User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// every attempt reads the account before any of them reserves it
+	const attempts = 10
+	var reads sync.WaitGroup
+	reads.Add(attempts)
+	var readCount int32
+	db.Callback().Query().After("gorm:query").Register("test:barrier", func(tx *gorm.DB) {
+		if tx.Statement.Table == "users" && atomic.AddInt32(&readCount, 1) <= attempts {
+			reads.Done()
+			reads.Wait()
+		}
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- ReserveLoginAttempt(db, "alice", "192.0.2.1")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	reserved := 0
+	for err := range errs {
+		switch err.(type) {
+		case nil:
+			reserved++
+		case *LoginThrottledError:
+		default:
+			t.Fatal(err)
+		}
+	}
+	if reserved != 1 {
+		t.Fatalf("%d of %d concurrent attempts went ahead, want 1", reserved, attempts)
+	}
+	db.First(&user, user.ID)
+	if user.FailedLogins != 1 {
+		t.Fatalf("failed_logins = %d before the password was checked, want 1", user.FailedLogins)
+	}
+}
+
+func TestFailuresLockTheAccount(t *testing.T) {
+	db := newTestDB(t, &User{}, &LoginAttempt{})
+	user := User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	db.Create(&user)
+
+	for i := 0; i < accountLockAfter; i++ {
+		// past the growing wait between attempts
+		db.Model(&user).Update("last_failed_login_at", time.Now().Add(-2*maxLoginAttemptDelay))
+		if err := ReserveLoginAttempt(db, "alice", "192.0.2.1"); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+		if err := RecordLoginAttempt(db, "alice", "192.0.2.1", LoginInvalidCredentials); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db.First(&user, user.ID)
+	if user.LockedUntil == nil || !user.LockedUntil.After(time.Now()) {
+		t.Fatalf("account not locked after %d failures (failed_logins = %d)", accountLockAfter, user.FailedLogins)
+	}
+	if err := ReserveLoginAttempt(db, "alice", "198.51.100.7"); err == nil {
+		t.Fatal("ReserveLoginAttempt let a locked account through")
+	}
+}
+
+func TestRightCredentialsReleaseTheAttempt(t *testing.T) {
+	db := newTestDB(t, &User{}, &LoginAttempt{})
+	user := User{Username: "carol", Email: "carol@example.com", Password: "x"}
+	db.Create(&user)
+
+	if err := ReserveLoginAttempt(db, "carol", "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReleaseLoginAttempt(db, "carol"); err != nil {
+		t.Fatal(err)
+	}
+	db.First(&user, user.ID)
+	if user.FailedLogins != 0 {
+		t.Fatalf("failed_logins = %d after a released attempt, want 0", user.FailedLogins)
+	}
+}
+
+func TestFailuresOutsideTheWindowStartOver(t *testing.T) {
+	db := newTestDB(t, &User{}, &LoginAttempt{})
+	user := User{Username: "bob", Email: "bob@example.com", Password: "x"}
+	db.Create(&user)
+
+	for i := 0; i < 3; i++ {
+		if err := ReserveLoginAttempt(db, "bob", "192.0.2.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Model(&user).Update("last_failed_login_at", time.Now().Add(-2*loginFailureWindow))
+	if err := ReserveLoginAttempt(db, "bob", "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	user = User{}
+	db.First(&user)
+	if user.FailedLogins != 1 {
+		t.Fatalf("failed_logins = %d after a failure outside the window, want 1", user.FailedLogins)
+	}
+}
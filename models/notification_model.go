@@ -0,0 +1,206 @@
+package models
+
+import (
+	"final-project-rest-api/utils/notify"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Notification is a message in a user's inbox. EventKey is set for the ones sent for an
+// outbox event, so an event handled again doesn't send them twice.
+type Notification struct {
+	ID        uint                   `gorm:"primaryKey" json:"id"`
+	UserID    uint                   `gorm:"not null;index" json:"user_id"`
+	Type      string                 `gorm:"size:50;not null;index" json:"type"`
+	Title     string                 `gorm:"size:255;not null" json:"title"`
+	Body      string                 `gorm:"type:text" json:"body"`
+	Data      map[string]interface{} `gorm:"serializer:json;type:text" json:"data"`
+	ReadAt    *time.Time             `gorm:"index" json:"read_at"`
+	EventKey  *string                `gorm:"size:100;uniqueIndex" json:"-"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// DeliverNotification stores the notification for the user's inbox and sends it through
+// the other requested channels. Delivery failures are logged, not returned, so one
+// unreachable channel doesn't prevent the others. A notification whose event key is
+// already stored was delivered before and isn't sent again.
+func DeliverNotification(db *gorm.DB, notification *Notification, channels []string, webhookURL string) error {
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(notification)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.Error
+	}
+
+	var user User
+	if err := db.Select("id", "email").First(&user, notification.UserID).Error; err != nil {
+		return err
+	}
+
+	msg := notify.Message{
+		Type:       notification.Type,
+		Title:      notification.Title,
+		Body:       notification.Body,
+		Data:       notification.Data,
+		CreatedAt:  notification.CreatedAt,
+		Email:      user.Email,
+		WebhookURL: webhookURL,
+	}
+	for _, name := range channels {
+		channel, ok := notify.Lookup(name)
+		if !ok {
+			log.Printf("Notification channel %q is not configured", name)
+			continue
+		}
+		if err := channel.Send(msg); err != nil {
+			log.Printf("Failed to deliver notification %d through %s: %v", notification.ID, name, err)
+		}
+	}
+	return nil
+}
+
+const (
+	NotificationNewReview     = "activity.new_review"
+	NotificationLaptopUpdated = "activity.laptop_updated"
+	NotificationAdminAction   = "activity.admin_action"
+)
+
+// NotificationTypes are the activity notifications users can turn off. Alerts are
+// explicit subscriptions and are managed through the alerts themselves.
+var NotificationTypes = []string{NotificationNewReview, NotificationLaptopUpdated, NotificationAdminAction}
+
+type NotificationPreference struct {
+	ID      uint   `gorm:"primaryKey" json:"-"`
+	UserID  uint   `gorm:"not null;uniqueIndex:idx_notification_preference" json:"-"`
+	Type    string `gorm:"size:50;not null;uniqueIndex:idx_notification_preference" json:"type"`
+	Enabled bool   `gorm:"not null" json:"enabled"`
+}
+
+func IsNotificationType(notificationType string) bool {
+	for _, t := range NotificationTypes {
+		if t == notificationType {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationPreferences returns whether each activity notification type is enabled for the user.
+func NotificationPreferences(db *gorm.DB, userID uint) (map[string]bool, error) {
+	preferences := map[string]bool{}
+	for _, t := range NotificationTypes {
+		preferences[t] = true
+	}
+
+	var stored []NotificationPreference
+	if err := db.Where("user_id = ?", userID).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	for _, preference := range stored {
+		preferences[preference.Type] = preference.Enabled
+	}
+	return preferences, nil
+}
+
+// Notify sends an in-app activity notification to each user who didn't turn its type off.
+func Notify(db *gorm.DB, userIDs []uint, notificationType string, title string, body string, data map[string]interface{}) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	var disabled []uint
+	if err := db.Model(&NotificationPreference{}).
+		Where("user_id IN ? AND type = ? AND enabled = ?", userIDs, notificationType, false).
+		Pluck("user_id", &disabled).Error; err != nil {
+		return err
+	}
+	optedOut := map[uint]bool{}
+	for _, id := range disabled {
+		optedOut[id] = true
+	}
+
+	for _, userID := range userIDs {
+		if optedOut[userID] {
+			continue
+		}
+		notification := Notification{
+			UserID:   userID,
+			Type:     notificationType,
+			Title:    title,
+			Body:     body,
+			Data:     data,
+			EventKey: eventKey(db, notificationType, userID),
+		}
+		if err := DeliverNotification(db, &notification, []string{notify.ChannelInApp}, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// laptopReviewers returns the users who reviewed the laptop, except the excluded one.
+func laptopReviewers(db *gorm.DB, laptopID uint, exclude uint) ([]uint, error) {
+	var userIDs []uint
+	err := db.Model(&Comment{}).Distinct("user_id").
+		Where("laptop_id = ? AND user_id <> ?", laptopID, exclude).
+		Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// NotifyReviewersOfNewReview tells the earlier reviewers of a laptop that it got a new review.
+func NotifyReviewersOfNewReview(db *gorm.DB, comment Comment) error {
+	// only the users who had reviewed it before this review, however late this runs
+	var reviewers []uint
+	err := db.Model(&Comment{}).Distinct("user_id").
+		Where("laptop_id = ? AND user_id <> ? AND id < ?", comment.LaptopID, comment.UserID, comment.ID).
+		Pluck("user_id", &reviewers).Error
+	if err != nil || len(reviewers) == 0 {
+		return err
+	}
+
+	var laptop Laptop
+	if err := db.First(&laptop, comment.LaptopID).Error; err != nil {
+		return err
+	}
+
+	return Notify(db, reviewers, NotificationNewReview,
+		"New review of "+laptop.Name,
+		"Someone else reviewed "+laptop.Name+", a laptop you reviewed.",
+		map[string]interface{}{"laptop_id": laptop.ID, "comment_id": comment.ID})
+}
+
+// NotifyReviewersOfLaptopChange tells the reviewers of a laptop, other than its editor, that its details changed.
+func NotifyReviewersOfLaptopChange(db *gorm.DB, laptop Laptop, changed []string, editorID uint) error {
+	if len(changed) == 0 {
+		return nil
+	}
+
+	reviewers, err := laptopReviewers(db, laptop.ID, editorID)
+	if err != nil || len(reviewers) == 0 {
+		return err
+	}
+
+	return Notify(db, reviewers, NotificationLaptopUpdated,
+		laptop.Name+" was updated",
+		"The details of "+laptop.Name+", a laptop you reviewed, changed: "+strings.Join(changed, ", ")+".",
+		map[string]interface{}{"laptop_id": laptop.ID, "changed": changed})
+}
+
+// NotifyOwnershipReviewed tells the claimant that a moderator verified or rejected their ownership claim.
+func NotifyOwnershipReviewed(db *gorm.DB, claim OwnershipClaim) error {
+	var laptop Laptop
+	if err := db.First(&laptop, claim.LaptopID).Error; err != nil {
+		return err
+	}
+
+	body := "A moderator marked your ownership claim for " + laptop.Name + " as " + claim.Status + "."
+	if claim.Note != "" {
+		body += " Note: " + claim.Note
+	}
+	return Notify(db, []uint{claim.UserID}, NotificationAdminAction,
+		"Ownership claim "+claim.Status,
+		body,
+		map[string]interface{}{"ownership_id": claim.ID, "laptop_id": laptop.ID, "status": claim.Status})
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"context"
+	"final-project-rest-api/utils/notify"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEventSideEffectsHappenOnce(t *testing.T) {
+	allowLocalReceivers(t)
+	db := newTestDB(t, &User{}, &Brand{}, &AlertSubscription{}, &Notification{}, &NotificationPreference{}, &Webhook{}, &WebhookDelivery{})
+	alice := User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	bob := User{Username: "bob", Email: "bob@example.com", Password: "x"}
+	db.Create(&alice)
+	db.Create(&bob)
+	brand := Brand{BrandName: "Acme"}
+	db.Create(&brand)
+	db.Create(&AlertSubscription{UserID: alice.ID, Type: AlertNewLaptop, BrandID: &brand.ID, Channels: []string{notify.ChannelInApp}, Active: true})
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+	db.Create(&Webhook{URL: receiver.URL, Secret: "whsec_test", Events: []string{EventLaptopCreated}, Active: true})
+
+	// the same event handled twice, as after a partial failure
+	edb := db.WithContext(WithOutboxEvent(context.Background(), 42))
+	laptop := Laptop{ID: 5, BrandID: brand.ID, Name: "Acme Book", Price: 999}
+	for i := 0; i < 2; i++ {
+		if err := EvaluateNewLaptopAlerts(edb, laptop); err != nil {
+			t.Fatal(err)
+		}
+		if err := Notify(edb, []uint{alice.ID, bob.ID}, NotificationLaptopUpdated, "Acme Book was updated", "", nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := PublishWebhookEvent(edb, EventLaptopCreated, nil, map[string]interface{}{"id": laptop.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var notifications, deliveries int64
+	db.Model(&Notification{}).Count(&notifications)
+	db.Model(&WebhookDelivery{}).Count(&deliveries)
+	if notifications != 3 || deliveries != 1 {
+		t.Fatalf("%d notifications and %d webhook deliveries, want 3 and 1", notifications, deliveries)
+	}
+
+	// another event notifies again
+	if err := Notify(db.WithContext(WithOutboxEvent(context.Background(), 43)), []uint{alice.ID}, NotificationLaptopUpdated, "Acme Book was updated", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&Notification{}).Count(&notifications)
+	if notifications != 4 {
+		t.Fatalf("%d notifications after another event, want 4", notifications)
+	}
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OutboxPending     = "pending"
+	OutboxDispatching = "dispatching"
+	OutboxDispatched  = "dispatched"
+	OutboxFailed      = "failed"
+)
+
+// OutboxEvent is a domain event written in the same transaction as the change it
+// describes, so it is dispatched even if the process dies right after the commit.
+// Completed lists the subscribers that handled it; when others fail it goes back to
+// pending and only those are run again once NextAttemptAt has passed. UserID is the user
+// whose data the payload holds, if any; the event is deleted with their account.
+type OutboxEvent struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Name          string     `gorm:"size:50;not null;index" json:"name"`
+	UserID        *uint      `gorm:"index" json:"user_id"`
+	Payload       string     `gorm:"type:text;not null" json:"payload"`
+	Status        string     `gorm:"size:20;not null;index" json:"status"`
+	Completed     []string   `gorm:"serializer:json;type:text" json:"completed"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt *time.Time `gorm:"index" json:"next_attempt_at"`
+	ClaimedAt     *time.Time `json:"claimed_at"`
+	DispatchedAt  *time.Time `json:"dispatched_at"`
+	LastError     string     `gorm:"type:text" json:"last_error"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type outboxEventKey struct{}
+
+// WithOutboxEvent returns a context for handling the outbox event. The side effects its
+// handlers key with eventKey happen once, however many times the handlers are run again.
+func WithOutboxEvent(ctx context.Context, id uint) context.Context {
+	return context.WithValue(ctx, outboxEventKey{}, id)
+}
+
+// eventKey identifies one side effect, such as a notification to a user, of the outbox
+// event db is handling. It is nil outside of an event handler.
+func eventKey(db *gorm.DB, parts ...interface{}) *string {
+	id, ok := db.Statement.Context.Value(outboxEventKey{}).(uint)
+	if !ok {
+		return nil
+	}
+	key := fmt.Sprint(id)
+	for _, part := range parts {
+		key += ":" + fmt.Sprint(part)
+	}
+	return &key
+}
+
+// HasCompleted reports whether the subscriber has already handled the event.
+func (o OutboxEvent) HasCompleted(subscriber string) bool {
+	for _, s := range o.Completed {
+		if s == subscriber {
+			return true
+		}
+	}
+	return false
+}
+
+// undispatched matches the events that are due or whose dispatcher stopped before finishing.
+func undispatched(db *gorm.DB, staleAfter time.Duration) *gorm.DB {
+	now := time.Now()
+	return db.Where("(status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)) OR (status = ? AND claimed_at < ?)",
+		OutboxPending, now, OutboxDispatching, now.Add(-staleAfter))
+}
+
+// ClaimOutboxEvent marks a due event, or one whose dispatcher stopped before finishing,
+// as being dispatched and returns it. It reports false when another dispatcher has it.
+func ClaimOutboxEvent(db *gorm.DB, id uint, staleAfter time.Duration) (OutboxEvent, bool, error) {
+	var outbox OutboxEvent
+	result := undispatched(db.Model(&OutboxEvent{}).Where("id = ?", id), staleAfter).
+		Updates(map[string]interface{}{"status": OutboxDispatching, "claimed_at": time.Now()})
+	if result.Error != nil || result.RowsAffected != 1 {
+		return outbox, false, result.Error
+	}
+	err := db.First(&outbox, id).Error
+	return outbox, err == nil, err
+}
+
+// FinishOutboxEvent records a dispatch attempt. An event with failed subscribers goes
+// back to pending until retryAt, or is given up on when retryAt is nil.
+func FinishOutboxEvent(db *gorm.DB, outbox OutboxEvent, lastError string, retryAt *time.Time) error {
+	// map updates skip the field's serializer
+	completed, err := json.Marshal(outbox.Completed)
+	if err != nil {
+		return err
+	}
+	status := OutboxDispatched
+	if lastError != "" {
+		status = OutboxFailed
+		if retryAt != nil {
+			status = OutboxPending
+		}
+	}
+	return db.Model(&OutboxEvent{}).Where("id = ?", outbox.ID).Updates(map[string]interface{}{
+		"status":          status,
+		"completed":       string(completed),
+		"attempts":        gorm.Expr("attempts + 1"),
+		"next_attempt_at": retryAt,
+		"dispatched_at":   time.Now(),
+		"last_error":      lastError,
+	}).Error
+}
+
+// UndispatchedOutboxEvents returns the events that are due or whose dispatcher stopped
+// before finishing, oldest first.
+func UndispatchedOutboxEvents(db *gorm.DB, staleAfter time.Duration, limit int) ([]OutboxEvent, error) {
+	var outbox []OutboxEvent
+	err := undispatched(db, staleAfter).Order("id").Limit(limit).Find(&outbox).Error
+	return outbox, err
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OwnershipPending  = "pending"
+	OwnershipVerified = "verified"
+	OwnershipRejected = "rejected"
+
+	// VerifiedOwnerWeight is how much a verified owner's review counts in aggregates
+	// compared to a regular review.
+	VerifiedOwnerWeight = 2
+)
+
+var (
+	verifiedOwnerJoin = fmt.Sprintf("LEFT JOIN ownership_claims ON ownership_claims.user_id = comments.user_id AND ownership_claims.laptop_id = comments.laptop_id AND ownership_claims.status = '%s'", OwnershipVerified)
+	reviewWeight      = fmt.Sprintf("(CASE WHEN ownership_claims.id IS NULL THEN 1 ELSE %d END)", VerifiedOwnerWeight)
+)
+
+type OwnershipClaim struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"not null;uniqueIndex:idx_ownership_user_laptop" json:"user_id"`
+	LaptopID     uint       `gorm:"not null;uniqueIndex:idx_ownership_user_laptop" json:"laptop_id"`
+	ReceiptPath  string     `json:"-"`
+	HasReceipt   bool       `json:"has_receipt"`
+	Status       string     `gorm:"size:20;not null;default:pending;index" json:"status"`
+	Note         string     `json:"note"`
+	ReviewedByID *uint      `json:"reviewed_by_id"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+	Laptop       *Laptop    `gorm:"foreignKey:LaptopID" json:"laptop,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// RemoveReceipt deletes a receipt file that no claim refers to anymore. The claim has
+// already been changed by then, so a failure is only logged.
+func RemoveReceipt(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove receipt %s: %v", path, err)
+	}
+}
+
+// OnlyVerifiedOwners is a scope restricting a comments query to reviews written by verified owners.
+func OnlyVerifiedOwners(db *gorm.DB) *gorm.DB {
+	return db.Where("EXISTS (SELECT 1 FROM ownership_claims WHERE ownership_claims.user_id = comments.user_id AND ownership_claims.laptop_id = comments.laptop_id AND ownership_claims.status = ?)", OwnershipVerified)
+}
+
+// MarkVerifiedOwners sets the verified owner badge of each comment.
+func MarkVerifiedOwners(db *gorm.DB, comments []Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	userIDs := make([]uint, len(comments))
+	for i, comment := range comments {
+		userIDs[i] = comment.UserID
+	}
+
+	var claims []OwnershipClaim
+	if err := db.Select("user_id", "laptop_id").Where("status = ? AND user_id IN ?", OwnershipVerified, userIDs).Find(&claims).Error; err != nil {
+		return err
+	}
+
+	verified := map[[2]uint]bool{}
+	for _, claim := range claims {
+		verified[[2]uint{claim.UserID, claim.LaptopID}] = true
+	}
+	for i := range comments {
+		comments[i].VerifiedOwner = verified[[2]uint{comments[i].UserID, comments[i].LaptopID}]
+	}
+	return nil
+}
+
+// LoadLaptopRatings fills the weighted average rating and review counts of each laptop.
+func LoadLaptopRatings(db *gorm.DB, laptops []Laptop) error {
+	if len(laptops) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(laptops))
+	for i, laptop := range laptops {
+		ids[i] = laptop.ID
+	}
+
+	var rows []struct {
+		LaptopID      uint
+		Average       float64
+		Reviews       int64
+		VerifiedCount int64
+	}
+	err := db.Table("comments").
+		Select("comments.laptop_id AS laptop_id, "+
+			"SUM(comments.rating * "+reviewWeight+") * 1.0 / SUM("+reviewWeight+") AS average, "+
+			"COUNT(*) AS reviews, COUNT(ownership_claims.id) AS verified_count").
+		Joins(verifiedOwnerJoin).
+		Where("comments.deleted_at IS NULL AND comments.laptop_id IN ?", ids).
+		Group("comments.laptop_id").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		for i := range laptops {
+			if laptops[i].ID == row.LaptopID {
+				laptops[i].AverageRating = row.Average
+				laptops[i].ReviewCount = row.Reviews
+				laptops[i].VerifiedReviewCount = row.VerifiedCount
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"final-project-rest-api/utils"
+	"final-project-rest-api/utils/mailer"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidResetToken = errors.New("reset link is invalid or has expired")
+
+// passwordResetInterval is the minimum time between reset emails to the same account.
+const passwordResetInterval = time.Minute
+
+// PasswordResetToken stores only the hash of the token mailed to the user.
+type PasswordResetToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func hashResetToken(resetToken string) string {
+	sum := sha256.Sum256([]byte(resetToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func passwordResetTTL() time.Duration {
+	minutes, err := strconv.Atoi(utils.Getenv("PASSWORD_RESET_TTL_MINUTES", "60"))
+	if err != nil || minutes < 1 {
+		minutes = 60
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// RequestPasswordReset mails a reset link to the account with the given email, if there
+// is one. It doesn't report whether the account exists; callers should answer the same
+// either way.
+func RequestPasswordReset(db *gorm.DB, email string) error {
+	var user User
+	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+
+	var recent int64
+	if err := db.Model(&PasswordResetToken{}).
+		Where("user_id = ? AND created_at > ?", user.ID, time.Now().Add(-passwordResetInterval)).
+		Count(&recent).Error; err != nil {
+		return err
+	}
+	if recent > 0 {
+		return nil
+	}
+
+	return sendPasswordReset(db, user,
+		"Someone asked to reset the password of your account.",
+		"If it wasn't you, you can ignore this email; your password won't change.")
+}
+
+// sendPasswordReset mails the user a new reset link between the given lines explaining it.
+func sendPasswordReset(db *gorm.DB, user User, intro string, closing string) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	resetToken := base64.RawURLEncoding.EncodeToString(b)
+
+	ttl := passwordResetTTL()
+	if err := db.Create(&PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashResetToken(resetToken),
+		ExpiresAt: time.Now().Add(ttl),
+	}).Error; err != nil {
+		return err
+	}
+
+	link := AppURL() + "/reset-password?token=" + url.QueryEscape(resetToken)
+	return mailer.Default().Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n%s Open this link within %d minutes to choose a new one:\n\n%s\n\n%s",
+			user.Username, intro, int(ttl.Minutes()), link, closing),
+	})
+}
+
+// ResetPassword sets a new password with a reset token, uses up every outstanding token
+// of the account and revokes its existing sessions.
+func ResetPassword(db *gorm.DB, resetToken string, newPassword string) error {
+	hashedPassword, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		var reset PasswordResetToken
+		if err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashResetToken(resetToken), now).First(&reset).Error; err != nil {
+			return ErrInvalidResetToken
+		}
+
+		// claiming the token here keeps two concurrent requests from both using it
+		claim := tx.Model(&PasswordResetToken{}).Where("user_id = ? AND used_at IS NULL", reset.UserID).Update("used_at", now)
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			return ErrInvalidResetToken
+		}
+
+		if err := tx.Model(&User{}).Where("id = ?", reset.UserID).Updates(map[string]interface{}{
+			"password":                hashedPassword,
+			"tokens_valid_after":      now,
+			"password_reset_required": false,
+		}).Error; err != nil {
+			return err
+		}
+		if err := RevokeAllAPIKeys(tx, reset.UserID); err != nil {
+			return err
+		}
+		return TerminateAllSessions(tx, reset.UserID)
+	})
+}
+
+// ChangePassword sets a new password for a logged-in user, revokes their tokens and API
+// keys and ends every session but the current one, which the caller must give a new token.
+func ChangePassword(db *gorm.DB, userID uint, currentSessionID uint, newPassword string) error {
+	hashedPassword, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"password":                hashedPassword,
+			"tokens_valid_after":      time.Now(),
+			"password_reset_required": false,
+		}).Error; err != nil {
+			return err
+		}
+		if err := RevokeAllAPIKeys(tx, userID); err != nil {
+			return err
+		}
+		return TerminateOtherSessions(tx, userID, currentSessionID)
+	})
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+const (
+	PrivacyFullname = "fullname"
+	PrivacyBio      = "bio"
+	PrivacyAvatar   = "avatar"
+	PrivacyJoined   = "joined_date"
+	PrivacyStats    = "stats"
+	PrivacyReviews  = "reviews"
+)
+
+// PrivacyFields are the parts of a user's public page they can hide. Everything is
+// visible until hidden.
+var PrivacyFields = []string{PrivacyFullname, PrivacyBio, PrivacyAvatar, PrivacyJoined, PrivacyStats, PrivacyReviews}
+
+type PrivacySetting struct {
+	ID      uint   `gorm:"primaryKey" json:"-"`
+	UserID  uint   `gorm:"not null;uniqueIndex:idx_privacy_setting" json:"-"`
+	Field   string `gorm:"size:30;not null;uniqueIndex:idx_privacy_setting" json:"field"`
+	Visible bool   `gorm:"not null" json:"visible"`
+}
+
+func IsPrivacyField(field string) bool {
+	for _, f := range PrivacyFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// PrivacySettings returns whether each field of the user's public page is visible.
+func PrivacySettings(db *gorm.DB, userID uint) (map[string]bool, error) {
+	settings, err := LoadPrivacySettings(db, []uint{userID})
+	if err != nil {
+		return nil, err
+	}
+	return settings[userID], nil
+}
+
+// LoadPrivacySettings returns the privacy settings of each of the users.
+func LoadPrivacySettings(db *gorm.DB, userIDs []uint) (map[uint]map[string]bool, error) {
+	settings := map[uint]map[string]bool{}
+	for _, userID := range userIDs {
+		settings[userID] = map[string]bool{}
+		for _, field := range PrivacyFields {
+			settings[userID][field] = true
+		}
+	}
+	if len(userIDs) == 0 {
+		return settings, nil
+	}
+
+	var stored []PrivacySetting
+	if err := db.Where("user_id IN ?", userIDs).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	for _, setting := range stored {
+		if _, ok := settings[setting.UserID][setting.Field]; ok {
+			settings[setting.UserID][setting.Field] = setting.Visible
+		}
+	}
+	return settings, nil
+}
+
+// UpdatePrivacySettings shows or hides the given fields; fields left out keep their setting.
+func UpdatePrivacySettings(db *gorm.DB, userID uint, visible map[string]bool) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for field, v := range visible {
+			setting := PrivacySetting{UserID: userID, Field: field}
+			if err := tx.Where(&setting).FirstOrInit(&setting).Error; err != nil {
+				return err
+			}
+			setting.Visible = v
+			if err := tx.Save(&setting).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RedactProfile blanks the profile fields its user hid.
+func RedactProfile(profile *Profile, settings map[string]bool) {
+	if !settings[PrivacyFullname] {
+		profile.Fullname = ""
+	}
+	if !settings[PrivacyBio] {
+		profile.Bio = ""
+	}
+	if !settings[PrivacyAvatar] {
+		profile.AvatarURL = ""
+	}
+}
+
+// RedactProfiles blanks the fields each profile's user hid, for showing them publicly.
+func RedactProfiles(db *gorm.DB, profiles []Profile) error {
+	userIDs := make([]uint, len(profiles))
+	for i, profile := range profiles {
+		userIDs[i] = profile.UserID
+	}
+	settings, err := LoadPrivacySettings(db, userIDs)
+	if err != nil {
+		return err
+	}
+	for i := range profiles {
+		RedactProfile(&profiles[i], settings[profiles[i].UserID])
+	}
+	return nil
+}
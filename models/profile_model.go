@@ -5,10 +5,12 @@ import (
 )
 
 type Profile struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `json:"user_id"`
-	Fullname  string    `json:"fullname"`
-	Bio       string    `json:"bio"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         uint               `gorm:"primaryKey" json:"id"`
+	UserID     uint               `json:"user_id"`
+	Fullname   string             `json:"fullname"`
+	Bio        string             `json:"bio"`
+	AvatarURL  string             `gorm:"size:500" json:"avatar_url"`
+	Reputation *ReputationSummary `gorm:"-" json:"reputation,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+	UpdatedAt  time.Time          `json:"updated_at"`
 }
@@ -0,0 +1,279 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	LevelNewcomer    = "newcomer"
+	LevelContributor = "contributor"
+	LevelTrusted     = "trusted"
+	LevelExpert      = "expert"
+
+	BadgeFirstReview    = "first_review"
+	BadgeProlific       = "prolific"
+	BadgeDetailed       = "detailed"
+	BadgeConsensus      = "consensus"
+	BadgeVeteran        = "veteran"
+	BadgeVerifiedOwner  = "verified_owner"
+	consensusMinReviews = 3
+)
+
+// reputationLevels are ordered from highest to lowest threshold.
+var reputationLevels = []struct {
+	Level     string
+	MinPoints int
+}{
+	{LevelExpert, 500},
+	{LevelTrusted, 200},
+	{LevelContributor, 50},
+	{LevelNewcomer, 0},
+}
+
+// dailyReviewLimits is how many reviews a user can post per 24 hours, 0 meaning unlimited.
+var dailyReviewLimits = map[string]int{
+	LevelNewcomer:    5,
+	LevelContributor: 20,
+	LevelTrusted:     0,
+	LevelExpert:      0,
+}
+
+type ReputationSummary struct {
+	Score  int      `json:"score"`
+	Level  string   `json:"level"`
+	Badges []string `json:"badges"`
+}
+
+func (u User) ReputationSummary() ReputationSummary {
+	level := u.ReputationLevel
+	if level == "" {
+		level = LevelNewcomer
+	}
+	badges := u.Badges
+	if badges == nil {
+		badges = []string{}
+	}
+	return ReputationSummary{Score: u.Reputation, Level: level, Badges: badges}
+}
+
+// DailyReviewLimit returns how many reviews the user can post per 24 hours, 0 meaning unlimited.
+func (u User) DailyReviewLimit() int {
+	if limit, ok := dailyReviewLimits[u.ReputationLevel]; ok {
+		return limit
+	}
+	return dailyReviewLimits[LevelNewcomer]
+}
+
+// reviewDepth scores how much substance a review has, from 0 to 10.
+func reviewDepth(comment Comment) int {
+	depth := len(comment.Content) / 100
+	if depth > 5 {
+		depth = 5
+	}
+	highlights := len(comment.Pros) + len(comment.Cons)
+	if highlights > 3 {
+		highlights = 3
+	}
+	depth += highlights
+	if comment.Title != "" {
+		depth++
+	}
+	if len(comment.AspectRatings) > 0 {
+		depth++
+	}
+	return depth
+}
+
+// RecomputeReputation scores a user from their review count, review depth, account age
+// and how closely their ratings track the other reviewers of the same laptops.
+func RecomputeReputation(db *gorm.DB, userID uint) error {
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			// the account was deleted
+			return nil
+		}
+		return err
+	}
+	if user.Username == DeletedUsername {
+		// anonymized reviews don't earn anyone reputation
+		return nil
+	}
+
+	var comments []Comment
+	if err := db.Preload("AspectRatings").Where("user_id = ?", userID).Find(&comments).Error; err != nil {
+		return err
+	}
+
+	points := len(comments) * 10
+	totalDepth := 0
+	for _, comment := range comments {
+		totalDepth += reviewDepth(comment)
+	}
+	points += totalDepth
+
+	months := int(time.Since(user.CreatedAt).Hours() / 24 / 30)
+	if months > 24 {
+		months = 24
+	}
+	points += months * 2
+
+	consistency, compared, err := ratingConsistency(db, userID)
+	if err != nil {
+		return err
+	}
+	if compared >= consensusMinReviews {
+		points += int(math.Round(consistency * 50))
+	}
+
+	var verifiedClaims int64
+	if err := db.Model(&OwnershipClaim{}).Where("user_id = ? AND status = ?", userID, OwnershipVerified).Count(&verifiedClaims).Error; err != nil {
+		return err
+	}
+
+	badges := []string{}
+	if len(comments) >= 1 {
+		badges = append(badges, BadgeFirstReview)
+	}
+	if len(comments) >= 25 {
+		badges = append(badges, BadgeProlific)
+	}
+	if len(comments) >= 5 && totalDepth/len(comments) >= 6 {
+		badges = append(badges, BadgeDetailed)
+	}
+	if compared >= 5 && consistency >= 0.8 {
+		badges = append(badges, BadgeConsensus)
+	}
+	if time.Since(user.CreatedAt) >= 365*24*time.Hour {
+		badges = append(badges, BadgeVeteran)
+	}
+	if verifiedClaims > 0 {
+		badges = append(badges, BadgeVerifiedOwner)
+	}
+
+	level := LevelNewcomer
+	for _, l := range reputationLevels {
+		if points >= l.MinPoints {
+			level = l.Level
+			break
+		}
+	}
+
+	return db.Model(&user).Select("Reputation", "ReputationLevel", "Badges").Updates(User{
+		Reputation:      points,
+		ReputationLevel: level,
+		Badges:          badges,
+	}).Error
+}
+
+// ratingConsistency compares the user's ratings with the average rating other users gave
+// the same laptops. It returns 1 for perfect agreement down to 0, and how many reviews
+// could be compared.
+func ratingConsistency(db *gorm.DB, userID uint) (float64, int, error) {
+	var rows []struct {
+		Rating    int
+		Consensus float64
+		Others    int64
+	}
+	err := db.Table("comments AS mine").
+		Select("mine.rating AS rating, AVG(others.rating) AS consensus, COUNT(others.id) AS others").
+		Joins("JOIN comments AS others ON others.laptop_id = mine.laptop_id AND others.user_id <> mine.user_id AND others.deleted_at IS NULL").
+		Where("mine.user_id = ? AND mine.deleted_at IS NULL", userID).
+		Group("mine.id, mine.rating").
+		Having("COUNT(others.id) >= ?", consensusMinReviews-1).
+		Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return 0, 0, err
+	}
+
+	totalDiff := 0.0
+	for _, row := range rows {
+		totalDiff += math.Abs(float64(row.Rating) - row.Consensus)
+	}
+	consistency := 1 - totalDiff/float64(len(rows))/4
+	if consistency < 0 {
+		consistency = 0
+	}
+	return consistency, len(rows), nil
+}
+
+// RecomputeAllReputations refreshes the reputation of every user.
+func RecomputeAllReputations(db *gorm.DB) error {
+	var ids []uint
+	if err := db.Model(&User{}).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := RecomputeReputation(db, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadAuthors loads users as shown next to what they wrote: with their profile as they let
+// it be shown and their reputation, in three queries however many there are.
+func loadAuthors(db *gorm.DB, userIDs []uint) (map[uint]*User, error) {
+	authors := map[uint]*User{}
+	if len(userIDs) == 0 {
+		return authors, nil
+	}
+
+	var users []User
+	if err := db.Select("id", "username", "reputation", "reputation_level", "badges", "created_at").
+		Preload("Profile", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "user_id", "fullname", "avatar_url")
+		}).
+		Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	settings, err := LoadPrivacySettings(db, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		RedactProfile(&users[i].Profile, settings[users[i].ID])
+		authors[users[i].ID] = &users[i]
+	}
+	return authors, nil
+}
+
+// LoadAuthors attaches the author of each comment.
+func LoadAuthors(db *gorm.DB, comments []Comment) error {
+	userIDs := make([]uint, len(comments))
+	for i, comment := range comments {
+		userIDs[i] = comment.UserID
+	}
+	authors, err := loadAuthors(db, userIDs)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		comments[i].User = authors[comments[i].UserID]
+	}
+	return nil
+}
+
+// LoadProfileReputations attaches the reputation of each profile's user, as shown with
+// their reviews.
+func LoadProfileReputations(db *gorm.DB, profiles []Profile) error {
+	userIDs := make([]uint, len(profiles))
+	for i, profile := range profiles {
+		userIDs[i] = profile.UserID
+	}
+	authors, err := loadAuthors(db, userIDs)
+	if err != nil {
+		return err
+	}
+	for i := range profiles {
+		if author, ok := authors[profiles[i].UserID]; ok {
+			reputation := author.ReputationSummary()
+			profiles[i].Reputation = &reputation
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"errors"
+	"final-project-rest-api/utils/token"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// sessionSeenInterval is how often a session's last-seen time is updated while it's in use.
+const sessionSeenInterval = time.Minute
+
+var ErrSessionTerminated = errors.New("session has been terminated")
+
+// Session is a login: a token issued to a device. Terminating it rejects its token.
+type Session struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	UserID    uint   `gorm:"not null;index" json:"-"`
+	UserAgent string `gorm:"size:255" json:"user_agent"`
+	IP        string `gorm:"size:64" json:"ip"`
+	// the admin who started the session to act as the user
+	ImpersonatorID *uint      `gorm:"index" json:"-"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastSeenAt     time.Time  `json:"last_seen_at"`
+	ExpiresAt      time.Time  `gorm:"not null;index" json:"expires_at"`
+	TerminatedAt   *time.Time `json:"-"`
+	Current        bool       `gorm:"-" json:"current"`
+}
+
+// StartSession records a new login of the user and returns the token for it.
+func StartSession(db *gorm.DB, userID uint, userAgent string, ip string) (Session, string, error) {
+	lifespan, err := token.Lifespan()
+	if err != nil {
+		return Session{}, "", err
+	}
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	now := time.Now()
+	session := Session{
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(lifespan),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return Session{}, "", err
+	}
+
+	jwtToken, err := token.GenerateSessionToken(userID, session.ID)
+	if err != nil {
+		return Session{}, "", err
+	}
+	return session, jwtToken, nil
+}
+
+// TouchSession checks that the user's session is still live and records that it was
+// just used from ip.
+func TouchSession(db *gorm.DB, userID uint, sessionID uint, ip string) error {
+	var session Session
+	err := db.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error
+	if err == gorm.ErrRecordNotFound {
+		return ErrSessionTerminated
+	}
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if session.TerminatedAt != nil || !session.ExpiresAt.After(now) {
+		return ErrSessionTerminated
+	}
+
+	if now.Sub(session.LastSeenAt) >= sessionSeenInterval || session.IP != ip {
+		return db.Model(&session).Updates(map[string]interface{}{"last_seen_at": now, "ip": ip}).Error
+	}
+	return nil
+}
+
+// ActiveSessions lists the user's live sessions, most recently used first.
+func ActiveSessions(db *gorm.DB, userID uint) ([]Session, error) {
+	var sessions []Session
+	err := db.Where("user_id = ? AND terminated_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// TerminateSession ends one of the user's sessions. It reports false when there is no
+// such live session.
+func TerminateSession(db *gorm.DB, userID uint, sessionID uint) (bool, error) {
+	res := db.Model(&Session{}).Where("id = ? AND user_id = ? AND terminated_at IS NULL", sessionID, userID).Update("terminated_at", time.Now())
+	return res.RowsAffected > 0, res.Error
+}
+
+// TerminateAllSessions ends every session of the user.
+func TerminateAllSessions(db *gorm.DB, userID uint) error {
+	return db.Model(&Session{}).Where("user_id = ? AND terminated_at IS NULL", userID).Update("terminated_at", time.Now()).Error
+}
+
+// TerminateOtherSessions ends every session of the user but the given one.
+func TerminateOtherSessions(db *gorm.DB, userID uint, keepSessionID uint) error {
+	return db.Model(&Session{}).Where("user_id = ? AND id <> ? AND terminated_at IS NULL", userID, keepSessionID).Update("terminated_at", time.Now()).Error
+}
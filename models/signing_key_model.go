@@ -0,0 +1,234 @@
+package models
+
+import (
+	"final-project-rest-api/utils"
+	"final-project-rest-api/utils/token"
+	"log"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	SigningKeyNext    = "next"
+	SigningKeyActive  = "active"
+	SigningKeyRetired = "retired"
+)
+
+// SigningKey is a key pair login tokens are signed with. A key is published in the JWKS
+// before it activates, so verifiers can pick it up ahead of time, and stays published
+// after it is retired until the last token it signed has expired.
+type SigningKey struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	KID         string     `gorm:"size:64;not null;uniqueIndex" json:"kid"`
+	Algorithm   string     `gorm:"size:10;not null" json:"algorithm"`
+	PrivateKey  string     `gorm:"type:text;not null" json:"-"`
+	PublicKey   string     `gorm:"type:text;not null" json:"public_key"`
+	ActivatesAt time.Time  `gorm:"not null;index" json:"activates_at"`
+	RetiredAt   *time.Time `json:"retired_at"`
+	ExpiresAt   *time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Status      string     `gorm:"-" json:"status"`
+}
+
+func (k *SigningKey) AfterFind(tx *gorm.DB) error {
+	switch {
+	case k.RetiredAt != nil:
+		k.Status = SigningKeyRetired
+	case k.ActivatesAt.After(time.Now()):
+		k.Status = SigningKeyNext
+	default:
+		k.Status = SigningKeyActive
+	}
+	return nil
+}
+
+// keyRotationInterval is how long a key signs tokens before the next one takes over,
+// from JWT_KEY_ROTATION_DAYS.
+func keyRotationInterval() time.Duration {
+	days, err := strconv.Atoi(utils.Getenv("JWT_KEY_ROTATION_DAYS", "30"))
+	if err != nil || days < 1 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// keyPrepublishPeriod is how long a key is published before it activates.
+func keyPrepublishPeriod() time.Duration {
+	period := 24 * time.Hour
+	if interval := keyRotationInterval(); period > interval/2 {
+		period = interval / 2
+	}
+	return period
+}
+
+// keyRetirementGrace is how long a retired key stays valid: long enough for every token
+// it signed to expire.
+func keyRetirementGrace() time.Duration {
+	lifespan, err := token.Lifespan()
+	if err != nil {
+		lifespan = time.Hour
+	}
+	return lifespan + time.Hour
+}
+
+func createSigningKey(db *gorm.DB, alg string, activatesAt time.Time) error {
+	key, err := token.GenerateKey(alg)
+	if err != nil {
+		return err
+	}
+	public, err := key.EncodePublicKey()
+	if err != nil {
+		return err
+	}
+	private, err := key.SealPrivateKey()
+	if err != nil {
+		return err
+	}
+	return db.Create(&SigningKey{
+		KID:         key.ID,
+		Algorithm:   alg,
+		PrivateKey:  private,
+		PublicKey:   public,
+		ActivatesAt: activatesAt,
+	}).Error
+}
+
+// PublishedSigningKeys are the keys tokens may be signed with: upcoming, active and
+// retired but not yet expired.
+func PublishedSigningKeys(db *gorm.DB) ([]SigningKey, error) {
+	var keys []SigningKey
+	err := db.Where("expires_at IS NULL OR expires_at > ?", time.Now()).Order("activates_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// currentSigningKey is the newest active key of the configured algorithm.
+func currentSigningKey(keys []SigningKey, alg string) *SigningKey {
+	for i := range keys {
+		if keys[i].Status == SigningKeyActive && keys[i].Algorithm == alg {
+			return &keys[i]
+		}
+	}
+	return nil
+}
+
+// RotateSigningKeys keeps the key schedule going: it publishes the next key ahead of its
+// activation, retires keys that were taken over and deletes the expired ones. force
+// activates a new key right away.
+func RotateSigningKeys(db *gorm.DB, force bool) error {
+	alg := token.SigningAlgorithm()
+	now := time.Now()
+
+	if alg != token.AlgHS256 {
+		keys, err := PublishedSigningKeys(db)
+		if err != nil {
+			return err
+		}
+		current := currentSigningKey(keys, alg)
+		var next *SigningKey
+		for i := range keys {
+			if keys[i].Status == SigningKeyNext && keys[i].Algorithm == alg {
+				next = &keys[i]
+			}
+		}
+
+		switch {
+		case current == nil || force:
+			if next != nil {
+				err = db.Model(next).Update("activates_at", now).Error
+			} else {
+				err = createSigningKey(db, alg, now)
+			}
+		case next == nil:
+			rotatesAt := current.ActivatesAt.Add(keyRotationInterval())
+			if !now.Before(rotatesAt.Add(-keyPrepublishPeriod())) {
+				err = createSigningKey(db, alg, rotatesAt)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// every active key but the one now signing is retired
+	keys, err := PublishedSigningKeys(db)
+	if err != nil {
+		return err
+	}
+	retire := db.Model(&SigningKey{}).Where("retired_at IS NULL AND activates_at <= ?", now)
+	if current := currentSigningKey(keys, alg); current != nil {
+		retire = retire.Where("id <> ?", current.ID)
+	}
+	if err := retire.Updates(map[string]interface{}{
+		"retired_at": now,
+		"expires_at": now.Add(keyRetirementGrace()),
+	}).Error; err != nil {
+		return err
+	}
+
+	return db.Where("expires_at <= ?", now).Delete(&SigningKey{}).Error
+}
+
+// LoadSigningKeys hands the published keys to the token package, which verifies tokens
+// against all of them and signs new ones with the current key.
+func LoadSigningKeys(db *gorm.DB) error {
+	keys, err := PublishedSigningKeys(db)
+	if err != nil {
+		return err
+	}
+
+	loaded := make([]*token.Key, 0, len(keys))
+	for _, k := range keys {
+		key, err := token.ParseKey(k.KID, k.Algorithm, k.PublicKey, k.PrivateKey)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", k.KID, err)
+			continue
+		}
+		loaded = append(loaded, key)
+	}
+
+	signingID := ""
+	if current := currentSigningKey(keys, token.SigningAlgorithm()); current != nil {
+		signingID = current.KID
+	}
+
+	// tokens signed with API_SECRET before the first key took over expire one lifespan later
+	var firstActivated time.Time
+	for _, k := range keys {
+		if k.Status != SigningKeyNext && (firstActivated.IsZero() || k.ActivatesAt.Before(firstActivated)) {
+			firstActivated = k.ActivatesAt
+		}
+	}
+	lifespan, err := token.Lifespan()
+	if err != nil {
+		lifespan = time.Hour
+	}
+	token.SetKeys(loaded, signingID, firstActivated.Add(lifespan))
+	return nil
+}
+
+// StartKeyRotation rotates and loads the signing keys now, then every interval until the
+// process exits, and reloads them whenever a token names a key that isn't loaded.
+func StartKeyRotation(db *gorm.DB, interval time.Duration) {
+	// another instance may have rotated to a key this one hasn't loaded yet
+	token.OnUnknownKey(func() error { return LoadSigningKeys(db) })
+
+	rotate := func() {
+		if err := RotateSigningKeys(db, false); err != nil {
+			log.Printf("Failed to rotate signing keys: %v", err)
+		}
+		if err := LoadSigningKeys(db); err != nil {
+			log.Printf("Failed to load signing keys: %v", err)
+		}
+	}
+
+	rotate()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			rotate()
+		}
+	}()
+}
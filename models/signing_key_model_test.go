@@ -0,0 +1,61 @@
+package models
+
+import (
+	"final-project-rest-api/utils/token"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func tokenValid(signed string) error {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	c.Request.Header.Set("Authorization", "Bearer "+signed)
+	return token.TokenValid(c)
+}
+
+func TestRetiredKeyVerifiesUntilItExpires(t *testing.T) {
+	t.Setenv("JWT_SIGNING_ALG", token.AlgEdDSA)
+	t.Setenv("JWT_ACCEPT_HS256", "false")
+	db := newTestDB(t, &SigningKey{})
+	t.Cleanup(func() { token.SetKeys(nil, "", time.Time{}) })
+
+	if err := RotateSigningKeys(db, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadSigningKeys(db); err != nil {
+		t.Fatal(err)
+	}
+	signed, err := token.GenerateToken(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RotateSigningKeys(db, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadSigningKeys(db); err != nil {
+		t.Fatal(err)
+	}
+	var retired SigningKey
+	if err := db.Where("retired_at IS NOT NULL").First(&retired).Error; err != nil {
+		t.Fatalf("no key was retired by a forced rotation: %v", err)
+	}
+	if err := tokenValid(signed); err != nil {
+		t.Fatalf("token signed with a key in its retirement grace: %v", err)
+	}
+
+	db.Model(&retired).Update("expires_at", time.Now().Add(-time.Second))
+	if err := RotateSigningKeys(db, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadSigningKeys(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := tokenValid(signed); err == nil {
+		t.Fatal("a token signed with an expired key was accepted")
+	}
+}
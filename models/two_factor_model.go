@@ -0,0 +1,271 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"final-project-rest-api/utils"
+	"final-project-rest-api/utils/token"
+	"final-project-rest-api/utils/totp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	recoveryCodeCount     = 10
+	purposeLoginChallenge = "login_2fa"
+	loginChallengeTTL     = 5 * time.Minute
+)
+
+var (
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled  = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotEnrolled = errors.New("start two-factor enrollment first")
+	ErrInvalidTwoFactorCode = errors.New("authentication code is invalid")
+)
+
+// RecoveryCode is a one-time code that stands in for a TOTP code when the
+// authenticator is lost. Only its hash is stored.
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;index"`
+	CodeHash  string `gorm:"size:64;not null;uniqueIndex"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (u *User) TwoFactorEnabled() bool {
+	return u.TwoFactorEnabledAt != nil
+}
+
+// TwoFactorRequired reports whether the user's role must use two-factor authentication,
+// per the comma separated roles in ENFORCE_2FA_ROLES.
+func TwoFactorRequired(role string) bool {
+	for _, enforced := range strings.Split(utils.Getenv("ENFORCE_2FA_ROLES", ""), ",") {
+		if strings.TrimSpace(enforced) == role && role != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := hex.EncodeToString(b)
+	return code[:5] + "-" + code[5:], nil
+}
+
+// replaceRecoveryCodes discards the user's recovery codes and returns a fresh set.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Create(&RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// BeginTwoFactorEnrollment provisions a new TOTP secret for the user and returns it with
+// the otpauth URI for authenticator apps. It only takes effect once confirmed.
+func BeginTwoFactorEnrollment(db *gorm.DB, user *User) (string, string, error) {
+	if user.TwoFactorEnabled() {
+		return "", "", ErrTwoFactorEnabled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := db.Model(user).Update("totp_secret", secret).Error; err != nil {
+		return "", "", err
+	}
+	issuer := utils.Getenv("TOTP_ISSUER", "Laptop Reviews")
+	return secret, totp.URI(issuer, user.Username, secret), nil
+}
+
+// ConfirmTwoFactor turns two-factor authentication on once the user proves their
+// authenticator works, and returns the recovery codes.
+func ConfirmTwoFactor(db *gorm.DB, user *User, code string) ([]string, error) {
+	if user.TwoFactorEnabled() {
+		return nil, ErrTwoFactorEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), 1)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"two_factor_enabled_at": now,
+			"totp_last_step":        step,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	return codes, err
+}
+
+// VerifySecondFactor accepts a current TOTP code or an unused recovery code. Each TOTP
+// step and each recovery code is accepted only once.
+func VerifySecondFactor(db *gorm.DB, user *User, code string) error {
+	if !user.TwoFactorEnabled() {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), 1); ok {
+		used := db.Model(&User{}).Where("id = ? AND totp_last_step < ?", user.ID, step).Update("totp_last_step", step)
+		if used.Error != nil {
+			return used.Error
+		}
+		if used.RowsAffected == 0 {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	used := db.Model(&RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if used.Error != nil {
+		return used.Error
+	}
+	if used.RowsAffected == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes.
+func RegenerateRecoveryCodes(db *gorm.DB, user *User) ([]string, error) {
+	if !user.TwoFactorEnabled() {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	return codes, err
+}
+
+// RemainingRecoveryCodes counts the user's unused recovery codes.
+func RemainingRecoveryCodes(db *gorm.DB, userID uint) (int64, error) {
+	var count int64
+	err := db.Model(&RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// DisableTwoFactor turns two-factor authentication off and discards the secret and
+// recovery codes.
+func DisableTwoFactor(db *gorm.DB, user *User) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_secret":           "",
+			"two_factor_enabled_at": nil,
+			"totp_last_step":        0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&RecoveryCode{}).Error
+	})
+}
+
+// LoginChallenge is a correct password waiting for the second factor. Only the hash of
+// its nonce is stored; the challenge token can be exchanged once.
+type LoginChallenge struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	NonceHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func hashChallengeNonce(nonce string) string {
+	sum := sha256.Sum256([]byte(nonce))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueLoginChallenge returns the token that stands for a correct password while the
+// login waits for the second factor.
+func IssueLoginChallenge(db *gorm.DB, user User) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	nonce := hex.EncodeToString(b)
+	challenge := LoginChallenge{UserID: user.ID, NonceHash: hashChallengeNonce(nonce), ExpiresAt: time.Now().Add(loginChallengeTTL)}
+	if err := db.Create(&challenge).Error; err != nil {
+		return "", err
+	}
+	return token.GeneratePurposeToken(purposeLoginChallenge, strconv.FormatUint(uint64(user.ID), 10)+":"+nonce, loginChallengeTTL)
+}
+
+// ConsumeLoginChallenge uses up a login challenge and returns the user it was issued to.
+// Challenges issued before the user's tokens were revoked are refused.
+func ConsumeLoginChallenge(db *gorm.DB, challengeToken string) (User, error) {
+	subject, err := token.ParsePurposeToken(purposeLoginChallenge, challengeToken)
+	if err != nil {
+		return User{}, err
+	}
+	id, nonce, ok := strings.Cut(subject, ":")
+	if !ok {
+		return User{}, token.ErrInvalidPurposeToken
+	}
+
+	var challenge LoginChallenge
+	if err := db.Where("user_id = ? AND nonce_hash = ?", id, hashChallengeNonce(nonce)).First(&challenge).Error; err != nil {
+		return User{}, token.ErrInvalidPurposeToken
+	}
+	// claiming it here keeps two concurrent requests from both using it
+	claim := db.Model(&LoginChallenge{}).Where("id = ? AND used_at IS NULL AND expires_at > ?", challenge.ID, time.Now()).Update("used_at", time.Now())
+	if claim.Error != nil {
+		return User{}, claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return User{}, token.ErrInvalidPurposeToken
+	}
+
+	var user User
+	if err := db.First(&user, challenge.UserID).Error; err != nil {
+		return User{}, token.ErrInvalidPurposeToken
+	}
+	if user.TokensValidAfter != nil && challenge.CreatedAt.Before(*user.TokensValidAfter) {
+		return User{}, token.ErrInvalidPurposeToken
+	}
+	return user, nil
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginChallengeIsSingleUse(t *testing.T) {
+	db := newTestDB(t, &User{}, &LoginChallenge{})
+	user := User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	db.Create(&user)
+
+	challenge, err := IssueLoginChallenge(db, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ConsumeLoginChallenge(db, challenge)
+	if err != nil || got.ID != user.ID {
+		t.Fatalf("ConsumeLoginChallenge = %d, %v", got.ID, err)
+	}
+	if _, err := ConsumeLoginChallenge(db, challenge); err == nil {
+		t.Fatal("a login challenge was accepted twice")
+	}
+}
+
+func TestLoginChallengeIsRevokedWithTokens(t *testing.T) {
+	db := newTestDB(t, &User{}, &LoginChallenge{})
+	user := User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	db.Create(&user)
+
+	challenge, err := IssueLoginChallenge(db, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// e.g. the password was reset after the challenge was issued
+	db.Model(&user).Update("tokens_valid_after", time.Now().Add(time.Second))
+	if _, err := ConsumeLoginChallenge(db, challenge); err == nil {
+		t.Fatal("a challenge issued before the tokens were revoked was accepted")
+	}
+}
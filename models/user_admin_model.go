@@ -0,0 +1,187 @@
+package models
+
+import (
+	"errors"
+	"final-project-rest-api/utils/mailer"
+	"final-project-rest-api/utils/token"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	UserActive    = "active"
+	UserSuspended = "suspended"
+	UserBanned    = "banned"
+)
+
+// impersonationTTL is how long an admin can act as another user with one token.
+const impersonationTTL = 15 * time.Minute
+
+var (
+	ErrPasswordResetRequired = errors.New("you must choose a new password before logging in, use the link we emailed you or request a new one")
+	ErrNotSuspended          = errors.New("the user is not suspended")
+	ErrImpersonateAdmin      = errors.New("admins can't be impersonated")
+	ErrInvalidRole           = fmt.Errorf("role must be %s, %s or %s", RoleUser, RoleEditor, RoleAdmin)
+)
+
+// SuspendedError means the account is suspended, or banned when there is no end to it.
+type SuspendedError struct {
+	Reason string
+	Until  *time.Time
+}
+
+func (e *SuspendedError) Error() string {
+	if e.Until == nil {
+		return "your account has been banned: " + e.Reason
+	}
+	return fmt.Sprintf("your account is suspended until %s: %s", e.Until.Format("January 2, 2006 15:04 MST"), e.Reason)
+}
+
+// Suspension returns the user's current suspension, or nil when there is none or it ended.
+func (u *User) Suspension() *SuspendedError {
+	if u.SuspendedAt == nil || (u.SuspendedUntil != nil && !u.SuspendedUntil.After(time.Now())) {
+		return nil
+	}
+	return &SuspendedError{Reason: u.SuspensionReason, Until: u.SuspendedUntil}
+}
+
+// Status is whether the user is active, suspended for a while or banned.
+func (u *User) Status() string {
+	suspension := u.Suspension()
+	if suspension == nil {
+		return UserActive
+	}
+	if suspension.Until == nil {
+		return UserBanned
+	}
+	return UserSuspended
+}
+
+// LoginAllowed returns why the user can't log in, if they can't: a suspension or a
+// password reset an admin required.
+func (u *User) LoginAllowed() error {
+	if suspension := u.Suspension(); suspension != nil {
+		return suspension
+	}
+	if u.PasswordResetRequired {
+		return ErrPasswordResetRequired
+	}
+	return nil
+}
+
+func IsRole(role string) bool {
+	return role == RoleUser || role == RoleEditor || role == RoleAdmin
+}
+
+// SuspendUser keeps the user from logging in until the given time, or for good when it is
+// nil, signs out every session and mails them the reason.
+func SuspendUser(db *gorm.DB, user *User, reason string, until *time.Time) error {
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"suspended_at":       now,
+			"suspended_until":    until,
+			"suspension_reason":  reason,
+			"tokens_valid_after": now,
+		}).Error; err != nil {
+			return err
+		}
+		return TerminateAllSessions(tx, user.ID)
+	})
+	if err != nil {
+		return err
+	}
+	user.SuspendedAt, user.SuspendedUntil, user.SuspensionReason = &now, until, reason
+
+	status := "Your account has been banned"
+	if until != nil {
+		status = "Your account is suspended until " + until.Format("January 2, 2006 15:04 MST")
+	}
+	return mailer.Default().Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your account has been suspended",
+		Body:    fmt.Sprintf("Hi %s,\n\n%s. Reason: %s\n\nYou have been signed out everywhere. If you think this is a mistake, reply to this email.", user.Username, status, reason),
+	})
+}
+
+// UnsuspendUser lifts the user's suspension or ban.
+func UnsuspendUser(db *gorm.DB, userID uint) error {
+	res := db.Model(&User{}).Where("id = ? AND suspended_at IS NOT NULL", userID).Updates(map[string]interface{}{
+		"suspended_at":      nil,
+		"suspended_until":   nil,
+		"suspension_reason": "",
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotSuspended
+	}
+	return nil
+}
+
+// SetUserRole changes the user's role.
+func SetUserRole(db *gorm.DB, userID uint, role string) error {
+	if !IsRole(role) {
+		return ErrInvalidRole
+	}
+	return db.Model(&User{}).Where("id = ?", userID).Update("role", role).Error
+}
+
+// ForcePasswordReset keeps the user from logging in until they choose a new password,
+// signs out every session and mails them a reset link.
+func ForcePasswordReset(db *gorm.DB, user *User) error {
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"password_reset_required": true,
+			"tokens_valid_after":      now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := RevokeAllAPIKeys(tx, user.ID); err != nil {
+			return err
+		}
+		return TerminateAllSessions(tx, user.ID)
+	})
+	if err != nil {
+		return err
+	}
+	user.PasswordResetRequired = true
+
+	return sendPasswordReset(db, *user,
+		"An administrator requires you to choose a new password for your account.",
+		"You have been signed out everywhere and can log in again once your password is changed. If the link expires, request a new one from the login page.")
+}
+
+// StartImpersonation starts a short session of the user for the admin to act as them, and
+// returns the token for it.
+func StartImpersonation(db *gorm.DB, adminID uint, user User, userAgent string, ip string) (Session, string, error) {
+	if user.Role == RoleAdmin {
+		return Session{}, "", ErrImpersonateAdmin
+	}
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	now := time.Now()
+	session := Session{
+		UserID:         user.ID,
+		UserAgent:      userAgent,
+		IP:             ip,
+		ImpersonatorID: &adminID,
+		LastSeenAt:     now,
+		ExpiresAt:      now.Add(impersonationTTL),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return Session{}, "", err
+	}
+
+	jwtToken, err := token.GenerateImpersonationToken(user.ID, session.ID, adminID, impersonationTTL)
+	if err != nil {
+		return Session{}, "", err
+	}
+	return session, jwtToken, nil
+}
@@ -1,7 +1,7 @@
 package models
 
 import (
-	"final-project-rest-api/utils/token"
+	"final-project-rest-api/utils"
 	"html"
 	"strings"
 	"time"
@@ -10,15 +10,52 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	RoleUser   = "user"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
 type User struct {
-	ID        uint      `json:"id" gorm:"primary_key"`
-	Username  string    `gorm:"not null;unique" json:"username"`
-	Email     string    `gorm:"not null;unique" json:"email"`
-	Password  string    `gorm:"not null;" json:"password"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Profile   Profile   `json:"profile"`
-	Comments  []Comment `json:"comments"`
+	ID                    uint       `json:"id" gorm:"primary_key"`
+	Username              string     `gorm:"not null;unique" json:"username"`
+	Email                 string     `gorm:"not null;unique" json:"email"`
+	Password              string     `gorm:"not null;" json:"-"`
+	Role                  string     `gorm:"size:20;not null;default:user" json:"role"`
+	Reputation            int        `gorm:"not null;default:0" json:"reputation"`
+	ReputationLevel       string     `gorm:"size:20;not null;default:newcomer" json:"reputation_level"`
+	Badges                []string   `gorm:"serializer:json;type:text" json:"badges"`
+	EmailVerifiedAt       *time.Time `json:"email_verified_at"`
+	VerificationSentAt    *time.Time `json:"-"`
+	TokensValidAfter      *time.Time `json:"-"`
+	FailedLogins          int        `gorm:"not null;default:0" json:"-"`
+	LastFailedLoginAt     *time.Time `json:"-"`
+	LockedUntil           *time.Time `json:"locked_until"`
+	TOTPSecret            string     `gorm:"size:64" json:"-"`
+	TOTPLastStep          int64      `gorm:"not null;default:0" json:"-"`
+	TwoFactorEnabledAt    *time.Time `json:"two_factor_enabled_at"`
+	DeletionScheduledAt   *time.Time `gorm:"index" json:"deletion_scheduled_at"`
+	SuspendedAt           *time.Time `gorm:"index" json:"suspended_at"`
+	SuspendedUntil        *time.Time `json:"suspended_until"`
+	SuspensionReason      string     `gorm:"size:500" json:"suspension_reason"`
+	PasswordResetRequired bool       `gorm:"not null;default:false" json:"password_reset_required"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+	Profile               Profile    `json:"profile"`
+	Comments              []Comment  `json:"comments"`
+}
+
+func (u *User) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if u.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
 }
 
 func HashPassword(password string) (string, error) {
@@ -33,32 +70,23 @@ func (u *User) VerifyPassword(password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 }
 
-func LoginCheck(username string, password string, db *gorm.DB) (string, error) {
-
-	var err error
-
+// Authenticate returns the user with the given username and password.
+func Authenticate(username string, password string, db *gorm.DB) (User, error) {
 	u := User{}
 
-	err = db.Model(User{}).Where("username = ?", username).Take(&u).Error
-
-	if err != nil {
-		return "", err
+	if err := db.Model(User{}).Where("username = ?", username).Take(&u).Error; err != nil {
+		return User{}, err
 	}
 
-	err = u.VerifyPassword(password)
-
-	if err != nil && err == bcrypt.ErrMismatchedHashAndPassword {
-		return "", err
+	if err := u.VerifyPassword(password); err != nil {
+		return User{}, err
 	}
 
-	token, err := token.GenerateToken(u.ID)
-
-	if err != nil {
-		return "", err
+	if err := u.LoginAllowed(); err != nil {
+		return User{}, err
 	}
 
-	return token, nil
-
+	return u, nil
 }
 
 func (u *User) SaveUser(db *gorm.DB) (*User, error) {
@@ -70,6 +98,9 @@ func (u *User) SaveUser(db *gorm.DB) (*User, error) {
 	u.Password = string(hashedPassword)
 	//remove spaces in username
 	u.Username = html.EscapeString(strings.TrimSpace(u.Username))
+	if strings.EqualFold(u.Username, DeletedUsername) {
+		return &User{}, ErrUsernameReserved
+	}
 
 	var err error = db.Create(&u).Error
 	if err != nil {
@@ -77,3 +108,30 @@ func (u *User) SaveUser(db *gorm.DB) (*User, error) {
 	}
 	return u, nil
 }
+
+// BootstrapAdmins gives the admin role to the existing accounts named in ADMIN_USERNAMES,
+// a comma separated list, so the first admin can be made without editing the database.
+// It returns the usernames it promoted.
+func BootstrapAdmins(db *gorm.DB) ([]string, error) {
+	var usernames []string
+	for _, username := range strings.Split(utils.Getenv("ADMIN_USERNAMES", ""), ",") {
+		if username = strings.TrimSpace(username); username != "" {
+			usernames = append(usernames, username)
+		}
+	}
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	var promoted []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("username IN ? AND role <> ?", usernames, RoleAdmin).Pluck("username", &promoted).Error; err != nil {
+			return err
+		}
+		if len(promoted) == 0 {
+			return nil
+		}
+		return tx.Model(&User{}).Where("username IN ?", promoted).Update("role", RoleAdmin).Error
+	})
+	return promoted, err
+}
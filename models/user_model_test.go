@@ -0,0 +1,29 @@
+package models
+
+import "testing"
+
+func TestBootstrapAdmins(t *testing.T) {
+	db := newTestDB(t, &User{})
+	for _, username := range []string{"alice", "bob", "carol"} {
+		db.Create(&User{Username: username, Email: username + "@example.com", Password: "x"})
+	}
+	t.Setenv("ADMIN_USERNAMES", " alice, carol ,nobody,")
+
+	promoted, err := BootstrapAdmins(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(promoted) != 2 {
+		t.Fatalf("promoted %v, want alice and carol", promoted)
+	}
+	var admins int64
+	db.Model(&User{}).Where("role = ?", RoleAdmin).Count(&admins)
+	if admins != 2 {
+		t.Fatalf("%d admins, want 2", admins)
+	}
+
+	// restarting with the same setting changes nothing
+	if promoted, err = BootstrapAdmins(db); err != nil || len(promoted) != 0 {
+		t.Fatalf("second bootstrap promoted %v, %v", promoted, err)
+	}
+}
@@ -0,0 +1,343 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"final-project-rest-api/utils"
+	"final-project-rest-api/utils/notify"
+	"final-project-rest-api/utils/webhook"
+	"fmt"
+	"log"
+
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	EventLaptopCreated   = "laptop.created"
+	EventLaptopUpdated   = "laptop.updated"
+	EventLaptopDeleted   = "laptop.deleted"
+	EventCommentCreated  = "comment.created"
+	EventCommentUpdated  = "comment.updated"
+	EventCommentDeleted  = "comment.deleted"
+	EventBrandCreated    = "brand.created"
+	EventBrandUpdated    = "brand.updated"
+	EventBrandDeleted    = "brand.deleted"
+	EventCategoryCreated = "category.created"
+	EventCategoryUpdated = "category.updated"
+	EventCategoryDeleted = "category.deleted"
+	EventPing            = "ping"
+
+	// AllWebhookEvents subscribes a webhook to every event.
+	AllWebhookEvents = "*"
+
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+
+	// deliveryLease keeps other workers off a delivery while it is being attempted.
+	deliveryLease = time.Minute
+)
+
+var WebhookEvents = []string{
+	EventLaptopCreated, EventLaptopUpdated, EventLaptopDeleted,
+	EventCommentCreated, EventCommentUpdated, EventCommentDeleted,
+	EventBrandCreated, EventBrandUpdated, EventBrandDeleted,
+	EventCategoryCreated, EventCategoryUpdated, EventCategoryDeleted,
+}
+
+type Webhook struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	URL         string    `gorm:"size:500;not null" json:"url"`
+	Description string    `gorm:"size:255" json:"description"`
+	Secret      string    `gorm:"size:64;not null" json:"-"`
+	Events      []string  `gorm:"serializer:json;type:text" json:"events"`
+	Active      bool      `gorm:"not null;default:true" json:"active"`
+	CreatedByID uint      `json:"created_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is a payload posted, or to be posted, to a webhook. UserID is the user
+// whose data the payload holds, if any; the delivery is deleted with their account.
+// EventKey is set for the deliveries of an outbox event, so an event handled again
+// doesn't queue them twice.
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	WebhookID      uint       `gorm:"not null;index" json:"webhook_id"`
+	Event          string     `gorm:"size:50;not null" json:"event"`
+	UserID         *uint      `gorm:"index" json:"user_id"`
+	Payload        string     `gorm:"type:text;not null" json:"payload"`
+	Status         string     `gorm:"size:20;not null;index" json:"status"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  *time.Time `gorm:"index" json:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	LastStatusCode int        `json:"last_status_code"`
+	LastResponse   string     `gorm:"type:text" json:"last_response"`
+	LastError      string     `gorm:"type:text" json:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	RedeliveryOfID *uint      `json:"redelivery_of_id"`
+	EventKey       *string    `gorm:"size:100;uniqueIndex" json:"-"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// WebhookPayload is the JSON body posted to receivers.
+type WebhookPayload struct {
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+func IsWebhookEvent(event string) bool {
+	if event == AllWebhookEvents {
+		return true
+	}
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (w Webhook) Validate() error {
+	if err := notify.CheckWebhookURL(w.URL); errors.Is(err, notify.ErrBlockedAddress) {
+		return errors.New("url must point to a public address")
+	} else if err != nil {
+		return errors.New("url must be a valid http or https URL")
+	}
+	if len(w.Events) == 0 {
+		return errors.New("events must list at least one event")
+	}
+	for _, event := range w.Events {
+		if !IsWebhookEvent(event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	return nil
+}
+
+func (w Webhook) Subscribes(event string) bool {
+	if event == EventPing {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event || e == AllWebhookEvents {
+			return true
+		}
+	}
+	return false
+}
+
+func GenerateWebhookSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func webhookMaxAttempts() int {
+	attempts, err := strconv.Atoi(utils.Getenv("WEBHOOK_MAX_ATTEMPTS", "6"))
+	if err != nil || attempts < 1 {
+		return 6
+	}
+	return attempts
+}
+
+// webhookBackoff is how long to wait after the given failed attempt: the base delay,
+// doubled for each attempt after the first.
+func webhookBackoff(attempt int) time.Duration {
+	base, err := strconv.Atoi(utils.Getenv("WEBHOOK_RETRY_BASE_SECONDS", "30"))
+	if err != nil || base < 1 {
+		base = 30
+	}
+	return time.Duration(base) * time.Second << (attempt - 1)
+}
+
+// PublishWebhookEvent queues a delivery of the event to every active webhook subscribed
+// to it and attempts them in the background. userID is the user whose data it holds, if any.
+func PublishWebhookEvent(db *gorm.DB, event string, userID *uint, data interface{}) error {
+	var webhooks []Webhook
+	if err := db.Where("active = ?", true).Find(&webhooks).Error; err != nil {
+		return err
+	}
+
+	var deliveries []WebhookDelivery
+	for _, w := range webhooks {
+		if !w.Subscribes(event) {
+			continue
+		}
+		delivery, err := queueDelivery(db, w.ID, event, userID, data)
+		if err != nil {
+			return err
+		}
+		// one queued when the event was handled before is left to the worker
+		if delivery.ID != 0 {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+
+	db = Detached(db)
+	for _, delivery := range deliveries {
+		go func(id uint) {
+			if err := AttemptDelivery(db, id); err != nil {
+				log.Printf("Webhook delivery %d: %v", id, err)
+			}
+		}(delivery.ID)
+	}
+	return nil
+}
+
+func queueDelivery(db *gorm.DB, webhookID uint, event string, userID *uint, data interface{}) (WebhookDelivery, error) {
+	payload, err := json.Marshal(WebhookPayload{Event: event, CreatedAt: time.Now(), Data: data})
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+
+	now := time.Now()
+	delivery := WebhookDelivery{
+		WebhookID:     webhookID,
+		Event:         event,
+		UserID:        userID,
+		Payload:       string(payload),
+		Status:        DeliveryPending,
+		NextAttemptAt: &now,
+		EventKey:      eventKey(db, "webhook", webhookID),
+	}
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&delivery)
+	if res.Error == nil && res.RowsAffected == 0 {
+		// queued when the event was handled before
+		return WebhookDelivery{}, nil
+	}
+	return delivery, res.Error
+}
+
+// PingWebhook queues a ping delivery so a receiver can be tested.
+func PingWebhook(db *gorm.DB, w Webhook) (WebhookDelivery, error) {
+	delivery, err := queueDelivery(db, w.ID, EventPing, nil, map[string]interface{}{"webhook_id": w.ID})
+	if err != nil {
+		return delivery, err
+	}
+	return attemptNow(db, delivery.ID)
+}
+
+// Redeliver queues a fresh copy of a past delivery, keeping the original in the log.
+func Redeliver(db *gorm.DB, original WebhookDelivery) (WebhookDelivery, error) {
+	now := time.Now()
+	delivery := WebhookDelivery{
+		WebhookID:      original.WebhookID,
+		Event:          original.Event,
+		UserID:         original.UserID,
+		Payload:        original.Payload,
+		Status:         DeliveryPending,
+		NextAttemptAt:  &now,
+		RedeliveryOfID: &original.ID,
+	}
+	if err := db.Create(&delivery).Error; err != nil {
+		return delivery, err
+	}
+	return attemptNow(db, delivery.ID)
+}
+
+// attemptNow makes the first attempt of a delivery in the request and returns how it
+// went; a failed attempt is retried by the worker like any other.
+func attemptNow(db *gorm.DB, deliveryID uint) (WebhookDelivery, error) {
+	if err := AttemptDelivery(db, deliveryID); err != nil {
+		log.Printf("Webhook delivery %d: %v", deliveryID, err)
+	}
+	var delivery WebhookDelivery
+	err := db.First(&delivery, deliveryID).Error
+	return delivery, err
+}
+
+// AttemptDelivery sends a pending delivery that is due, recording the outcome and
+// scheduling a retry with exponential backoff when it fails.
+func AttemptDelivery(db *gorm.DB, deliveryID uint) error {
+	now := time.Now()
+	leaseUntil := now.Add(deliveryLease)
+	claim := db.Model(&WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", deliveryID, DeliveryPending, now).
+		Update("next_attempt_at", leaseUntil)
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		// already being attempted, finished or not due yet
+		return claim.Error
+	}
+
+	var delivery WebhookDelivery
+	if err := db.First(&delivery, deliveryID).Error; err != nil {
+		return err
+	}
+
+	var w Webhook
+	var result webhook.Result
+	err := db.First(&w, delivery.WebhookID).Error
+	if err == nil {
+		result, err = webhook.Send(w.URL, w.Secret, delivery.Event, delivery.ID, []byte(delivery.Payload))
+	}
+
+	attemptedAt := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &attemptedAt
+	delivery.LastStatusCode = result.StatusCode
+	delivery.LastResponse = result.Body
+	if err == nil {
+		delivery.Status = DeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &attemptedAt
+		delivery.NextAttemptAt = nil
+	} else {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= webhookMaxAttempts() {
+			delivery.Status = DeliveryFailed
+			delivery.NextAttemptAt = nil
+		} else {
+			next := attemptedAt.Add(webhookBackoff(delivery.Attempts))
+			delivery.NextAttemptAt = &next
+		}
+	}
+
+	if err := db.Save(&delivery).Error; err != nil {
+		return err
+	}
+	if delivery.Status != DeliverySucceeded {
+		return fmt.Errorf("delivery %d failed: %s", delivery.ID, delivery.LastError)
+	}
+	return nil
+}
+
+// RetryDueDeliveries attempts the pending deliveries whose retry time has come.
+func RetryDueDeliveries(db *gorm.DB) error {
+	var ids []uint
+	if err := db.Model(&WebhookDelivery{}).
+		Where("status = ? AND next_attempt_at <= ?", DeliveryPending, time.Now()).
+		Order("next_attempt_at").Limit(100).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := AttemptDelivery(db, id); err != nil {
+			log.Printf("Webhook delivery %d: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// StartWebhookWorker retries due webhook deliveries every interval until the process exits.
+func StartWebhookWorker(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := RetryDueDeliveries(db); err != nil {
+				log.Printf("Failed to retry webhook deliveries: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,150 @@
+package models
+
+import (
+	"encoding/json"
+	"final-project-rest-api/utils/webhook"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// allowLocalReceivers lets deliveries reach httptest servers, which listen on loopback.
+func allowLocalReceivers(t *testing.T) {
+	client := webhook.Client
+	webhook.Client = &http.Client{Timeout: 10 * time.Second}
+	t.Cleanup(func() { webhook.Client = client })
+}
+
+func TestAttemptDeliverySignsPayload(t *testing.T) {
+	allowLocalReceivers(t)
+	db := newTestDB(t, &Webhook{}, &WebhookDelivery{})
+
+	var received WebhookPayload
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(webhook.HeaderTimestamp), 10, 64)
+		if r.Header.Get(webhook.HeaderEvent) != EventBrandUpdated || !webhook.Verify("whsec_test", timestamp, body, r.Header.Get(webhook.HeaderSignature)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.Unmarshal(body, &received)
+		w.Write([]byte("ok"))
+	}))
+	defer receiver.Close()
+
+	hook := Webhook{URL: receiver.URL, Secret: "whsec_test", Events: []string{EventBrandUpdated}, Active: true}
+	if err := db.Create(&hook).Error; err != nil {
+		t.Fatal(err)
+	}
+	delivery, err := queueDelivery(db, hook.ID, EventBrandUpdated, nil, map[string]interface{}{"id": 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := AttemptDelivery(db, delivery.ID); err != nil {
+		t.Fatalf("AttemptDelivery: %v", err)
+	}
+	db.First(&delivery, delivery.ID)
+	if delivery.Status != DeliverySucceeded || delivery.Attempts != 1 || delivery.LastStatusCode != http.StatusOK || delivery.LastResponse != "ok" {
+		t.Fatalf("delivery = %+v, want one successful attempt", delivery)
+	}
+	if received.Event != EventBrandUpdated || received.Data.(map[string]interface{})["id"] != float64(7) {
+		t.Fatalf("receiver got %+v", received)
+	}
+
+	// a finished delivery isn't sent again
+	if err := AttemptDelivery(db, delivery.ID); err != nil {
+		t.Fatalf("second AttemptDelivery: %v", err)
+	}
+	db.First(&delivery, delivery.ID)
+	if delivery.Attempts != 1 {
+		t.Fatalf("attempts = %d after a second attempt, want 1", delivery.Attempts)
+	}
+}
+
+func TestAttemptDeliveryRetriesWithBackoff(t *testing.T) {
+	t.Setenv("WEBHOOK_MAX_ATTEMPTS", "2")
+	t.Setenv("WEBHOOK_RETRY_BASE_SECONDS", "60")
+	allowLocalReceivers(t)
+	db := newTestDB(t, &Webhook{}, &WebhookDelivery{})
+
+	calls := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	hook := Webhook{URL: receiver.URL, Secret: "whsec_test", Events: []string{AllWebhookEvents}, Active: true}
+	db.Create(&hook)
+	delivery, err := queueDelivery(db, hook.ID, EventLaptopCreated, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := AttemptDelivery(db, delivery.ID); err == nil {
+		t.Fatal("AttemptDelivery succeeded against a failing receiver")
+	}
+	db.First(&delivery, delivery.ID)
+	if delivery.Status != DeliveryPending || delivery.LastStatusCode != http.StatusInternalServerError {
+		t.Fatalf("delivery = %+v, want pending after the first failure", delivery)
+	}
+	if wait := time.Until(*delivery.NextAttemptAt); wait < 55*time.Second || wait > time.Minute {
+		t.Fatalf("next attempt in %v, want about a minute", wait)
+	}
+
+	// not due yet
+	AttemptDelivery(db, delivery.ID)
+	if calls != 1 {
+		t.Fatalf("receiver called %d times before the retry was due", calls)
+	}
+
+	db.Model(&delivery).Update("next_attempt_at", time.Now().Add(-time.Second))
+	if err := RetryDueDeliveries(db); err != nil {
+		t.Fatal(err)
+	}
+	delivery = WebhookDelivery{}
+	db.First(&delivery)
+	if calls != 2 || delivery.Status != DeliveryFailed || delivery.Attempts != 2 || delivery.NextAttemptAt != nil {
+		t.Fatalf("delivery = %+v after %d calls, want failed after 2 attempts", delivery, calls)
+	}
+}
+
+func TestDeliveriesDontReachPrivateAddresses(t *testing.T) {
+	db := newTestDB(t, &Webhook{}, &WebhookDelivery{})
+
+	hit := false
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+		w.Write([]byte("internal data"))
+	}))
+	defer internal.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(internal.URL, http.StatusFound))
+	defer redirect.Close()
+
+	hook := Webhook{URL: internal.URL, Secret: "whsec_test", Events: []string{AllWebhookEvents}, Active: true}
+	if err := hook.Validate(); err == nil {
+		t.Fatalf("Validate accepted %s", hook.URL)
+	}
+	db.Create(&hook)
+	delivery, _ := queueDelivery(db, hook.ID, EventLaptopCreated, nil, nil)
+	if err := AttemptDelivery(db, delivery.ID); err == nil {
+		t.Fatal("AttemptDelivery reached a loopback receiver")
+	}
+	db.First(&delivery, delivery.ID)
+	if hit || delivery.LastResponse != "" {
+		t.Fatalf("the delivery reached the internal server: %+v", delivery)
+	}
+
+	// a public receiver redirecting inward isn't followed
+	client := webhook.Client
+	webhook.Client = &http.Client{CheckRedirect: client.CheckRedirect}
+	defer func() { webhook.Client = client }()
+	result, err := webhook.Send(redirect.URL, "whsec_test", EventPing, 1, []byte("{}"))
+	if err == nil || hit || result.StatusCode != http.StatusFound {
+		t.Fatalf("Send through a redirect = %+v, %v; internal server hit %v", result, err, hit)
+	}
+}
@@ -3,6 +3,9 @@ package routes
 import (
 	"final-project-rest-api/controllers"
 	"final-project-rest-api/middleware"
+	"final-project-rest-api/models"
+	"final-project-rest-api/utils"
+	"strings"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -14,64 +17,225 @@ import (
 func SetupRouter(db *gorm.DB) *gin.Engine {
 	r := gin.Default()
 
+	// ClientIP drives login throttling, so forwarding headers are only believed from the
+	// proxies in TRUSTED_PROXIES (comma-separated IPs or CIDRs), or from a header the
+	// hosting platform sets itself, named by TRUSTED_PLATFORM (X-Real-Ip on Vercel).
+	var trustedProxies []string
+	if proxies := utils.Getenv("TRUSTED_PROXIES", ""); proxies != "" {
+		for _, proxy := range strings.Split(proxies, ",") {
+			trustedProxies = append(trustedProxies, strings.TrimSpace(proxy))
+		}
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		panic(err.Error())
+	}
+	r.TrustedPlatform = utils.Getenv("TRUSTED_PLATFORM", "")
+
 	// CORS configuration
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowAllOrigins = true
-	corsConfig.AllowHeaders = []string{"Content-Type", "X-XSRF-TOKEN", "Accept", "Origin", "X-Requested-With", "Authorization"}
+	corsConfig.AllowHeaders = []string{"Content-Type", "X-XSRF-TOKEN", "Accept", "Origin", "X-Requested-With", "Authorization", middleware.APIKeyHeader}
 	corsConfig.AllowCredentials = true
-	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
 	r.Use(cors.New(corsConfig))
 
 	// set db to gin context
-	r.Use(func(c *gin.Context) {
-		c.Set("db", db)
-	})
+	r.Use(middleware.DatabaseMiddleware(db))
 
 	// User routes
 	r.POST("/register", controllers.Register)
 	r.POST("/login", controllers.Login)
+	r.POST("/login/2fa", controllers.LoginTwoFactor)
+	r.GET("/verify-email", controllers.VerifyEmail)
+	r.POST("/auth/forgot-password", controllers.ForgotPassword)
+	r.POST("/auth/reset-password", controllers.ResetPassword)
+	r.GET("/.well-known/jwks.json", controllers.GetJWKS)
+
+	// Social login
+	r.GET("/auth/oidc", controllers.GetOIDCProviders)
+	r.GET("/auth/oidc/:provider/login", controllers.StartOIDCLogin)
+	r.GET("/auth/oidc/:provider/callback", controllers.OIDCCallback)
 
 	// Protected routes with JWT middleware
 	auth := r.Group("/auth")
 	auth.Use(middleware.JwtAuthMiddleware())
 	{
 		auth.PUT("/change-password", controllers.ChangePassword)
+		auth.POST("/resend-verification", controllers.ResendVerificationEmail)
+
+		// Two-factor authentication
+		auth.GET("/2fa", controllers.GetTwoFactorStatus)
+		auth.POST("/2fa/enroll", controllers.EnrollTwoFactor)
+		auth.POST("/2fa/confirm", controllers.ConfirmTwoFactor)
+		auth.POST("/2fa/recovery-codes", controllers.RegenerateRecoveryCodes)
+		auth.POST("/2fa/disable", controllers.DisableTwoFactor)
 	}
 
 	api := r.Group("/api")
 	{
 		// Category
-		api.GET("/categories", controllers.GetCategories)
-		api.GET("/category/:id", controllers.GetCategoryById)
-		api.POST("/category", middleware.JwtAuthMiddleware(), controllers.CreateCategory)
-		api.PUT("/category/:id", middleware.JwtAuthMiddleware(), controllers.UpdateCategory)
-		api.DELETE("/category/:id", middleware.JwtAuthMiddleware(), controllers.DeleteCategory)
+		api.GET("/categories", middleware.APIKeyScopeMiddleware(models.ScopeCatalogRead), controllers.GetCategories)
+		api.GET("/category/:id", middleware.APIKeyScopeMiddleware(models.ScopeCatalogRead), controllers.GetCategoryById)
+		api.POST("/category", middleware.JwtAuthMiddleware(models.ScopeCatalogWrite), controllers.CreateCategory)
+		api.PUT("/category/:id", middleware.JwtAuthMiddleware(models.ScopeCatalogWrite), controllers.UpdateCategory)
+		api.DELETE("/category/:id", middleware.JwtAuthMiddleware(models.ScopeCatalogWrite), controllers.DeleteCategory)
 
 		// Brand
-		api.GET("/brands", controllers.GetBrands)
-		api.GET("/brand/:id", controllers.GetBrandByID)
-		api.POST("/brand", middleware.JwtAuthMiddleware(), controllers.CreateBrand)
-		api.PUT("/brand/:id", middleware.JwtAuthMiddleware(), controllers.UpdateBrand)
-		api.DELETE("/brand/:id", middleware.JwtAuthMiddleware(), controllers.DeleteBrand)
+		api.GET("/brands", middleware.APIKeyScopeMiddleware(models.ScopeCatalogRead), controllers.GetBrands)
+		api.GET("/brand/:id", middleware.APIKeyScopeMiddleware(models.ScopeCatalogRead), controllers.GetBrandByID)
+		api.POST("/brand", middleware.JwtAuthMiddleware(models.ScopeCatalogWrite), controllers.CreateBrand)
+		api.PUT("/brand/:id", middleware.JwtAuthMiddleware(models.ScopeCatalogWrite), controllers.UpdateBrand)
+		api.DELETE("/brand/:id", middleware.JwtAuthMiddleware(models.ScopeCatalogWrite), controllers.DeleteBrand)
 
 		// Laptop
-		api.GET("/laptops", controllers.GetLaptops)
-		api.GET("/laptop/:id", controllers.GetLaptopById)
-		api.POST("/laptop", middleware.JwtAuthMiddleware(), controllers.CreateLaptop)
-		api.PUT("/laptop/:id", middleware.JwtAuthMiddleware(), controllers.UpdateLaptop)
-		api.DELETE("/laptop/:id", middleware.JwtAuthMiddleware(), controllers.DeleteLaptop)
+		api.GET("/laptops", middleware.APIKeyScopeMiddleware(models.ScopeCatalogRead), controllers.GetLaptops)
+		api.GET("/laptop/:id", middleware.APIKeyScopeMiddleware(models.ScopeCatalogRead), controllers.GetLaptopById)
+		api.POST("/laptop", middleware.JwtAuthMiddleware(models.ScopeCatalogWrite), controllers.CreateLaptop)
+		api.PUT("/laptop/:id", middleware.JwtAuthMiddleware(models.ScopeCatalogWrite), controllers.UpdateLaptop)
+		api.DELETE("/laptop/:id", middleware.JwtAuthMiddleware(models.ScopeCatalogWrite), controllers.DeleteLaptop)
 
 		// Profile
 		api.GET("/profiles", controllers.GetProfile)
 		api.POST("/profile", middleware.JwtAuthMiddleware(), controllers.CreateProfile)
 		api.PUT("/profile/:id", middleware.JwtAuthMiddleware(), controllers.UpdateProfile)
 
+		// Users
+		api.GET("/users/:username", controllers.GetUserPage)
+
 		// Comment
 		api.GET("/comments", controllers.GetComments)
 		api.GET("/comment/:id", controllers.GetCommentById)
-		api.POST("/comment", middleware.JwtAuthMiddleware(), controllers.CreateComment)
-		api.PUT("/comment/:id", middleware.JwtAuthMiddleware(), controllers.UpdateComment)
-		api.DELETE("/comment/:id", middleware.JwtAuthMiddleware(), controllers.DeleteComment)
+		api.GET("/comment/:id/revisions", controllers.GetCommentRevisions)
+		api.POST("/comment", middleware.JwtAuthMiddleware(models.ScopeReviewsWrite), middleware.VerifiedEmailMiddleware(), controllers.CreateComment)
+		api.PUT("/comment/:id", middleware.JwtAuthMiddleware(models.ScopeReviewsWrite), controllers.UpdateComment)
+		api.DELETE("/comment/:id", middleware.JwtAuthMiddleware(models.ScopeReviewsWrite), controllers.DeleteComment)
+
+		// Ownership
+		api.GET("/ownerships", middleware.JwtAuthMiddleware(), controllers.GetMyOwnershipClaims)
+		api.POST("/ownership", middleware.JwtAuthMiddleware(), controllers.CreateOwnershipClaim)
+		api.GET("/ownership/:id/receipt", middleware.JwtAuthMiddleware(), controllers.GetOwnershipReceipt)
+		api.DELETE("/ownership/:id", middleware.JwtAuthMiddleware(), controllers.DeleteOwnershipClaim)
+
+		// Shared collections
+		api.GET("/collections/shared/:token", controllers.GetSharedCollection)
+	}
+
+	me := r.Group("/api/me")
+	me.Use(middleware.JwtAuthMiddleware())
+	{
+		// Account and profile
+		me.GET("", controllers.GetMe)
+		me.GET("/profile", controllers.GetMyProfile)
+		me.PUT("/profile", controllers.PutMyProfile)
+		me.PATCH("/profile", controllers.PatchMyProfile)
+		me.DELETE("/profile", controllers.DeleteMyProfile)
+		me.PUT("/avatar", controllers.UpdateMyAvatar)
+		me.DELETE("/avatar", controllers.DeleteMyAvatar)
+
+		// Collections
+		me.GET("/collections", controllers.GetMyCollections)
+		me.POST("/collections", controllers.CreateCollection)
+		me.GET("/collections/:id", controllers.GetMyCollection)
+		me.PUT("/collections/:id", controllers.UpdateCollection)
+		me.DELETE("/collections/:id", controllers.DeleteCollection)
+		me.POST("/collections/:id/share", controllers.RegenerateCollectionShareToken)
+		me.PUT("/collections/:id/order", controllers.ReorderCollectionItems)
+		me.POST("/collections/:id/items", controllers.AddCollectionItem)
+		me.PUT("/collections/:id/items/:item_id", controllers.UpdateCollectionItem)
+		me.DELETE("/collections/:id/items/:item_id", controllers.DeleteCollectionItem)
+
+		// Alerts
+		me.GET("/alerts", controllers.GetMyAlerts)
+		me.POST("/alerts", controllers.CreateAlert)
+		me.PUT("/alerts/:id", controllers.UpdateAlert)
+		me.DELETE("/alerts/:id", controllers.DeleteAlert)
+
+		me.GET("/notifications", controllers.GetMyNotifications)
+		me.GET("/notifications/unread-count", controllers.GetUnreadNotificationCount)
+		me.PUT("/notifications/read-all", controllers.MarkAllNotificationsRead)
+		me.PUT("/notifications/:id/read", controllers.MarkNotificationRead)
+		// the account's preferences
+		me.GET("/notification-preferences", controllers.GetNotificationPreferences)
+		me.PUT("/notification-preferences", controllers.UpdateNotificationPreferences)
+
+		// Privacy
+		me.GET("/privacy", controllers.GetPrivacySettings)
+		me.PUT("/privacy", controllers.UpdatePrivacySettings)
+
+		// Sessions
+		me.GET("/sessions", controllers.GetMySessions)
+		me.DELETE("/sessions/:id", controllers.TerminateSession)
+
+		// Linked accounts
+		me.GET("/identities", controllers.GetMyIdentities)
+		me.DELETE("/identities/:id", controllers.UnlinkIdentity)
+
+		// API keys
+		me.GET("/api-keys", controllers.GetMyAPIKeys)
+		me.POST("/api-keys", controllers.CreateAPIKey)
+		me.DELETE("/api-keys/:id", controllers.RevokeAPIKey)
+
+		// Personal data and account deletion
+		me.GET("/exports", controllers.GetMyDataExports)
+		me.POST("/exports", controllers.RequestDataExport)
+		me.GET("/exports/:id/download", controllers.DownloadDataExport)
+		me.GET("/deletion", controllers.GetAccountDeletion)
+		me.POST("/deletion", controllers.DeleteAccount)
+		me.DELETE("/deletion", controllers.CancelAccountDeletion)
+	}
+
+	// The first admin is made at startup: register the account, list its username in
+	// ADMIN_USERNAMES and restart (see models.BootstrapAdmins).
+	admin := r.Group("/api/admin")
+	admin.Use(middleware.JwtAuthMiddleware(), middleware.RoleAuthMiddleware(models.RoleAdmin, models.RoleEditor))
+	{
+		// Ownership verification
+		admin.GET("/ownerships", controllers.GetOwnershipClaims)
+		admin.PUT("/ownership/:id/verify", controllers.VerifyOwnershipClaim)
+		admin.PUT("/ownership/:id/reject", controllers.RejectOwnershipClaim)
+
+		// Reputation
+		admin.POST("/reputations/recompute", middleware.RoleAuthMiddleware(models.RoleAdmin), controllers.RecomputeReputations)
+
+		// Login protection
+		admin.GET("/login-attempts", middleware.RoleAuthMiddleware(models.RoleAdmin), controllers.GetLoginAttempts)
+
+		// Users
+		users := admin.Group("/users", middleware.RoleAuthMiddleware(models.RoleAdmin))
+		users.GET("", controllers.GetUsers)
+		users.GET("/:id", controllers.GetUser)
+		users.POST("/:id/unlock", controllers.UnlockUser)
+		users.POST("/:id/suspend", controllers.SuspendUser)
+		users.POST("/:id/ban", controllers.BanUser)
+		users.DELETE("/:id/suspension", controllers.UnsuspendUser)
+		users.PUT("/:id/role", controllers.UpdateUserRole)
+		users.POST("/:id/force-password-reset", controllers.ForcePasswordReset)
+		users.POST("/:id/impersonate", controllers.ImpersonateUser)
+		users.GET("/:id/profile", controllers.GetUserProfile)
+		users.PUT("/:id/profile", controllers.PutUserProfile)
+		users.PATCH("/:id/profile", controllers.PatchUserProfile)
+		users.DELETE("/:id/profile", controllers.DeleteUserProfile)
+		admin.GET("/actions", middleware.RoleAuthMiddleware(models.RoleAdmin), controllers.GetAdminActions)
+
+		// Audit log
+		admin.GET("/audit-log", middleware.RoleAuthMiddleware(models.RoleAdmin), controllers.GetAuditLog)
+		admin.GET("/audit-log/verify", middleware.RoleAuthMiddleware(models.RoleAdmin), controllers.VerifyAuditLog)
+
+		// Token signing keys
+		admin.GET("/signing-keys", middleware.RoleAuthMiddleware(models.RoleAdmin), controllers.GetSigningKeys)
+		admin.POST("/signing-keys/rotate", middleware.RoleAuthMiddleware(models.RoleAdmin), controllers.RotateSigningKeys)
+
+		// Webhooks
+		webhooks := admin.Group("/webhooks", middleware.RoleAuthMiddleware(models.RoleAdmin))
+		webhooks.GET("", controllers.GetWebhooks)
+		webhooks.POST("", controllers.CreateWebhook)
+		webhooks.GET("/:id", controllers.GetWebhook)
+		webhooks.PUT("/:id", controllers.UpdateWebhook)
+		webhooks.DELETE("/:id", controllers.DeleteWebhook)
+		webhooks.POST("/:id/secret", controllers.RotateWebhookSecret)
+		webhooks.POST("/:id/ping", controllers.PingWebhook)
+		webhooks.GET("/:id/deliveries", controllers.GetWebhookDeliveries)
+		webhooks.POST("/:id/deliveries/:delivery_id/redeliver", controllers.RedeliverWebhookDelivery)
 	}
 
 	// Swagger route
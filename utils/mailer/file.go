@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileMailer appends messages to Path, or logs them when Path is empty. Logged messages
+// only show the recipient and subject unless LogBodies is set.
+type FileMailer struct {
+	Path      string
+	From      string
+	LogBodies bool
+
+	mu sync.Mutex
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	if msg.To == "" {
+		return errors.New("recipient has no email address")
+	}
+
+	if m.Path == "" {
+		if !m.LogBodies {
+			log.Printf("Mail to %s: %s", msg.To, msg.Subject)
+			return nil
+		}
+		log.Printf("Mail to %s:\n%s", msg.To, format(m.From, msg))
+		return nil
+	}
+
+	mail := format(m.From, msg)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString("=== " + time.Now().Format(time.RFC3339) + "\r\n"); err != nil {
+		return err
+	}
+	_, err = f.Write(mail)
+	return err
+}
@@ -0,0 +1,73 @@
+// Package mailer sends transactional email. SMTP is used when SMTP_HOST is set;
+// otherwise mail is written to a file (MAILER=file, MAIL_FILE) or to the log, so
+// development setups can follow links without a mail server. The log only gets the
+// recipient and subject, since bodies carry sign-in links, unless MAIL_LOG_BODIES=true.
+package mailer
+
+import (
+	"final-project-rest-api/utils"
+	"sync"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(msg Message) error
+}
+
+var (
+	mu      sync.RWMutex
+	once    sync.Once
+	current Mailer
+)
+
+// Default returns the mailer configured by the environment. It is resolved on first use
+// so the environment is loaded by then.
+func Default() Mailer {
+	once.Do(func() {
+		m := fromEnv()
+		mu.Lock()
+		if current == nil {
+			current = m
+		}
+		mu.Unlock()
+	})
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// SetDefault replaces the mailer returned by Default.
+func SetDefault(m Mailer) {
+	once.Do(func() {})
+	mu.Lock()
+	defer mu.Unlock()
+	current = m
+}
+
+func fromEnv() Mailer {
+	from := utils.Getenv("SMTP_FROM", "no-reply@laptop-reviews.local")
+	kind := utils.Getenv("MAILER", "")
+	if kind == "" && utils.Getenv("SMTP_HOST", "") != "" {
+		kind = "smtp"
+	}
+
+	switch kind {
+	case "smtp":
+		return &SMTPMailer{
+			Host:     utils.Getenv("SMTP_HOST", ""),
+			Port:     utils.Getenv("SMTP_PORT", "587"),
+			Username: utils.Getenv("SMTP_USERNAME", ""),
+			Password: utils.Getenv("SMTP_PASSWORD", ""),
+			From:     from,
+		}
+	case "file":
+		return &FileMailer{Path: utils.Getenv("MAIL_FILE", "mail.log"), From: from}
+	default:
+		return &FileMailer{From: from, LogBodies: utils.Getenv("MAIL_LOG_BODIES", "false") == "true"}
+	}
+}
@@ -0,0 +1,28 @@
+package notify
+
+import (
+	"errors"
+	"final-project-rest-api/utils/mailer"
+)
+
+// EmailChannel sends notifications through Mailer, or the default mailer when it's nil.
+type EmailChannel struct {
+	Mailer mailer.Mailer
+}
+
+func NewEmailChannel() Channel {
+	return &EmailChannel{}
+}
+
+func (e *EmailChannel) Name() string { return ChannelEmail }
+
+func (e *EmailChannel) Send(msg Message) error {
+	if msg.Email == "" {
+		return errors.New("recipient has no email address")
+	}
+	m := e.Mailer
+	if m == nil {
+		m = mailer.Default()
+	}
+	return m.Send(mailer.Message{To: msg.Email, Subject: msg.Title, Body: msg.Body})
+}
@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	ChannelInApp   = "in_app"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// Message is a notification ready to be delivered to one recipient.
+type Message struct {
+	Type       string                 `json:"type"`
+	Title      string                 `json:"title"`
+	Body       string                 `json:"body"`
+	Data       map[string]interface{} `json:"data"`
+	CreatedAt  time.Time              `json:"created_at"`
+	Email      string                 `json:"-"`
+	WebhookURL string                 `json:"-"`
+}
+
+// Channel delivers messages through one medium.
+type Channel interface {
+	Name() string
+	Send(msg Message) error
+}
+
+var (
+	mu       sync.RWMutex
+	once     sync.Once
+	channels = map[string]Channel{}
+)
+
+// Register adds a channel, replacing any channel with the same name.
+func Register(channel Channel) {
+	registerDefaults()
+	mu.Lock()
+	defer mu.Unlock()
+	channels[channel.Name()] = channel
+}
+
+// Lookup returns the channel registered under name.
+func Lookup(name string) (Channel, bool) {
+	registerDefaults()
+	mu.RLock()
+	defer mu.RUnlock()
+	channel, ok := channels[name]
+	return channel, ok
+}
+
+// registerDefaults is deferred until first use so the environment is loaded by then.
+func registerDefaults() {
+	once.Do(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, channel := range []Channel{inAppChannel{}, NewWebhookChannel(), NewEmailChannel()} {
+			if channel != nil {
+				channels[channel.Name()] = channel
+			}
+		}
+	})
+}
+
+// inAppChannel has nothing to send: in-app notifications are the stored records themselves.
+type inAppChannel struct{}
+
+func (inAppChannel) Name() string { return ChannelInApp }
+
+func (inAppChannel) Send(msg Message) error { return nil }
@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedAddress is returned for webhook URLs that point into the server's own network.
+var ErrBlockedAddress = errors.New("webhook address is not a public address")
+
+type WebhookChannel struct {
+	Client *http.Client
+}
+
+// NewWebhookChannel returns a channel whose client only connects to public addresses and
+// doesn't follow redirects, since webhook URLs are chosen by users.
+func NewWebhookChannel() Channel {
+	return &WebhookChannel{Client: NewPublicClient()}
+}
+
+// NewPublicClient returns an HTTP client for calling URLs the server doesn't control. It
+// only connects to public addresses and doesn't follow redirects.
+func NewPublicClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: refusePrivateAddress}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func (w *WebhookChannel) Name() string { return ChannelWebhook }
+
+// Send posts the message as JSON to the recipient's webhook URL.
+func (w *WebhookChannel) Send(msg Message) error {
+	if msg.WebhookURL == "" {
+		return errors.New("recipient has no webhook URL")
+	}
+	if err := CheckWebhookURL(msg.WebhookURL); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	resp, err := w.Client.Post(msg.WebhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// CheckWebhookURL rejects URLs that aren't http(s) or name a non-public host directly.
+// Host names are checked again once resolved, when the webhook is sent.
+func CheckWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("webhook URL must be a valid http or https URL")
+	}
+	if u.Hostname() == "localhost" {
+		return ErrBlockedAddress
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !isPublicIP(ip) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+// refusePrivateAddress runs after DNS resolution, so it also catches names resolving to
+// internal addresses.
+func refusePrivateAddress(network string, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicIP(ip) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
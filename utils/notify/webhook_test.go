@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckWebhookURL(t *testing.T) {
+	for url, blocked := range map[string]bool{
+		"http://127.0.0.1:8080/hook":        true,
+		"http://localhost/hook":             true,
+		"http://10.1.2.3/hook":              true,
+		"http://192.168.0.10/hook":          true,
+		"http://169.254.169.254/latest":     true,
+		"http://0.0.0.0/hook":               true,
+		"http://[::1]/hook":                 true,
+		"https://hooks.example.com/alerts":  false,
+		"https://93.184.216.34/alerts/hook": false,
+	} {
+		err := CheckWebhookURL(url)
+		if got := errors.Is(err, ErrBlockedAddress); got != blocked {
+			t.Errorf("CheckWebhookURL(%q) = %v, want blocked %v", url, err, blocked)
+		}
+	}
+
+	if err := CheckWebhookURL("ftp://example.com/hook"); err == nil {
+		t.Error("CheckWebhookURL accepted an ftp URL")
+	}
+}
+
+func TestWebhookChannelRefusesLoopback(t *testing.T) {
+	hit := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+	}))
+	defer server.Close()
+
+	channel := NewWebhookChannel().(*WebhookChannel)
+	if err := channel.Send(Message{Type: "test", WebhookURL: server.URL}); !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("Send to %s = %v, want ErrBlockedAddress", server.URL, err)
+	}
+
+	// the dialer refuses the address even when the URL check is skipped
+	if _, err := channel.Client.Post(server.URL, "application/json", nil); !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("Post to %s = %v, want ErrBlockedAddress", server.URL, err)
+	}
+	if hit {
+		t.Fatal("the webhook reached the loopback server")
+	}
+}
@@ -0,0 +1,202 @@
+// Package mock is a local OpenID Connect provider for trying out and testing the social
+// login without a real provider. It approves every authorization request.
+package mock
+
+import (
+	"encoding/json"
+	"final-project-rest-api/utils/oidc"
+	"final-project-rest-api/utils/token"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+type grant struct {
+	clientID      string
+	redirectURI   string
+	challenge     string
+	nonce         string
+	email         string
+	emailVerified bool
+	expiresAt     time.Time
+}
+
+// Provider logs in as the email in the login_hint parameter, or as Email without one.
+// A login_hint of "unverified:<email>" reports the email as unverified.
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Email        string
+
+	key    *token.Key
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+func New(issuer string, clientID string, clientSecret string) (*Provider, error) {
+	key, err := token.GenerateKey(token.AlgRS256)
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{
+		Issuer:       strings.TrimRight(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Email:        "mock.user@example.com",
+		key:          key,
+		grants:       map[string]grant{},
+	}, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func tokenError(w http.ResponseWriter, code string, description string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code, "error_description": description})
+}
+
+func (p *Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                p.Issuer,
+			"authorization_endpoint":                p.Issuer + "/authorize",
+			"token_endpoint":                        p.Issuer + "/token",
+			"jwks_uri":                              p.Issuer + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+			"code_challenge_methods_supported":      []string{"S256"},
+		})
+	case "/jwks":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []token.JWK{p.key.JWK()}})
+	case "/authorize":
+		p.authorize(w, r)
+	case "/token":
+		p.token(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI := q.Get("redirect_uri")
+	if q.Get("client_id") != p.ClientID || redirectURI == "" {
+		http.Error(w, "unknown client or missing redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "only the code flow with S256 PKCE is supported", http.StatusBadRequest)
+		return
+	}
+
+	g := grant{
+		clientID:      p.ClientID,
+		redirectURI:   redirectURI,
+		challenge:     q.Get("code_challenge"),
+		nonce:         q.Get("nonce"),
+		email:         p.Email,
+		emailVerified: true,
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	if hint := q.Get("login_hint"); hint != "" {
+		if email, ok := strings.CutPrefix(hint, "unverified:"); ok {
+			g.email, g.emailVerified = email, false
+		} else {
+			g.email = hint
+		}
+	}
+
+	code, err := oidc.RandomString()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p.mu.Lock()
+	p.grants[code] = g
+	p.mu.Unlock()
+
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := target.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	target.RawQuery = params.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type", "only authorization_code is supported")
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != p.ClientID || clientSecret != p.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	g, ok := p.grants[code]
+	delete(p.grants, code)
+	p.mu.Unlock()
+	if !ok || time.Now().After(g.expiresAt) || g.clientID != clientID || g.redirectURI != r.PostForm.Get("redirect_uri") {
+		tokenError(w, "invalid_grant", "unknown, expired or mismatched code")
+		return
+	}
+	if oidc.CodeChallenge(r.PostForm.Get("code_verifier")) != g.challenge {
+		tokenError(w, "invalid_grant", "PKCE verification failed")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                p.Issuer,
+		"sub":                "mock|" + g.email,
+		"aud":                p.ClientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"email":              g.email,
+		"email_verified":     g.emailVerified,
+		"name":               strings.Split(g.email, "@")[0],
+		"preferred_username": strings.Split(g.email, "@")[0],
+	}
+	if g.nonce != "" {
+		claims["nonce"] = g.nonce
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = p.key.ID
+	signed, err := idToken.SignedString(p.key.PrivateKey)
+	if err != nil {
+		tokenError(w, "server_error", err.Error())
+		return
+	}
+
+	accessToken, _ := oidc.RandomString()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     signed,
+	})
+}
@@ -0,0 +1,220 @@
+// Package oidc is a minimal OpenID Connect relying party: discovery, the authorization
+// code flow with PKCE, and ID token verification against the provider's JWKS.
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"final-project-rest-api/utils"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Provider is an OpenID Connect provider we accept logins from.
+type Provider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      *keySet
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims are the identity claims of a verified ID token.
+type Claims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+var (
+	providersOnce sync.Once
+	providers     map[string]*Provider
+)
+
+// Providers returns the configured providers. OIDC_PROVIDERS lists their names, and each
+// is configured with OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET
+// and optionally OIDC_<NAME>_SCOPES.
+func Providers() map[string]*Provider {
+	providersOnce.Do(func() {
+		providers = map[string]*Provider{}
+		for _, name := range strings.Split(utils.Getenv("OIDC_PROVIDERS", ""), ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+			p := &Provider{
+				Name:         name,
+				Issuer:       strings.TrimRight(utils.Getenv(prefix+"ISSUER", ""), "/"),
+				ClientID:     utils.Getenv(prefix+"CLIENT_ID", ""),
+				ClientSecret: utils.Getenv(prefix+"CLIENT_SECRET", ""),
+				Scopes:       strings.Fields(utils.Getenv(prefix+"SCOPES", "openid email profile")),
+			}
+			if p.Issuer == "" || p.ClientID == "" {
+				continue
+			}
+			providers[name] = p
+		}
+	})
+	return providers
+}
+
+// ProviderNames lists the configured providers in order.
+func ProviderNames() []string {
+	names := make([]string, 0, len(Providers()))
+	for name := range Providers() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func Lookup(name string) (*Provider, bool) {
+	p, ok := Providers()[name]
+	return p, ok
+}
+
+// RandomString returns a random URL-safe string, for states, nonces and PKCE verifiers.
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallenge is the S256 PKCE challenge of a verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func getJSON(endpoint string, v interface{}) error {
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", endpoint, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (p *Provider) discover() (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var d discovery
+	if err := getJSON(p.Issuer+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, err
+	}
+	if strings.TrimRight(d.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("provider reports issuer %q, expected %q", d.Issuer, p.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("provider configuration is incomplete")
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// AuthCodeURL is where to send the user to log in with the provider.
+func (p *Provider) AuthCodeURL(redirectURL string, state string, nonce string, verifier string, loginHint string) (string, error) {
+	d, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.ClientID)
+	params.Set("redirect_uri", redirectURL)
+	params.Set("scope", strings.Join(p.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", CodeChallenge(verifier))
+	params.Set("code_challenge_method", "S256")
+	if loginHint != "" {
+		params.Set("login_hint", loginHint)
+	}
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// Exchange trades an authorization code for tokens and returns the claims of the
+// verified ID token.
+func (p *Provider) Exchange(redirectURL string, code string, verifier string, nonce string) (Claims, error) {
+	d, err := p.discover()
+	if err != nil {
+		return Claims{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+	form.Set("code_verifier", verifier)
+	form.Set("client_id", p.ClientID)
+	req, err := http.NewRequest(http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Claims{}, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return Claims{}, fmt.Errorf("token request failed: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return Claims{}, err
+	}
+	if tokens.IDToken == "" {
+		return Claims{}, errors.New("provider returned no ID token")
+	}
+	return p.verifyIDToken(d, tokens.IDToken, nonce)
+}
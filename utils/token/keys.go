@@ -0,0 +1,306 @@
+package token
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"final-project-rest-api/utils"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// Key is an asymmetric key pair login tokens are signed with. Keys loaded only for
+// verification have no private key.
+type Key struct {
+	ID         string
+	Algorithm  string
+	PrivateKey crypto.PrivateKey
+	PublicKey  crypto.PublicKey
+}
+
+// JWK is the public part of a key as published in the JWKS document.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// reloadInterval limits how often an unknown kid makes the keys reload, so tokens with
+// made-up kids can't keep the database busy.
+const reloadInterval = 10 * time.Second
+
+var (
+	keysMu     sync.RWMutex
+	signingKey *Key
+	verifyKeys = map[string]*Key{}
+	hs256Until time.Time
+
+	reloadMu   sync.Mutex
+	reloadKeys func() error
+	lastReload time.Time
+)
+
+// SigningAlgorithm is the algorithm new login tokens are signed with, from JWT_SIGNING_ALG.
+// HS256 keeps signing with API_SECRET.
+func SigningAlgorithm() string {
+	switch alg := utils.Getenv("JWT_SIGNING_ALG", AlgRS256); alg {
+	case AlgHS256, AlgEdDSA:
+		return alg
+	default:
+		return AlgRS256
+	}
+}
+
+// acceptHS256 reports whether tokens signed with API_SECRET are still accepted. They are
+// while tokens are signed with it; after switching to asymmetric keys, only until the ones
+// issued before have expired, unless JWT_ACCEPT_HS256 says otherwise.
+func acceptHS256() bool {
+	if SigningAlgorithm() == AlgHS256 {
+		return true
+	}
+	switch utils.Getenv("JWT_ACCEPT_HS256", "") {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	return signingKey == nil || time.Now().Before(hs256Until)
+}
+
+// SetKeys replaces the keys tokens are verified against, and signs new tokens with the
+// key with the given ID. Without a signing key, tokens are signed with API_SECRET.
+// Tokens signed with API_SECRET are accepted until hs256Cutoff.
+func SetKeys(keys []*Key, signingID string, hs256Cutoff time.Time) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	signingKey = nil
+	hs256Until = hs256Cutoff
+	verifyKeys = make(map[string]*Key, len(keys))
+	for _, k := range keys {
+		verifyKeys[k.ID] = k
+		if k.ID == signingID && k.PrivateKey != nil {
+			signingKey = k
+		}
+	}
+}
+
+func currentSigningKey() *Key {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	return signingKey
+}
+
+// OnUnknownKey sets how keys are reloaded when a token names a key that isn't loaded,
+// such as one another instance just rotated to.
+func OnUnknownKey(reload func() error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	reloadKeys = reload
+}
+
+func verificationKey(id string) (*Key, bool) {
+	keysMu.RLock()
+	k, ok := verifyKeys[id]
+	keysMu.RUnlock()
+	if ok || !reloadUnknownKey() {
+		return k, ok
+	}
+
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	k, ok = verifyKeys[id]
+	return k, ok
+}
+
+// reloadUnknownKey reloads the keys unless they were reloaded for an unknown key recently.
+func reloadUnknownKey() bool {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	if reloadKeys == nil || time.Since(lastReload) < reloadInterval {
+		return false
+	}
+	lastReload = time.Now()
+	if err := reloadKeys(); err != nil {
+		log.Printf("Failed to reload signing keys: %v", err)
+		return false
+	}
+	return true
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	if k.Algorithm == AlgEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// GenerateKey creates a key pair for alg. Its ID is the RFC 7638 thumbprint of the public key.
+func GenerateKey(alg string) (*Key, error) {
+	k := &Key{Algorithm: alg}
+	switch alg {
+	case AlgRS256:
+		private, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		k.PrivateKey, k.PublicKey = private, &private.PublicKey
+	case AlgEdDSA:
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		k.PrivateKey, k.PublicKey = private, public
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+
+	thumbprint, err := k.thumbprint()
+	if err != nil {
+		return nil, err
+	}
+	k.ID = thumbprint
+	return k, nil
+}
+
+func (k *Key) JWK() JWK {
+	jwk := JWK{Use: "sig", Alg: k.Algorithm, Kid: k.ID}
+	switch public := k.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
+
+func (k *Key) thumbprint() (string, error) {
+	jwk := k.JWK()
+	var members interface{}
+	// the required members in lexicographic order, per RFC 7638
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	default:
+		return "", errors.New("unsupported key type")
+	}
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// EncodePublicKey returns the public key as a PEM block.
+func (k *Key) EncodePublicKey() (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(k.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// SealPrivateKey encrypts the private key with a key derived from API_SECRET, so a copy
+// of the database alone can't be used to sign tokens.
+func (k *Key) SealPrivateKey() (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := sealingCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, der, []byte(k.ID))), nil
+}
+
+func sealingCipher() (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(API_SECRET + ":signing-keys"))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ParseKey restores a key from its public PEM and, when not empty, its sealed private key.
+func ParseKey(id string, alg string, publicPEM string, sealedPrivate string) (*Key, error) {
+	block, _ := pem.Decode([]byte(publicPEM))
+	if block == nil {
+		return nil, errors.New("invalid public key")
+	}
+	public, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	k := &Key{ID: id, Algorithm: alg, PublicKey: public}
+	if sealedPrivate == "" {
+		return k, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(sealedPrivate)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := sealingCipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("invalid private key")
+	}
+	der, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("can't decrypt private key %s, was API_SECRET changed? %w", id, err)
+	}
+	k.PrivateKey, err = x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
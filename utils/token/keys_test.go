@@ -0,0 +1,158 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func generateKey(t *testing.T, alg string) *Key {
+	t.Helper()
+	k, err := GenerateKey(alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// useKeys loads keys for the test and unloads them after it.
+func useKeys(t *testing.T, keys []*Key, signingID string, hs256Cutoff time.Time) {
+	t.Helper()
+	SetKeys(keys, signingID, hs256Cutoff)
+	t.Cleanup(func() {
+		SetKeys(nil, "", time.Time{})
+		OnUnknownKey(nil)
+		lastReload = time.Time{}
+	})
+}
+
+func TestSignAndVerify(t *testing.T) {
+	for _, alg := range []string{AlgRS256, AlgEdDSA} {
+		k := generateKey(t, alg)
+		useKeys(t, []*Key{k}, k.ID, time.Time{})
+
+		signed, err := GenerateSessionToken(7, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := parse(signed)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if parsed.Header["kid"] != k.ID || parsed.Method.Alg() != alg {
+			t.Fatalf("%s: header = %v", alg, parsed.Header)
+		}
+		if claims := parsed.Claims.(jwt.MapClaims); claims["user_id"] != float64(7) || claims["sid"] != float64(3) {
+			t.Fatalf("%s: claims = %v", alg, claims)
+		}
+	}
+}
+
+func TestVerifiesWithTheKeyNamedByKid(t *testing.T) {
+	previous, current := generateKey(t, AlgRS256), generateKey(t, AlgRS256)
+	useKeys(t, []*Key{previous}, previous.ID, time.Time{})
+	before, err := GenerateToken(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rotated: the previous key only verifies
+	SetKeys([]*Key{previous, current}, current.ID, time.Time{})
+	if _, err := parse(before); err != nil {
+		t.Fatalf("token signed with the previous key: %v", err)
+	}
+	after, _ := GenerateToken(1)
+	if parsed, err := parse(after); err != nil || parsed.Header["kid"] != current.ID {
+		t.Fatalf("new token: kid %v, %v", parsed.Header["kid"], err)
+	}
+
+	// once the previous key is no longer published its tokens are rejected
+	SetKeys([]*Key{current}, current.ID, time.Time{})
+	if _, err := parse(before); err == nil {
+		t.Fatal("a token signed with a retired key was accepted")
+	}
+}
+
+func TestUnknownKidReloadsKeys(t *testing.T) {
+	old, rotated := generateKey(t, AlgEdDSA), generateKey(t, AlgEdDSA)
+	useKeys(t, []*Key{rotated}, rotated.ID, time.Time{})
+	signed, _ := GenerateToken(1)
+
+	// this instance still has the old key only
+	SetKeys([]*Key{old}, old.ID, time.Time{})
+	reloads := 0
+	OnUnknownKey(func() error {
+		reloads++
+		SetKeys([]*Key{old, rotated}, rotated.ID, time.Time{})
+		return nil
+	})
+	if _, err := parse(signed); err != nil {
+		t.Fatalf("token signed with a key another instance rotated to: %v", err)
+	}
+
+	// unknown kids don't reload again right away
+	SetKeys([]*Key{old}, old.ID, time.Time{})
+	if _, err := parse(signed); err == nil {
+		t.Fatal("an unknown key was accepted without a reload")
+	}
+	if reloads != 1 {
+		t.Fatalf("keys reloaded %d times, want 1", reloads)
+	}
+}
+
+func TestHS256IsAcceptedUntilTheCutoff(t *testing.T) {
+	t.Setenv("JWT_SIGNING_ALG", AlgRS256)
+	useKeys(t, nil, "", time.Time{})
+	legacy, err := GenerateToken(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parse(legacy); err != nil {
+		t.Fatalf("HS256 token without signing keys: %v", err)
+	}
+
+	k := generateKey(t, AlgRS256)
+	SetKeys([]*Key{k}, k.ID, time.Now().Add(time.Hour))
+	if _, err := parse(legacy); err != nil {
+		t.Fatalf("HS256 token before the cutoff: %v", err)
+	}
+
+	SetKeys([]*Key{k}, k.ID, time.Now().Add(-time.Second))
+	if _, err := parse(legacy); err == nil {
+		t.Fatal("an HS256 token was accepted after the cutoff")
+	}
+	t.Setenv("JWT_ACCEPT_HS256", "true")
+	if _, err := parse(legacy); err != nil {
+		t.Fatalf("HS256 token with JWT_ACCEPT_HS256=true: %v", err)
+	}
+}
+
+func TestSealedKeyRoundTrip(t *testing.T) {
+	k := generateKey(t, AlgRS256)
+	public, err := k.EncodePublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := k.SealPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := ParseKey(k.ID, k.Algorithm, public, sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	useKeys(t, []*Key{restored}, restored.ID, time.Time{})
+	signed, err := GenerateToken(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parse(signed); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ParseKey("another-kid", k.Algorithm, public, sealed); err == nil {
+		t.Fatal("a sealed key was opened under another kid")
+	}
+}
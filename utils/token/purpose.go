@@ -0,0 +1,51 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var ErrInvalidPurposeToken = errors.New("invalid or expired token")
+
+// purposeKey derives a signing key per purpose, so a token made for one purpose (or a
+// login token) is never accepted for another.
+func purposeKey(purpose string) []byte {
+	return []byte(API_SECRET + ":" + purpose)
+}
+
+// GeneratePurposeToken signs a short-lived token carrying subject for a single purpose,
+// such as an email verification link.
+func GeneratePurposeToken(purpose string, subject string, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"sub":     subject,
+		"purpose": purpose,
+		"exp":     time.Now().Add(ttl).Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(purposeKey(purpose))
+}
+
+// ParsePurposeToken returns the subject of a valid, unexpired token made for purpose.
+func ParsePurposeToken(purpose string, tokenString string) (string, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return purposeKey(purpose), nil
+	})
+	if err != nil || !token.Valid {
+		return "", ErrInvalidPurposeToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != purpose {
+		return "", ErrInvalidPurposeToken
+	}
+	subject, ok := claims["sub"].(string)
+	if !ok || subject == "" {
+		return "", ErrInvalidPurposeToken
+	}
+	return subject, nil
+}
@@ -1,6 +1,7 @@
 package token
 
 import (
+	"errors"
 	"final-project-rest-api/utils"
 	"fmt"
 	"strconv"
@@ -13,8 +14,30 @@ import (
 
 var API_SECRET = utils.Getenv("API_SECRET", "secret_key")
 
-func GenerateToken(user_id uint) (string, error) {
+// UserIDKey is where the auth middleware stores the user of requests it authenticated
+// without a JWT, such as with an API key.
+const UserIDKey = "user_id"
+
+// claimsKey is where the verified claims of the request's token are kept.
+const claimsKey = "token_claims"
+
+// Lifespan is how long login tokens stay valid, from TOKEN_HOUR_LIFESPAN.
+func Lifespan() (time.Duration, error) {
 	token_lifespan, err := strconv.Atoi(utils.Getenv("TOKEN_HOUR_LIFESPAN", "1"))
+	if err != nil {
+		return 0, err
+	}
+	return time.Hour * time.Duration(token_lifespan), nil
+}
+
+func GenerateToken(user_id uint) (string, error) {
+	return GenerateSessionToken(user_id, 0)
+}
+
+// GenerateSessionToken issues a token for a tracked login session. The session ID is
+// carried in the "sid" claim; 0 issues a token without one.
+func GenerateSessionToken(user_id uint, session_id uint) (string, error) {
+	lifespan, err := Lifespan()
 
 	if err != nil {
 		return "", err
@@ -23,25 +46,65 @@ func GenerateToken(user_id uint) (string, error) {
 	claims := jwt.MapClaims{}
 	claims["authorized"] = true
 	claims["user_id"] = user_id
-	claims["exp"] = time.Now().Add(time.Hour * time.Duration(token_lifespan)).Unix()
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = time.Now().Add(lifespan).Unix()
+	if session_id != 0 {
+		claims["sid"] = session_id
+	}
+	return sign(claims)
+}
 
-	return token.SignedString([]byte(API_SECRET))
+// GenerateImpersonationToken issues a token for the user's session that an admin started to
+// act as them. The admin is carried in the "imp" claim.
+func GenerateImpersonationToken(user_id uint, session_id uint, impersonator_id uint, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{}
+	claims["authorized"] = true
+	claims["user_id"] = user_id
+	claims["sid"] = session_id
+	claims["imp"] = impersonator_id
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = time.Now().Add(ttl).Unix()
+	return sign(claims)
+}
 
+func sign(claims jwt.MapClaims) (string, error) {
+	key := currentSigningKey()
+	if key == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(API_SECRET))
+	}
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
 }
 
-func TokenValid(c *gin.Context) error {
-	tokenString := ExtractToken(c)
-	_, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+// keyFunc picks the key a login token is verified with: API_SECRET for HS256 tokens, or
+// the public key named by the kid header.
+func keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if !acceptHS256() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(API_SECRET), nil
-	})
-	if err != nil {
-		return err
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodEd25519:
+		kid, _ := token.Header["kid"].(string)
+		key, ok := verificationKey(kid)
+		if !ok || key.method().Alg() != token.Method.Alg() {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key.PublicKey, nil
 	}
-	return nil
+	return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+}
+
+func parse(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, keyFunc)
+}
+
+func TokenValid(c *gin.Context) error {
+	_, err := requestClaims(c)
+	return err
 }
 
 func ExtractToken(c *gin.Context) string {
@@ -57,24 +120,69 @@ func ExtractToken(c *gin.Context) string {
 }
 
 func ExtractTokenID(c *gin.Context) (uint, error) {
+	if userID, ok := c.Get(UserIDKey); ok {
+		return userID.(uint), nil
+	}
 
-	tokenString := ExtractToken(c)
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(API_SECRET), nil
-	})
+	claims, err := requestClaims(c)
+	if err != nil {
+		return 0, err
+	}
+	uid, err := strconv.ParseUint(fmt.Sprintf("%.0f", claims["user_id"]), 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint(uid), nil
+}
+
+// ExtractIssuedAt returns when the request's token was issued, or 0 for tokens issued
+// before this was recorded.
+func ExtractIssuedAt(c *gin.Context) (int64, error) {
+	claims, err := requestClaims(c)
 	if err != nil {
 		return 0, err
 	}
+	iat, _ := claims["iat"].(float64)
+	return int64(iat), nil
+}
+
+// ExtractSessionID returns the session of the request's token, or 0 for tokens issued
+// without one.
+func ExtractSessionID(c *gin.Context) (uint, error) {
+	claims, err := requestClaims(c)
+	if err != nil {
+		return 0, err
+	}
+	sid, _ := claims["sid"].(float64)
+	return uint(sid), nil
+}
+
+// ExtractImpersonatorID returns the admin acting as the user with the request's token, or
+// 0 when the user is logged in themselves.
+func ExtractImpersonatorID(c *gin.Context) (uint, error) {
+	claims, err := requestClaims(c)
+	if err != nil {
+		return 0, err
+	}
+	imp, _ := claims["imp"].(float64)
+	return uint(imp), nil
+}
+
+// requestClaims verifies the request's token the first time it is needed and keeps its
+// claims in the context, so the rest of the request doesn't parse it again.
+func requestClaims(c *gin.Context) (jwt.MapClaims, error) {
+	if claims, ok := c.Get(claimsKey); ok {
+		return claims.(jwt.MapClaims), nil
+	}
+
+	token, err := parse(ExtractToken(c))
+	if err != nil {
+		return nil, err
+	}
 	claims, ok := token.Claims.(jwt.MapClaims)
-	if ok && token.Valid {
-		uid, err := strconv.ParseUint(fmt.Sprintf("%.0f", claims["user_id"]), 10, 32)
-		if err != nil {
-			return 0, err
-		}
-		return uint(uid), nil
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
 	}
-	return 0, nil
+	c.Set(claimsKey, claims)
+	return claims, nil
 }
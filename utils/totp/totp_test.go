@@ -0,0 +1,49 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of RFC 6238 appendix B, "12345678901234567890".
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestRFC6238Vectors(t *testing.T) {
+	// the RFC lists 8-digit codes; 6-digit codes are their last six digits
+	for unix, want := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		got, err := CodeAt(rfc6238Secret, Counter(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("code at %d = %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	step, ok := Validate(rfc6238Secret, "050 471", now, 1)
+	if !ok || step != Counter(now) {
+		t.Fatalf("Validate(current code) = %d, %v", step, ok)
+	}
+
+	// 1111111109 falls in the previous step: accepted within the skew and reported as that step
+	if step, ok := Validate(rfc6238Secret, "081804", now, 1); !ok || step != Counter(now)-1 {
+		t.Fatalf("Validate(previous code) = %d, %v", step, ok)
+	}
+	if _, ok := Validate(rfc6238Secret, "081804", now, 0); ok {
+		t.Fatal("Validate accepted a code outside the skew")
+	}
+	if _, ok := Validate(rfc6238Secret, "000000", now, 1); ok {
+		t.Fatal("Validate accepted a wrong code")
+	}
+}
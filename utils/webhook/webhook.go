@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"final-project-rest-api/utils/notify"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+
+	maxResponseBody = 1024
+)
+
+// Client sends the deliveries. Webhook URLs are set by admins, so like the alert webhooks
+// it only connects to public addresses and doesn't follow redirects.
+var Client = notify.NewPublicClient()
+
+// Sign returns the signature receivers should compare against the X-Webhook-Signature
+// header: "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with
+// the webhook secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is valid for the body and timestamp.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Result is what the receiver answered to one delivery attempt.
+type Result struct {
+	StatusCode int
+	Body       string
+}
+
+// Send posts a signed payload to url. It returns an error for transport failures and
+// non-2xx responses; the result is filled whenever the receiver answered.
+func Send(url string, secret string, event string, deliveryID uint, body []byte) (Result, error) {
+	var result Result
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return result, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "laptop-api-webhooks/1.0")
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(deliveryID), 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(secret, timestamp, body))
+
+	resp, err := Client.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	result.StatusCode = resp.StatusCode
+	result.Body = string(respBody)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return result, fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+	return result, nil
+}